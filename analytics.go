@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// maxAnalyticsBeacon is the maximum size in bytes of a beacon request body.
+const maxAnalyticsBeacon int64 = 4096
+
+// maxAnalyticsKeys is the maximum number of distinct values counted for
+// pages, referrers and dimensions together, so that clients cannot exhaust
+// memory by sending arbitrary values.
+const maxAnalyticsKeys int = 10000
+
+// AnalyticsBeacon is the JSON document sent to an AnalyticsHandler for each
+// page view. Dimensions holds any additional values to count, such as the
+// screen size or the language of the page.
+type AnalyticsBeacon struct {
+	Page       string            `json:"page"`
+	Referrer   string            `json:"referrer"`
+	Dimensions map[string]string `json:"dimensions"`
+}
+
+// AnalyticsReport holds the counts aggregated by an AnalyticsHandler.
+type AnalyticsReport struct {
+	Pages      map[string]int64            `json:"pages"`
+	Referrers  map[string]int64            `json:"referrers"`
+	Dimensions map[string]map[string]int64 `json:"dimensions"`
+}
+
+// AnalyticsHandler collects first-party analytics for sites served by the
+// package. Pages send a beacon with a POST request, for example with
+// navigator.sendBeacon, and the handler counts views by page, referring host
+// and dimension. Only the path of each page and the host of each referrer are
+// kept, and nothing identifying the client is stored. The report is served
+// by a separate handler, returned by ReportHandler, so that it can be put
+// behind authentication while beacons stay public. If a flush path is given,
+// the report is
+// periodically written to that file and reloaded from it when the handler
+// is created, so counts survive restarts.
+type AnalyticsHandler struct {
+	mutex     sync.Mutex
+	report    AnalyticsReport
+	keys      int
+	flushPath string
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewAnalyticsHandler returns a new AnalyticsHandler. If flushPath is not
+// empty the report is loaded from that file if it exists, and written to it
+// every flushInterval and when the handler is closed.
+func NewAnalyticsHandler(flushPath string, flushInterval time.Duration) (*AnalyticsHandler, error) {
+
+	h := &AnalyticsHandler{
+		report: AnalyticsReport{
+			Pages:      make(map[string]int64),
+			Referrers:  make(map[string]int64),
+			Dimensions: make(map[string]map[string]int64),
+		},
+		flushPath: flushPath,
+		done:      make(chan struct{}),
+	}
+
+	if flushPath == "" {
+		return h, nil
+	}
+
+	contents, err := os.ReadFile(flushPath)
+
+	if err == nil {
+		err = json.Unmarshal(contents, &h.report)
+	}
+
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	h.keys = len(h.report.Pages) + len(h.report.Referrers)
+
+	for _, counts := range h.report.Dimensions {
+		h.keys += 1 + len(counts)
+	}
+
+	if flushInterval > 0 {
+		go h.flushPeriodically(flushInterval)
+	}
+
+	return h, nil
+}
+
+// ServeHTTP records a beacon sent with POST.
+func (h *AnalyticsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	setServerHeader(w)
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	var beacon AnalyticsBeacon
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAnalyticsBeacon))
+
+	if err == nil {
+		err = json.Unmarshal(body, &beacon)
+	}
+
+	if err != nil || beacon.Page == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	h.Record(beacon)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReportHandler returns a handler that serves the report as JSON for GET
+// requests. The report shows which pages are read and where readers come
+// from, so the handler should usually be wrapped in one that authenticates
+// the site's owners, such as a TokenAuthHandler.
+func (h *AnalyticsHandler) ReportHandler() http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		setServerHeader(w)
+
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		json.NewEncoder(w).Encode(h.Report())
+	})
+}
+
+// Record adds a beacon to the counts.
+func (h *AnalyticsHandler) Record(beacon AnalyticsBeacon) {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if page, err := url.Parse(beacon.Page); err == nil && page.Path != "" {
+		h.increment(h.report.Pages, page.Path)
+	}
+
+	if referrer, err := url.Parse(beacon.Referrer); err == nil && referrer.Host != "" {
+		h.increment(h.report.Referrers, referrer.Host)
+	}
+
+	for name, value := range beacon.Dimensions {
+
+		counts, ok := h.report.Dimensions[name]
+
+		if !ok {
+
+			// A new dimension needs a key for its name and one for its value
+			if h.keys+2 > maxAnalyticsKeys {
+				continue
+			}
+
+			counts = make(map[string]int64)
+			h.report.Dimensions[name] = counts
+			h.keys++
+		}
+
+		h.increment(counts, value)
+	}
+}
+
+// Report returns a copy of the current counts.
+func (h *AnalyticsHandler) Report() AnalyticsReport {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	report := AnalyticsReport{
+		Pages:      copyCounts(h.report.Pages),
+		Referrers:  copyCounts(h.report.Referrers),
+		Dimensions: make(map[string]map[string]int64, len(h.report.Dimensions)),
+	}
+
+	for name, counts := range h.report.Dimensions {
+		report.Dimensions[name] = copyCounts(counts)
+	}
+
+	return report
+}
+
+// Flush writes the report to the handler's flush path. The report is written
+// to a temporary file which is then renamed, so the file is never left
+// partially written.
+func (h *AnalyticsHandler) Flush() error {
+
+	if h.flushPath == "" {
+		return nil
+	}
+
+	contents, err := json.Marshal(h.Report())
+
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(h.flushPath), ".analytics-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tempFile.Name())
+
+	_, err = tempFile.Write(contents)
+
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempFile.Name(), h.flushPath)
+}
+
+// Close stops the periodic flush and writes the report a final time.
+func (h *AnalyticsHandler) Close() error {
+
+	h.closeOnce.Do(func() {
+		close(h.done)
+	})
+
+	return h.Flush()
+}
+
+// flushPeriodically flushes the report every interval until the handler is
+// closed.
+func (h *AnalyticsHandler) flushPeriodically(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+
+		select {
+
+		case <-ticker.C:
+			h.Flush()
+
+		case <-h.done:
+			return
+		}
+	}
+}
+
+// increment increments the count for key, unless it is a new key and the
+// report already holds the maximum number of keys.
+func (h *AnalyticsHandler) increment(counts map[string]int64, key string) {
+
+	if _, ok := counts[key]; !ok {
+
+		if h.keys >= maxAnalyticsKeys {
+			return
+		}
+
+		h.keys++
+	}
+
+	counts[key]++
+}
+
+// incrementCount increments the count for key, unless the counts already
+// hold the maximum number of keys.
+func incrementCount(counts map[string]int64, key string) {
+
+	if _, ok := counts[key]; !ok && len(counts) >= maxAnalyticsKeys {
+		return
+	}
+
+	counts[key]++
+}
+
+// copyCounts returns a copy of the counts.
+func copyCounts(counts map[string]int64) map[string]int64 {
+
+	copied := make(map[string]int64, len(counts))
+
+	for key, count := range counts {
+		copied[key] = count
+	}
+
+	return copied
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test AnalyticsHandler functions and methods
+func TestAnalyticsHandler(t *testing.T) {
+
+	var (
+		h         *AnalyticsHandler
+		flushPath string
+		report    AnalyticsReport
+		response  *httptest.ResponseRecorder
+		request   *http.Request
+		err       error
+	)
+
+	flushPath = filepath.Join(t.TempDir(), "analytics.json")
+	h, err = NewAnalyticsHandler(flushPath, 0)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewAnalyticsHandler. Got: %s", err)
+	}
+
+	// Test ServeHTTP with two beacons for the same page
+	for i := 0; i < 2; i++ {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("POST", "/analytics", strings.NewReader(
+			`{"page": "/about?utm_source=x", "referrer": "https://search.example.com/q?id=1",`+
+				` "dimensions": {"lang": "en"}}`))
+		h.ServeHTTP(response, request)
+
+		if response.Code != http.StatusNoContent {
+			t.Errorf("Expected StatusNoContent from AnalyticsHandler. Got: %d",
+				response.Code)
+		}
+	}
+
+	// Test ServeHTTP with an invalid beacon
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST", "/analytics", strings.NewReader("{}"))
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected StatusBadRequest from AnalyticsHandler. Got: %d",
+			response.Code)
+	}
+
+	// Check the beacon handler does not serve the report
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/analytics", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected StatusMethodNotAllowed for GET. Got: %d", response.Code)
+	}
+
+	// Test ReportHandler serves the report
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/analytics/report", nil)
+	h.ReportHandler().ServeHTTP(response, request)
+
+	json.Unmarshal(response.Body.Bytes(), &report)
+
+	// Check only the page path and referrer host were counted
+	if report.Pages["/about"] != 2 || report.Referrers["search.example.com"] != 2 ||
+		report.Dimensions["lang"]["en"] != 2 {
+		t.Errorf("Expected two views of /about in the report. Got: %s",
+			response.Body.String())
+	}
+
+	// Test the counts survive closing and reloading the handler
+	if err = h.Close(); err != nil {
+		t.Fatalf("Expected no error from Close. Got: %s", err)
+	}
+
+	h, err = NewAnalyticsHandler(flushPath, 0)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewAnalyticsHandler. Got: %s", err)
+	}
+
+	if report = h.Report(); report.Pages["/about"] != 2 {
+		t.Errorf("Expected two views of /about after reloading. Got: %v", report.Pages)
+	}
+
+	// Check the number of keys is limited across the whole report
+	for i := 0; i < maxAnalyticsKeys; i++ {
+
+		h.Record(AnalyticsBeacon{
+			Page:       fmt.Sprintf("/page/%d", i),
+			Dimensions: map[string]string{fmt.Sprintf("d%d", i): fmt.Sprintf("v%d", i)},
+		})
+	}
+
+	report = h.Report()
+	keys := len(report.Pages) + len(report.Referrers)
+
+	for _, counts := range report.Dimensions {
+		keys += 1 + len(counts)
+	}
+
+	if keys > maxAnalyticsKeys {
+		t.Errorf("Expected at most %d keys in the report. Got: %d", maxAnalyticsKeys, keys)
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// updateAPI rewrites the recorded exported API with the current one.
+var updateAPI = flag.Bool("update-api", false, "update testdata/api.txt")
+
+// apiPath is the path of the file recording the package's exported API.
+var apiPath string = filepath.FromSlash("testdata/api.txt")
+
+// Test that no part of the exported API recorded in testdata/api.txt has been
+// removed or changed. Additions are allowed. After an intended change to the
+// API run go test -run TestAPI -update-api to record it.
+func TestAPI(t *testing.T) {
+
+	current, err := exportedAPI(".")
+
+	if err != nil {
+		t.Fatalf("Failed to read the exported API: %s", err)
+	}
+
+	if *updateAPI {
+
+		contents := strings.Join(current, "\n") + "\n"
+
+		if err := os.WriteFile(apiPath, []byte(contents), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", apiPath, err)
+		}
+
+		return
+	}
+
+	contents, err := os.ReadFile(apiPath)
+
+	if err != nil {
+		t.Fatalf("Failed to read %s: %s", apiPath, err)
+	}
+
+	exported := make(map[string]bool, len(current))
+
+	for _, line := range current {
+		exported[line] = true
+	}
+
+	// Check every recorded declaration is still exported unchanged
+	for _, line := range strings.Split(strings.TrimSpace(string(contents)), "\n") {
+
+		if !exported[line] {
+			t.Errorf("Exported API removed or changed: %s", line)
+		}
+	}
+}
+
+// exportedAPI returns a sorted description of the exported declarations of
+// the package in directory, one per line. Parameter names are left out of
+// function signatures, since renaming a parameter does not break callers.
+func exportedAPI(directory string) ([]string, error) {
+
+	fset := token.NewFileSet()
+
+	packages, err := parser.ParseDir(fset, directory, func(finfo os.FileInfo) bool {
+		return !strings.HasSuffix(finfo.Name(), "_test.go")
+	}, 0)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+
+	for _, pkg := range packages {
+
+		for _, file := range pkg.Files {
+
+			for _, decl := range file.Decls {
+				lines = append(lines, apiLines(fset, decl)...)
+			}
+		}
+	}
+
+	sort.Strings(lines)
+	return lines, nil
+}
+
+// apiLines returns the description of the exported parts of a declaration.
+func apiLines(fset *token.FileSet, decl ast.Decl) []string {
+
+	var lines []string
+
+	switch decl := decl.(type) {
+
+	case *ast.FuncDecl:
+
+		if !decl.Name.IsExported() {
+			break
+		}
+
+		name := "func " + decl.Name.Name
+
+		if decl.Recv != nil {
+
+			receiver := apiNode(fset, decl.Recv.List[0].Type)
+
+			if !ast.IsExported(strings.TrimPrefix(receiver, "*")) {
+				break
+			}
+
+			name = "method (" + receiver + ") " + decl.Name.Name
+		}
+
+		lines = append(lines, name+apiSignature(fset, decl.Type))
+
+	case *ast.GenDecl:
+
+		for _, spec := range decl.Specs {
+
+			switch spec := spec.(type) {
+
+			case *ast.TypeSpec:
+
+				if !spec.Name.IsExported() {
+					continue
+				}
+
+				lines = append(lines, apiTypeLines(fset, spec)...)
+
+			case *ast.ValueSpec:
+
+				for _, name := range spec.Names {
+
+					if !name.IsExported() {
+						continue
+					}
+
+					line := decl.Tok.String() + " " + name.Name
+
+					if spec.Type != nil {
+						line += " " + apiNode(fset, spec.Type)
+					}
+
+					lines = append(lines, line)
+				}
+			}
+		}
+	}
+
+	return lines
+}
+
+// apiTypeLines returns the description of an exported type and of its
+// exported fields or interface methods.
+func apiTypeLines(fset *token.FileSet, spec *ast.TypeSpec) []string {
+
+	name := "type " + spec.Name.Name
+
+	switch typ := spec.Type.(type) {
+
+	case *ast.StructType:
+
+		lines := []string{name + " struct"}
+
+		for _, field := range typ.Fields.List {
+
+			for _, fieldName := range field.Names {
+
+				if fieldName.IsExported() {
+					lines = append(lines, name+", "+fieldName.Name+" "+apiNode(fset, field.Type))
+				}
+			}
+		}
+
+		return lines
+
+	case *ast.InterfaceType:
+
+		lines := []string{name + " interface"}
+
+		for _, method := range typ.Methods.List {
+
+			if funcType, ok := method.Type.(*ast.FuncType); ok {
+				lines = append(lines, name+", "+method.Names[0].Name+apiSignature(fset, funcType))
+			}
+		}
+
+		return lines
+
+	case *ast.FuncType:
+
+		return []string{name + " func" + apiSignature(fset, typ)}
+	}
+
+	return []string{name + " " + apiNode(fset, spec.Type)}
+}
+
+// apiSignature returns a function's parameter and result types.
+func apiSignature(fset *token.FileSet, funcType *ast.FuncType) string {
+
+	signature := "(" + apiFieldTypes(fset, funcType.Params) + ")"
+
+	if results := funcType.Results; results != nil {
+
+		if len(results.List) == 1 && len(results.List[0].Names) <= 1 {
+			signature += " " + apiFieldTypes(fset, results)
+		} else {
+			signature += " (" + apiFieldTypes(fset, results) + ")"
+		}
+	}
+
+	return signature
+}
+
+// apiFieldTypes returns the types in a field list, repeated for each name.
+func apiFieldTypes(fset *token.FileSet, fields *ast.FieldList) string {
+
+	var types []string
+
+	for _, field := range fields.List {
+
+		count := len(field.Names)
+
+		if count == 0 {
+			count = 1
+		}
+
+		for i := 0; i < count; i++ {
+			types = append(types, apiNode(fset, field.Type))
+		}
+	}
+
+	return strings.Join(types, ", ")
+}
+
+// apiNode returns the source of a node.
+func apiNode(fset *token.FileSet, node ast.Node) string {
+
+	var buffer bytes.Buffer
+	printer.Fprint(&buffer, fset, node)
+	return buffer.String()
+}
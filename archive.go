@@ -0,0 +1,438 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveFileSystem is a read-only http.FileSystem that serves files directly
+// out of a zip or uncompressed tar archive, so a whole site can be shipped as a
+// single file and mounted with NewFileSystemHandler without unpacking it. The
+// archive is indexed when it is opened. Files stored without compression are
+// read from the archive with range reads, while compressed zip entries are
+// decompressed as they are read, so that large files are never held in
+// memory. Seeking backwards in a compressed entry starts decompressing it
+// again from the beginning.
+type ArchiveFileSystem struct {
+	file    *os.File
+	entries map[string]*archiveEntry
+}
+
+// archiveEntry describes a file or directory in an archive.
+type archiveEntry struct {
+	name     string
+	size     int64
+	modTime  time.Time
+	isDir    bool
+	offset   int64
+	zipFile  *zip.File
+	children []string
+}
+
+// OpenArchive opens the zip or tar archive at archivePath and returns an
+// ArchiveFileSystem for its contents. The type of the archive is determined
+// from its extension, which must be ".zip" or ".tar". The archive should be
+// closed with Close when it is no longer needed.
+func OpenArchive(archivePath string) (*ArchiveFileSystem, error) {
+
+	file, err := os.Open(archivePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	fs := &ArchiveFileSystem{
+		file: file,
+		entries: map[string]*archiveEntry{
+			"/": {name: "/", isDir: true},
+		},
+	}
+
+	switch strings.ToLower(path.Ext(archivePath)) {
+
+	case ".zip":
+		err = fs.indexZip()
+
+	case ".tar":
+		err = fs.indexTar()
+
+	default:
+		err = fmt.Errorf("handlers: unsupported archive type %s", archivePath)
+	}
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Close closes the archive file.
+func (fs *ArchiveFileSystem) Close() error {
+
+	return fs.file.Close()
+}
+
+// Open opens the named file in the archive.
+func (fs *ArchiveFileSystem) Open(name string) (http.File, error) {
+
+	entry, ok := fs.entries[path.Clean("/"+name)]
+
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+
+	file := &archiveFile{fs: fs, entry: entry}
+
+	if entry.isDir {
+		file.reader = bytes.NewReader(nil)
+		return file, nil
+	}
+
+	// Entries stored without compression are read in place
+	if entry.zipFile == nil || entry.zipFile.Method == zip.Store {
+		file.reader = io.NewSectionReader(fs.file, entry.offset, entry.size)
+		return file, nil
+	}
+
+	// Compressed entries are decompressed as they are read
+	file.reader = &inflatingReader{zipFile: entry.zipFile, size: entry.size}
+	return file, nil
+}
+
+// indexZip adds the entries in a zip archive to the index.
+func (fs *ArchiveFileSystem) indexZip() error {
+
+	finfo, err := fs.file.Stat()
+
+	if err != nil {
+		return err
+	}
+
+	reader, err := zip.NewReader(fs.file, finfo.Size())
+
+	if err != nil {
+		return err
+	}
+
+	for _, zipFile := range reader.File {
+
+		if strings.HasSuffix(zipFile.Name, "/") {
+			fs.addDirectory(path.Clean("/" + zipFile.Name))
+			continue
+		}
+
+		offset, err := zipFile.DataOffset()
+
+		if err != nil {
+			return err
+		}
+
+		fs.addFile(&archiveEntry{
+			name:    path.Clean("/" + zipFile.Name),
+			size:    int64(zipFile.UncompressedSize64),
+			modTime: zipFile.Modified,
+			offset:  offset,
+			zipFile: zipFile,
+		})
+	}
+
+	return nil
+}
+
+// indexTar adds the entries in a tar archive to the index, recording the
+// offset of each regular file's data in the archive.
+func (fs *ArchiveFileSystem) indexTar() error {
+
+	counter := &countingReader{reader: fs.file}
+	reader := tar.NewReader(counter)
+
+	for {
+
+		header, err := reader.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+
+		case tar.TypeDir:
+
+			fs.addDirectory(path.Clean("/" + header.Name))
+
+		case tar.TypeReg:
+
+			fs.addFile(&archiveEntry{
+				name:    path.Clean("/" + header.Name),
+				size:    header.Size,
+				modTime: header.ModTime,
+				offset:  counter.count,
+			})
+		}
+	}
+}
+
+// addFile adds a file entry and its parent directories to the index.
+func (fs *ArchiveFileSystem) addFile(entry *archiveEntry) {
+
+	if _, ok := fs.entries[entry.name]; ok {
+		return
+	}
+
+	fs.entries[entry.name] = entry
+	fs.addChild(entry.name)
+}
+
+// addDirectory adds a directory entry and its parents to the index.
+func (fs *ArchiveFileSystem) addDirectory(name string) {
+
+	if _, ok := fs.entries[name]; ok {
+		return
+	}
+
+	fs.entries[name] = &archiveEntry{name: name, isDir: true}
+	fs.addChild(name)
+}
+
+// addChild records the named entry as a child of its parent directory.
+func (fs *ArchiveFileSystem) addChild(name string) {
+
+	parent := path.Dir(name)
+	fs.addDirectory(parent)
+	fs.entries[parent].children = append(fs.entries[parent].children, name)
+}
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+// Read reads from the underlying reader and adds the bytes read to the count.
+func (r *countingReader) Read(p []byte) (int, error) {
+
+	n, err := r.reader.Read(p)
+	r.count += int64(n)
+	return n, err
+}
+
+// archiveFile is an open file or directory in an archive. For directories,
+// listed is the number of entries already returned by Readdir.
+type archiveFile struct {
+	fs     *ArchiveFileSystem
+	entry  *archiveEntry
+	reader io.ReadSeeker
+	listed int
+}
+
+// Read reads the entry's contents, which are decompressed as they are read
+// if the entry is compressed.
+func (f *archiveFile) Read(p []byte) (int, error) {
+
+	return f.reader.Read(p)
+}
+
+// Seek sets the offset of the next Read within the entry's contents.
+func (f *archiveFile) Seek(offset int64, whence int) (int64, error) {
+
+	return f.reader.Seek(offset, whence)
+}
+
+// Close releases the decompressor of a compressed entry, if one is open.
+// The archive itself stays open for other files.
+func (f *archiveFile) Close() error {
+
+	if closer, ok := f.reader.(io.Closer); ok {
+		return closer.Close()
+	}
+
+	return nil
+}
+
+// Stat returns the entry's name, size, mode and modification time.
+func (f *archiveFile) Stat() (fs.FileInfo, error) {
+
+	return archiveFileInfo{f.entry}, nil
+}
+
+// Readdir returns the entries in the directory in name order. As with
+// os.File, if count is greater than zero at most count entries are returned,
+// continuing from the previous call, and io.EOF is returned when there are
+// none left. Otherwise all of the remaining entries are returned.
+func (f *archiveFile) Readdir(count int) ([]fs.FileInfo, error) {
+
+	if !f.entry.isDir {
+		return nil, fmt.Errorf("handlers: %s is not a directory", f.entry.name)
+	}
+
+	children := append([]string(nil), f.entry.children...)
+	sort.Strings(children)
+
+	if f.listed < len(children) {
+		children = children[f.listed:]
+	} else {
+		children = nil
+	}
+
+	if count > 0 && len(children) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > 0 && len(children) > count {
+		children = children[:count]
+	}
+
+	f.listed += len(children)
+
+	infos := make([]fs.FileInfo, len(children))
+
+	for i, name := range children {
+		infos[i] = archiveFileInfo{f.fs.entries[name]}
+	}
+
+	return infos, nil
+}
+
+// inflatingReader reads a compressed zip entry, decompressing it as it is
+// read. Seeking only moves the offset; the next read skips forward to it, or
+// starts again from the beginning if it is behind the data already read.
+type inflatingReader struct {
+	zipFile  *zip.File
+	size     int64
+	offset   int64
+	reader   io.ReadCloser
+	position int64
+}
+
+// Read decompresses the entry from the current offset, reopening it first if
+// a Seek moved the offset back before the data already read.
+func (r *inflatingReader) Read(p []byte) (int, error) {
+
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.reader == nil || r.position > r.offset {
+
+		r.Close()
+		reader, err := r.zipFile.Open()
+
+		if err != nil {
+			return 0, err
+		}
+
+		r.reader, r.position = reader, 0
+	}
+
+	if r.position < r.offset {
+
+		skipped, err := io.CopyN(io.Discard, r.reader, r.offset-r.position)
+		r.position += skipped
+
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.reader.Read(p)
+	r.position += int64(n)
+	r.offset += int64(n)
+	return n, err
+}
+
+// Seek moves the offset of the next Read without decompressing anything. An
+// error is returned for offsets before the start of the entry.
+func (r *inflatingReader) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+
+	case io.SeekCurrent:
+		offset += r.offset
+
+	case io.SeekEnd:
+		offset += r.size
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("handlers: invalid seek to %d", offset)
+	}
+
+	r.offset = offset
+	return offset, nil
+}
+
+// Close closes the decompressor, if one is open. A later Read opens a new
+// one.
+func (r *inflatingReader) Close() error {
+
+	if r.reader == nil {
+		return nil
+	}
+
+	err := r.reader.Close()
+	r.reader = nil
+	return err
+}
+
+// archiveFileInfo implements fs.FileInfo for an archive entry.
+type archiveFileInfo struct {
+	entry *archiveEntry
+}
+
+// Name returns the base name of the entry.
+func (i archiveFileInfo) Name() string {
+
+	return path.Base(i.entry.name)
+}
+
+// Size returns the uncompressed size of the entry.
+func (i archiveFileInfo) Size() int64 {
+
+	return i.entry.size
+}
+
+// Mode returns read-only permissions, with the directory bit set for
+// directories, as archives are never written to.
+func (i archiveFileInfo) Mode() fs.FileMode {
+
+	if i.entry.isDir {
+		return fs.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// ModTime returns the modification time recorded for the entry in the
+// archive.
+func (i archiveFileInfo) ModTime() time.Time {
+
+	return i.entry.modTime
+}
+
+// IsDir reports whether the entry is a directory.
+func (i archiveFileInfo) IsDir() bool {
+
+	return i.entry.isDir
+}
+
+// Sys returns nil, as archive entries have no underlying data source.
+func (i archiveFileInfo) Sys() interface{} {
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test ArchiveFileSystem with zip and tar archives
+func TestArchiveFileSystem(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		fs         *ArchiveFileSystem
+		archive    *os.File
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+		err        error
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+
+	// Write a zip archive with a stored and a compressed file
+	zipPath := filepath.Join(t.TempDir(), "site.zip")
+	archive, _ = os.Create(zipPath)
+	zipWriter := zip.NewWriter(archive)
+	entry, _ := zipWriter.CreateHeader(&zip.FileHeader{Name: "index.html", Method: zip.Store})
+	io.WriteString(entry, "Zip index")
+	entry, _ = zipWriter.Create("sub/page.html")
+	io.WriteString(entry, "Zip page")
+	zipWriter.Close()
+	archive.Close()
+
+	// Write a tar archive with a file in a subdirectory
+	tarPath := filepath.Join(t.TempDir(), "site.tar")
+	archive, _ = os.Create(tarPath)
+	tarWriter := tar.NewWriter(archive)
+	tarWriter.WriteHeader(&tar.Header{Name: "sub/page.html", Mode: 0644, Size: 8})
+	io.WriteString(tarWriter, "Tar page")
+	tarWriter.Close()
+	archive.Close()
+
+	// Test each archive through a FileHandler
+	for _, test := range []struct {
+		archivePath string
+		requestPath string
+		rangeHeader string
+		status      int
+		body        string
+	}{
+		{zipPath, "/site/", "", http.StatusOK, "Zip index"},
+		{zipPath, "/site/sub/page.html", "", http.StatusOK, "Zip page"},
+		{zipPath, "/site/sub/page.html", "bytes=4-", http.StatusPartialContent, "page"},
+		{zipPath, "/site/sub/page.html", "bytes=0-2", http.StatusPartialContent, "Zip"},
+		{zipPath, "/site/", "bytes=4-", http.StatusPartialContent, "index"},
+		{zipPath, "/site/sub", "", http.StatusFound, ""},
+		{zipPath, "/site/missing.html", "", http.StatusNotFound, "Not Found: /site/missing.html"},
+		{tarPath, "/site/sub/page.html", "", http.StatusOK, "Tar page"},
+		{tarPath, "/site/sub/page.html", "bytes=4-", http.StatusPartialContent, "page"},
+		{tarPath, "/site/sub/", "", http.StatusNotFound, "Not Found: /site/sub/"},
+	} {
+
+		fs, err = OpenArchive(test.archivePath)
+
+		if err != nil {
+			t.Fatalf("Expected no error from OpenArchive. Got: %s", err)
+		}
+
+		h = NewFileSystemHandler("/site/", fs, nfh)
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.requestPath, nil)
+
+		if test.rangeHeader != "" {
+			request.Header.Set("Range", test.rangeHeader)
+		}
+
+		h.ServeHTTP(response, request)
+		fs.Close()
+
+		// Check the status code and body
+		bodyString = response.Body.String()
+
+		if response.Code != test.status || bodyString != test.body {
+			t.Errorf("Expected %d \"%s\" from %s for %s. Got: %d %s", test.status,
+				test.body, test.archivePath, test.requestPath, response.Code, bodyString)
+		}
+	}
+
+	// Check a compressed entry can be read after seeking in either direction
+	fs, err = OpenArchive(zipPath)
+
+	if err != nil {
+		t.Fatalf("Expected no error from OpenArchive. Got: %s", err)
+	}
+
+	defer fs.Close()
+
+	file, err := fs.Open("/sub/page.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error opening a compressed entry. Got: %s", err)
+	}
+
+	file.Seek(4, io.SeekStart)
+	tail, _ := io.ReadAll(file)
+	file.Seek(0, io.SeekStart)
+	head := make([]byte, 3)
+	io.ReadFull(file, head)
+	file.Close()
+
+	if string(tail) != "page" || string(head) != "Zip" {
+		t.Errorf("Expected \"page\" and \"Zip\" after seeking. Got: %q and %q", tail, head)
+	}
+
+	// Check Readdir pages through a directory and then returns io.EOF
+	dir, err := fs.Open("/")
+
+	if err != nil {
+		t.Fatalf("Expected no error opening the root directory. Got: %s", err)
+	}
+
+	defer dir.Close()
+
+	var names []string
+
+	for i := 0; i < 3; i++ {
+
+		infos, err := dir.Readdir(1)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil || len(infos) != 1 {
+			t.Fatalf("Expected one entry from Readdir(1). Got: %d %v", len(infos), err)
+		}
+
+		names = append(names, infos[0].Name())
+	}
+
+	if len(names) != 2 || names[0] != "index.html" || names[1] != "sub" {
+		t.Errorf("Expected index.html and sub from Readdir. Got: %v", names)
+	}
+
+	if infos, err := dir.Readdir(-1); len(infos) != 0 || err != nil {
+		t.Errorf("Expected no more entries and no error from Readdir(-1). Got: %d %v", len(infos), err)
+	}
+}
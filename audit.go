@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// auditMarker is the prefix of the values substituted for request-derived
+// fields when a template is audited. It only contains characters that none of
+// html/template's escapers change, so it can be found in the output whatever
+// the context in which it was printed.
+const auditMarker string = "handlersAuditMarker"
+
+// AuditErrorTemplate checks that an error template only prints
+// {{.ErrorMessage}} and {{.Path}} in an HTML text context. Error messages
+// often contain request-derived values, and while html/template escapes them
+// for the context in which they appear, printing them inside a tag attribute,
+// a script or a style element is a common source of reflected XSS. The
+// function is intended for use in development and tests, or at startup before
+// the handler is created, and returns an error describing the first unsafe use
+// it finds.
+func AuditErrorTemplate(t *template.Template) error {
+
+	fields := []string{".ErrorMessage", ".Path"}
+	data := &ErrorMessage{ErrorMessage: auditValue(0), Path: auditValue(1)}
+
+	return auditTemplate(t, data, fields)
+}
+
+// AuditNotFoundTemplate checks that a not found template only prints {{.Path}}
+// and the values of the named query parameters in an HTML text context. See
+// AuditErrorTemplate for details.
+func AuditNotFoundTemplate(t *template.Template, queryParams ...string) error {
+
+	fields := []string{".Path"}
+	data := &NotFoundData{
+		Path:  auditValue(0),
+		Query: make(map[string]string),
+	}
+
+	for _, name := range queryParams {
+		data.Query[name] = auditValue(len(fields))
+		fields = append(fields, ".Query."+name)
+	}
+
+	return auditTemplate(t, data, fields)
+}
+
+// auditValue returns the marker value for the field with the given index.
+func auditValue(index int) string {
+
+	return fmt.Sprintf("%s%dX", auditMarker, index)
+}
+
+// auditTemplate executes the template with the given marker data and returns
+// an error if any of the markers appear outside of an HTML text context.
+func auditTemplate(t *template.Template, data interface{}, fields []string) error {
+
+	var buffer bytes.Buffer
+
+	err := t.Execute(&buffer, data)
+
+	if err != nil {
+		return fmt.Errorf("handlers: audit of template %q failed: %s", t.Name(), err)
+	}
+
+	output := buffer.String()
+
+	for index, field := range fields {
+
+		marker := auditValue(index)
+		offset := 0
+
+		for {
+
+			position := strings.Index(output[offset:], marker)
+
+			if position < 0 {
+				break
+			}
+
+			offset += position
+
+			if context := auditContext(output[:offset]); context != "" {
+				return fmt.Errorf("handlers: template %q prints request-derived "+
+					"value %s in %s context", t.Name(), field, context)
+			}
+
+			offset += len(marker)
+		}
+	}
+
+	return nil
+}
+
+// auditContext returns a description of the unsafe context at the end of the
+// given output, or an empty string if the output ends in an HTML text context.
+func auditContext(prefix string) string {
+
+	prefix = strings.ToLower(prefix)
+
+	if strings.LastIndex(prefix, "<") > strings.LastIndex(prefix, ">") {
+		return "a tag or attribute"
+	}
+
+	if strings.LastIndex(prefix, "<script") > strings.LastIndex(prefix, "</script") {
+		return "a script"
+	}
+
+	if strings.LastIndex(prefix, "<style") > strings.LastIndex(prefix, "</style") {
+		return "a style"
+	}
+
+	return ""
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"html/template"
+	"path/filepath"
+	"testing"
+)
+
+// Test AuditErrorTemplate and AuditNotFoundTemplate
+func TestAuditTemplates(t *testing.T) {
+
+	var (
+		tmpl *template.Template
+		err  error
+	)
+
+	// Check the example templates pass the audit
+	tmpl = template.Must(template.ParseFiles(filepath.FromSlash("templates/error.html")))
+
+	if err = AuditErrorTemplate(tmpl); err != nil {
+		t.Errorf("Expected no error from AuditErrorTemplate. Got: %s", err)
+	}
+
+	tmpl = template.Must(template.ParseFiles(filepath.FromSlash("templates/notfound.html")))
+
+	if err = AuditNotFoundTemplate(tmpl); err != nil {
+		t.Errorf("Expected no error from AuditNotFoundTemplate. Got: %s", err)
+	}
+
+	// Check a message printed in an attribute fails the audit
+	tmpl = template.Must(template.New("attr").Parse(
+		"<p title=\"{{.ErrorMessage}}\">Error</p>"))
+
+	if err = AuditErrorTemplate(tmpl); err == nil {
+		t.Errorf("Expected an error from AuditErrorTemplate for an attribute context")
+	}
+
+	// Check a message printed in a script fails the audit
+	tmpl = template.Must(template.New("script").Parse(
+		"<p>Error</p><script>var message = {{.ErrorMessage}};</script>"))
+
+	if err = AuditErrorTemplate(tmpl); err == nil {
+		t.Errorf("Expected an error from AuditErrorTemplate for a script context")
+	}
+
+	// Check a request path printed in a link fails the audit
+	tmpl = template.Must(template.New("path").Parse(
+		"<p>{{.ErrorMessage}}</p><a href=\"{{.Path}}\">Retry</a>"))
+
+	if err = AuditErrorTemplate(tmpl); err == nil {
+		t.Errorf("Expected an error from AuditErrorTemplate for a path in a URL context")
+	}
+
+	// Check a query parameter printed in a link fails the audit
+	tmpl = template.Must(template.New("query").Parse(
+		"<p>{{.Path}}</p><a href=\"/search?q={{.Query.q}}\">Search</a>"))
+
+	if err = AuditNotFoundTemplate(tmpl, "q"); err == nil {
+		t.Errorf("Expected an error from AuditNotFoundTemplate for a URL context")
+	}
+}
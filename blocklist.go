@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// DefaultBlockedExtensions returns the extensions blocked when a FileHandler
+// is given a nil blocklist: server-side scripts, executables and the
+// configuration and secret files that are often synced by accident.
+func DefaultBlockedExtensions() []string {
+
+	return []string{
+		".php", ".php3", ".php4", ".php5", ".phtml", ".phar", ".cgi", ".pl",
+		".py", ".rb", ".asp", ".aspx", ".jsp", ".jspx", ".cfm", ".shtml",
+		".exe", ".dll", ".so", ".bat", ".cmd", ".ps1", ".sh",
+		".htaccess", ".htpasswd", ".env", ".ini", ".key", ".pem",
+	}
+}
+
+// SetBlockedExtensions sets extensions, such as ".php", of files the handler
+// must never serve as they are, protecting against server-side scripts and
+// other files synced into the content by accident being exposed. Extensions
+// are matched without regard to case. If download is false requests for
+// these files get a 404, as if they did not exist. If download is true they
+// are served only as downloads, with the type application/octet-stream and
+// an attachment disposition, so that the browser never runs or renders them.
+// A nil list uses DefaultBlockedExtensions, and an empty list turns blocking
+// off, which is the default.
+func (h *FileHandler) SetBlockedExtensions(extensions []string, download bool) {
+
+	if extensions == nil {
+		extensions = DefaultBlockedExtensions()
+	}
+
+	h.blocked = make(map[string]bool, len(extensions))
+
+	for _, extension := range extensions {
+		h.blocked[strings.ToLower(extension)] = true
+	}
+
+	h.downloadBlocked = download
+}
+
+// isBlocked reports whether the file at filePath has a blocked extension.
+func (h *FileHandler) isBlocked(filePath string) bool {
+
+	return h.blocked[strings.ToLower(path.Ext(filePath))]
+}
+
+// setDownloadHeaders marks the response as a download of the named file that
+// the browser must not interpret.
+func setDownloadHeaders(w http.ResponseWriter, name string) {
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	value := DispositionAttachment
+
+	if filename := sanitizeFilename(name); filename != "" {
+
+		if formatted := mime.FormatMediaType(value, map[string]string{"filename": filename}); formatted != "" {
+			value = formatted
+		}
+	}
+
+	w.Header().Set("Content-Disposition", value)
+}
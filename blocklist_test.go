@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler extension blocklists
+func TestBlockedExtensions(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.php":   {Data: []byte("<?php echo 'secret'; ?>")},
+		"setup.EXE":   {Data: []byte("EXE")},
+		".env":        {Data: []byte("PASSWORD=secret")},
+		"page.html":   {Data: []byte("<p>Page</p>")},
+		"scripts.cgi": {Data: []byte("#!/bin/sh")},
+	}), nfh)
+
+	// Check files are served by default
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/index.php", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK for /index.php by default. Got: %d", response.Code)
+	}
+
+	h.SetBlockedExtensions(nil, false)
+
+	tests := []struct {
+		path   string
+		status int
+	}{
+		{"/index.php", http.StatusNotFound},
+		{"/setup.EXE", http.StatusNotFound},
+		{"/.env", http.StatusNotFound},
+		{"/scripts.cgi", http.StatusNotFound},
+		{"/page.html", http.StatusOK},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the file
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check blocked files are not found
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+	}
+
+	// Check blocked files are served as downloads when asked
+	h.SetBlockedExtensions([]string{".PHP"}, true)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/index.php", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK for a blocked download. Got: %d", response.Code)
+	}
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/octet-stream" {
+		t.Errorf("Expected application/octet-stream for a blocked download. Got: %s", contentType)
+	}
+
+	if disposition := response.Header().Get("Content-Disposition"); disposition != "attachment; filename=index.php" {
+		t.Errorf("Expected an attachment for a blocked download. Got: %s", disposition)
+	}
+
+	// Check only the listed extensions are blocked
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/setup.EXE", nil)
+	h.ServeHTTP(response, request)
+
+	if disposition := response.Header().Get("Content-Disposition"); response.Code != http.StatusOK || disposition != "" {
+		t.Errorf("Expected /setup.EXE to be served normally. Got: %d %s", response.Code, disposition)
+	}
+
+	// Check an empty list turns blocking off
+	h.SetBlockedExtensions([]string{}, false)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/.env", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK with blocking off. Got: %d", response.Code)
+	}
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"regexp"
+	"strings"
+)
+
+// Branding holds the details of a site shown by the built-in templates, so
+// that simple branding does not require custom templates. SiteName is shown in
+// the page header and title. The logo is shown in the header, either from
+// LogoURL or, if Logo is not empty, from the image data in Logo with the media
+// type LogoType, such as "image/png". AccentColor is a CSS color used by
+// themes that support it, such as the branded theme. FooterLinks are shown in
+// the page footer.
+type Branding struct {
+	SiteName    string
+	LogoURL     string
+	Logo        []byte
+	LogoType    string
+	AccentColor string
+	FooterLinks []BrandingLink
+}
+
+// BrandingLink is a link shown in the footer of the built-in templates.
+type BrandingLink struct {
+	Text string
+	URL  string
+}
+
+// brandingData holds branding in the form used by the built-in templates.
+// LogoSrc is a template.URL for a data URI built from the logo bytes, which
+// html/template would otherwise reject, and a plain string for a logo url, so
+// that html/template still filters unsafe schemes such as javascript:.
+type brandingData struct {
+	SiteName    string
+	LogoSrc     interface{}
+	AccentColor template.CSS
+	FooterLinks []BrandingLink
+}
+
+// accentColorPattern matches the CSS colors accepted as an accent color:
+// hex colors, named colors, and rgb or hsl functions.
+var accentColorPattern = regexp.MustCompile(
+	`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|rgba|hsl|hsla)\([0-9., %]+\))$`)
+
+// BrandedErrorTemplate returns the built-in error page template styled with
+// the named theme and showing the given branding.
+func BrandedErrorTemplate(theme string, branding Branding) (*template.Template, error) {
+
+	return themedTemplate("error", defaultErrorPage, theme, branding)
+}
+
+// BrandedNotFoundTemplate returns the built-in 404 page template styled with
+// the named theme and showing the given branding.
+func BrandedNotFoundTemplate(theme string, branding Branding) (*template.Template, error) {
+
+	return themedTemplate("notfound", defaultNotFoundPage, theme, branding)
+}
+
+// templateData validates the branding and converts it for use in templates.
+// The logo and accent color are checked here so they can be marked as safe
+// for the url and CSS contexts in which the templates print them.
+func (b Branding) templateData() (*brandingData, error) {
+
+	data := &brandingData{
+		SiteName:    b.SiteName,
+		FooterLinks: b.FooterLinks,
+	}
+
+	if b.AccentColor != "" {
+
+		if !accentColorPattern.MatchString(b.AccentColor) {
+			return nil, fmt.Errorf("handlers: invalid accent color %q", b.AccentColor)
+		}
+
+		data.AccentColor = template.CSS(b.AccentColor)
+	}
+
+	switch {
+
+	case len(b.Logo) > 0:
+
+		if !strings.HasPrefix(b.LogoType, "image/") || strings.ContainsAny(b.LogoType, ";,") {
+			return nil, fmt.Errorf("handlers: invalid logo type %q", b.LogoType)
+		}
+
+		data.LogoSrc = template.URL("data:" + b.LogoType + ";base64," +
+			base64.StdEncoding.EncodeToString(b.Logo))
+
+	case b.LogoURL != "":
+		data.LogoSrc = b.LogoURL
+	}
+
+	return data, nil
+}
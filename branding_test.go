@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the branded templates
+func TestBranding(t *testing.T) {
+
+	var (
+		eh         *ErrorHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	branding := Branding{
+		SiteName:    "Example Site",
+		Logo:        []byte{0x89, 0x50, 0x4e, 0x47},
+		LogoType:    "image/png",
+		AccentColor: "#ff6600",
+		FooterLinks: []BrandingLink{
+			{Text: "Home", URL: "/"},
+			{Text: "Status", URL: "https://status.example.com/"},
+		},
+	}
+
+	tmpl, err := BrandedErrorTemplate(ThemeBranded, branding)
+
+	if err != nil {
+		t.Fatalf("Expected no error from BrandedErrorTemplate. Got: %s", err)
+	}
+
+	eh = NewErrorHandler(tmpl, WithDefaultMessage("Branded error"), WithDisplayErrors(true))
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	eh.ServeHTTP(response, request)
+
+	// Check the page contains each part of the branding
+	bodyString = response.Body.String()
+
+	expected := []string{
+		"<title>Error - Example Site</title>",
+		":root { --accent: #ff6600; }",
+		`<img src="data:image/png;base64,iVBORw==" alt="">`,
+		"Example Site</header>",
+		`<a href="/">Home</a>`,
+		`<a href="https://status.example.com/">Status</a>`,
+		"<p>Branded error</p>",
+	}
+
+	for _, s := range expected {
+
+		if !strings.Contains(bodyString, s) {
+			t.Errorf("Expected %q in the page. Got: %s", s, bodyString)
+		}
+	}
+
+	// Check a page without branding has no header or footer
+	tmpl, _ = BrandedNotFoundTemplate(ThemePlain, Branding{})
+	response = httptest.NewRecorder()
+	NewNotFoundHandler(tmpl).ServeHTTP(response, request)
+	bodyString = response.Body.String()
+
+	if strings.Contains(bodyString, "<header>") || strings.Contains(bodyString, "<footer>") {
+		t.Errorf("Expected no header or footer without branding. Got: %s", bodyString)
+	}
+
+	// Check an unsafe logo url is filtered
+	tmpl, _ = BrandedNotFoundTemplate(ThemePlain, Branding{LogoURL: "javascript:alert(1)"})
+	response = httptest.NewRecorder()
+	NewNotFoundHandler(tmpl).ServeHTTP(response, request)
+	bodyString = response.Body.String()
+
+	if strings.Contains(bodyString, "javascript:") {
+		t.Errorf("Expected the unsafe logo url to be filtered. Got: %s", bodyString)
+	}
+
+	// Check invalid accent colors and logo types are rejected
+	invalid := []Branding{
+		{AccentColor: "red; } body { display: none"},
+		{AccentColor: "url(https://example.com/)"},
+		{Logo: []byte("<svg/>"), LogoType: "text/html"},
+		{Logo: []byte("<svg/>"), LogoType: "image/svg+xml;charset=utf-8"},
+	}
+
+	for _, b := range invalid {
+
+		if _, err := BrandedErrorTemplate(ThemePlain, b); err == nil {
+			t.Errorf("Expected an error from BrandedErrorTemplate for %+v", b)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// maxBrokenLinks is the maximum number of pairs of missing path and referring
+// page a NotFoundHandler remembers having reported. When it is reached the
+// oldest pair is forgotten, and will be reported again if it recurs.
+const maxBrokenLinks int = 10000
+
+// BrokenLink describes a link from a page of a site to a missing path. As the
+// referring page is taken from the Referer header, which clients control, it
+// should be treated as a hint rather than trusted.
+type BrokenLink struct {
+	Path     string    `json:"path"`
+	Referrer string    `json:"referrer"`
+	Time     time.Time `json:"time"`
+}
+
+// brokenLinkReporter reports each broken link once. The links reported are
+// kept in the order they were first seen, so that the oldest can be forgotten.
+type brokenLinkReporter struct {
+	report   func(link BrokenLink)
+	mutex    sync.Mutex
+	reported map[BrokenLink]bool
+	order    []BrokenLink
+}
+
+// SetBrokenLinkReporter sets a function that is called when the handler
+// serves a 404 for a request whose Referer is a page on the same host, which
+// means a page of the site links to a missing path. Each pair of missing path
+// and referring page is reported once, so a popular page with a broken link
+// does not report it on every view. The number of pairs remembered is limited,
+// and the oldest are forgotten first. BrokenLinkLogger and BrokenLinkWebhook
+// return reporters for the common cases. Passing nil turns reporting off.
+func (h *NotFoundHandler) SetBrokenLinkReporter(report func(link BrokenLink)) {
+
+	if report == nil {
+		h.brokenLinks = nil
+		return
+	}
+
+	h.brokenLinks = &brokenLinkReporter{
+		report:   report,
+		reported: make(map[BrokenLink]bool),
+	}
+}
+
+// SetClock sets the clock used for the time of each broken link reported. By
+// default the handler uses SystemClock.
+func (h *NotFoundHandler) SetClock(clock Clock) {
+
+	h.clock = clock
+}
+
+// BrokenLinkLogger returns a broken link reporter that writes a warning to
+// logger, or to the standard logger if logger is nil.
+func BrokenLinkLogger(logger *log.Logger) func(link BrokenLink) {
+
+	return func(link BrokenLink) {
+
+		if logger == nil {
+			log.Printf("handlers: broken link to %s on %s", link.Path, link.Referrer)
+			return
+		}
+
+		logger.Printf("handlers: broken link to %s on %s", link.Path, link.Referrer)
+	}
+}
+
+// BrokenLinkWebhook returns a broken link reporter that posts each broken
+// link as JSON to the webhook at webhookURL. Links are posted in the
+// background so that serving the 404 is not delayed, and failed posts are
+// logged with the standard logger.
+func BrokenLinkWebhook(webhookURL string) func(link BrokenLink) {
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	return func(link BrokenLink) {
+
+		go func() {
+
+			body, _ := json.Marshal(link)
+			response, err := client.Post(webhookURL, "application/json", bytes.NewReader(body))
+
+			if err != nil {
+				log.Printf("handlers: reporting broken link failed: %s", err)
+				return
+			}
+
+			response.Body.Close()
+
+			if response.StatusCode >= 300 {
+				log.Printf("handlers: reporting broken link returned %s", response.Status)
+			}
+		}()
+	}
+}
+
+// check reports the request as a broken link at the given time if its Referer
+// is a page on the same host that has not already been reported for the path.
+func (b *brokenLinkReporter) check(r *http.Request, now time.Time) {
+
+	referrer, err := url.Parse(r.Referer())
+
+	if err != nil || referrer.Host == "" || referrer.Host != r.Host {
+		return
+	}
+
+	link := BrokenLink{
+		Path:     r.URL.Path,
+		Referrer: referrer.Scheme + "://" + referrer.Host + referrer.EscapedPath(),
+	}
+
+	b.mutex.Lock()
+
+	if b.reported[link] {
+		b.mutex.Unlock()
+		return
+	}
+
+	if len(b.order) >= maxBrokenLinks {
+		delete(b.reported, b.order[0])
+		b.order = b.order[1:]
+	}
+
+	b.reported[link] = true
+	b.order = append(b.order, link)
+	b.mutex.Unlock()
+
+	link.Time = now
+	b.report(link)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test NotFoundHandler broken link reporting
+func TestBrokenLinkReporter(t *testing.T) {
+
+	var (
+		nfh      *NotFoundHandler
+		reported []BrokenLink
+	)
+
+	clock := NewFixedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	nfh = NewDefaultNotFoundHandler()
+	nfh.SetClock(clock)
+	nfh.SetBrokenLinkReporter(func(link BrokenLink) {
+		reported = append(reported, link)
+	})
+
+	tests := []struct {
+		path     string
+		referrer string
+	}{
+		{"/missing", "http://example.com/page?ref=1"},
+		{"/missing", "http://example.com/page"},
+		{"/missing", "http://other.example.com/page"},
+		{"/missing", ""},
+		{"/other", "http://example.com/page"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the Referer
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "http://example.com"+test.path, nil)
+
+		if test.referrer != "" {
+			request.Header.Set("Referer", test.referrer)
+		}
+
+		nfh.ServeHTTP(response, request)
+
+		if response.Code != http.StatusNotFound {
+			t.Errorf("Expected StatusNotFound for %s. Got: %d", test.path, response.Code)
+		}
+	}
+
+	// Check only internal links are reported, each once, without the query
+	if len(reported) != 2 {
+		t.Fatalf("Expected 2 broken links. Got: %+v", reported)
+	}
+
+	if reported[0].Path != "/missing" || reported[0].Referrer != "http://example.com/page" {
+		t.Errorf("Expected /missing on http://example.com/page. Got: %+v", reported[0])
+	}
+
+	if reported[1].Path != "/other" {
+		t.Errorf("Expected /other. Got: %+v", reported[1])
+	}
+
+	if !reported[0].Time.Equal(clock.Now()) {
+		t.Errorf("Expected the time from the handler's clock. Got: %s", reported[0].Time)
+	}
+
+	// Check the oldest links are forgotten when the limit is reached
+	report := func(path string) {
+
+		request := httptest.NewRequest("GET", "http://example.com"+path, nil)
+		request.Header.Set("Referer", "http://example.com/page")
+		nfh.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	for i := 0; i < maxBrokenLinks; i++ {
+		report(fmt.Sprintf("/filler/%d", i))
+	}
+
+	count := len(reported)
+	report("/new")
+	report("/missing")
+
+	if len(reported) != count+2 || reported[len(reported)-1].Path != "/missing" {
+		t.Errorf("Expected new and forgotten links to be reported. Got: %d reports", len(reported)-count)
+	}
+
+	reported = reported[:2]
+
+	// Test BrokenLinkLogger
+	var output bytes.Buffer
+	BrokenLinkLogger(log.New(&output, "", 0))(reported[0])
+
+	if expected := "broken link to /missing on http://example.com/page"; !strings.Contains(output.String(), expected) {
+		t.Errorf("Expected %q in the log. Got: %s", expected, output.String())
+	}
+
+	// Test BrokenLinkWebhook
+	received := make(chan BrokenLink, 1)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		var link BrokenLink
+		json.NewDecoder(r.Body).Decode(&link)
+		received <- link
+	}))
+
+	defer server.Close()
+
+	BrokenLinkWebhook(server.URL)(reported[1])
+
+	select {
+
+	case link := <-received:
+
+		if link.Path != "/other" {
+			t.Errorf("Expected /other from the webhook. Got: %+v", link)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected the broken link to be posted to the webhook")
+	}
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"strings"
+)
+
+// clientCertContextKey is the context key for the verified client certificate
+// of a request.
+type clientCertContextKey struct{}
+
+// ClientCertTLSConfig returns a tls.Config for a listener that requires every
+// client to present a certificate signed by one of the given certificate
+// authorities. Use it for the listeners of internal servers, for example with
+// http.Server.TLSConfig, and add the server's own certificates to it. Other
+// listeners of the same program can keep serving without client certificates.
+func ClientCertTLSConfig(clientCAs *x509.CertPool) *tls.Config {
+
+	return &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+		MinVersion: tls.VersionTLS12,
+	}
+}
+
+// ClientCertHandler authorizes requests by the verified client certificate
+// presented over mutual TLS, for internal servers such as artifact stores.
+// Requests without a verified certificate are refused with a 403. Paths can be
+// restricted to certificates with particular subjects with Allow. The
+// verified certificate is placed in the request context, where it can be read
+// with ClientCertificate and ClientCertSubject.
+type ClientCertHandler struct {
+	next       http.Handler
+	allowlists map[string][]string
+	prefixes   []string
+	files      *FileHandler
+}
+
+// NewClientCertHandler returns a new ClientCertHandler protecting next. Until
+// Allow is called any client with a verified certificate is accepted.
+func NewClientCertHandler(next http.Handler) *ClientCertHandler {
+
+	files, _ := next.(*FileHandler)
+
+	return &ClientCertHandler{
+		next:       next,
+		allowlists: make(map[string][]string),
+		files:      files,
+	}
+}
+
+// Allow restricts the paths under prefix to clients whose certificate has one
+// of the given subjects. A subject matches either the certificate's common
+// name or its full distinguished name, such as "CN=builder,O=Example".
+// Prefixes match whole segments of the cleaned request path, so "/admin"
+// covers "/admin/users" but not "/administrator", and paths are matched as
+// SetPathMatching describes. When prefixes overlap the longest one that
+// matches a request applies. If several prefixes of that length match, which
+// happens when they differ only in case or Unicode form, it is not clear
+// which applies, so the request is refused.
+func (h *ClientCertHandler) Allow(prefix string, subjects ...string) {
+
+	if _, ok := h.allowlists[prefix]; !ok {
+		h.prefixes = append(h.prefixes, prefix)
+	}
+
+	h.allowlists[prefix] = append(h.allowlists[prefix], subjects...)
+}
+
+// SetPathMatching sets the FileHandler whose case sensitivity and path
+// normalization are used to match request paths against the prefixes given
+// to Allow, so that every spelling of a path that the FileHandler serves as
+// the same file is held to the same allowlist. It is needed when other
+// handlers sit between the ClientCertHandler and the FileHandler; when next
+// is a FileHandler it is used by default. Without one, case is ignored but
+// paths are not normalized.
+func (h *ClientCertHandler) SetPathMatching(files *FileHandler) {
+
+	h.files = files
+}
+
+// ServeHTTP calls the protected handler if the request has a verified client
+// certificate that is allowed for its path, and responds with a 403 otherwise.
+func (h *ClientCertHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+		serveForbidden(w)
+		return
+	}
+
+	certificate := r.TLS.VerifiedChains[0][0]
+
+	if !h.allowed(r.URL.Path, certificate) {
+		serveForbidden(w)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), clientCertContextKey{}, certificate)
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// allowed reports whether the certificate is allowed for the path by the
+// longest prefix that matches it, and refuses it if that prefix is ambiguous.
+func (h *ClientCertHandler) allowed(urlPath string, certificate *x509.Certificate) bool {
+
+	var matched []string
+	longest := -1
+
+	for _, prefix := range h.prefixes {
+
+		if !hasPathPrefix(urlPath, prefix, h.files) {
+			continue
+		}
+
+		length := len(strings.TrimSuffix(foldPath(prefix, h.files), "/"))
+
+		if length > longest {
+			matched, longest = nil, length
+		}
+
+		if length == longest {
+			matched = append(matched, prefix)
+		}
+	}
+
+	if len(matched) == 0 {
+		return true
+	}
+
+	if len(matched) > 1 {
+		return false
+	}
+
+	for _, subject := range h.allowlists[matched[0]] {
+
+		if subject == certificate.Subject.CommonName ||
+			subject == certificate.Subject.String() {
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientCertificate returns the verified client certificate of a request that
+// has passed through a ClientCertHandler, or nil if it has not.
+func ClientCertificate(r *http.Request) *x509.Certificate {
+
+	certificate, _ := r.Context().Value(clientCertContextKey{}).(*x509.Certificate)
+	return certificate
+}
+
+// ClientCertSubject returns the distinguished name of the verified client
+// certificate of a request that has passed through a ClientCertHandler, or an
+// empty string if it has not.
+func ClientCertSubject(r *http.Request) string {
+
+	if certificate := ClientCertificate(r); certificate != nil {
+		return certificate.Subject.String()
+	}
+
+	return ""
+}
+
+// serveForbidden responds with a 403.
+func serveForbidden(w http.ResponseWriter) {
+
+	setServerHeader(w)
+	http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// newTestCertificate returns a certificate for the given common name, signed
+// by parent, or self-signed as a certificate authority if parent is nil.
+func newTestCertificate(t *testing.T, commonName string, parent *tls.Certificate) tls.Certificate {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %s", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := template, interface{}(key)
+
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.Leaf, parent.PrivateKey
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+
+	if err != nil {
+		t.Fatalf("Failed to create a certificate: %s", err)
+	}
+
+	leaf, _ := x509.ParseCertificate(der)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// Test the ClientCertHandler over mutual TLS
+func TestClientCertHandler(t *testing.T) {
+
+	var (
+		h *ClientCertHandler
+	)
+
+	ca := newTestCertificate(t, "Test CA", nil)
+	builder := newTestCertificate(t, "builder", &ca)
+	viewer := newTestCertificate(t, "viewer", &ca)
+	untrusted := newTestCertificate(t, "builder", nil)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, ClientCertSubject(r))
+	})
+
+	h = NewClientCertHandler(next)
+	h.Allow("/upload/", "builder")
+	h.Allow("/upload/public/", "viewer", "CN=builder,O=Example")
+	h.Allow("/admin", "builder")
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca.Leaf)
+
+	server := httptest.NewUnstartedServer(h)
+	server.TLS = ClientCertTLSConfig(clientCAs)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	get := func(certificate *tls.Certificate, path string) (int, error) {
+
+		config := &tls.Config{RootCAs: serverCAs}
+
+		if certificate != nil {
+			config.Certificates = []tls.Certificate{*certificate}
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		response, err := client.Get(server.URL + path)
+
+		if err != nil {
+			return 0, err
+		}
+
+		response.Body.Close()
+		return response.StatusCode, nil
+	}
+
+	tests := []struct {
+		name        string
+		certificate *tls.Certificate
+		path        string
+		status      int
+	}{
+		{"builder", &builder, "/artifacts/", http.StatusOK},
+		{"viewer", &viewer, "/artifacts/", http.StatusOK},
+		{"builder", &builder, "/upload/", http.StatusOK},
+		{"viewer", &viewer, "/upload/", http.StatusForbidden},
+		{"viewer", &viewer, "/upload/public/", http.StatusOK},
+		{"builder", &builder, "/upload/public/", http.StatusOK},
+		{"viewer", &viewer, "//upload/x", http.StatusForbidden},
+		{"viewer", &viewer, "/./upload/x", http.StatusForbidden},
+		{"viewer", &viewer, "/upload/public/../x", http.StatusForbidden},
+		{"viewer", &viewer, "/admin", http.StatusForbidden},
+		{"viewer", &viewer, "/admin/x", http.StatusForbidden},
+		{"viewer", &viewer, "/administrator", http.StatusOK},
+		{"viewer", &viewer, "/Upload/x", http.StatusForbidden},
+		{"viewer", &viewer, "/ADMIN", http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+
+		// Test the request with the client certificate
+		status, err := get(test.certificate, test.path)
+
+		if err != nil {
+			t.Errorf("Expected no error for %s on %s. Got: %s", test.name, test.path, err)
+			continue
+		}
+
+		// Check the status
+		if status != test.status {
+			t.Errorf("Expected %d for %s on %s. Got: %d", test.status, test.name, test.path, status)
+		}
+	}
+
+	// Check clients without a trusted certificate are refused by the listener
+	if status, err := get(nil, "/artifacts/"); err == nil {
+		t.Errorf("Expected an error without a client certificate. Got: %d", status)
+	}
+
+	if status, err := get(&untrusted, "/artifacts/"); err == nil {
+		t.Errorf("Expected an error with an untrusted client certificate. Got: %d", status)
+	}
+
+	// Check requests without TLS are refused by the handler
+	response := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", "/artifacts/", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusForbidden {
+		t.Errorf("Expected StatusForbidden without TLS. Got: %d", response.Code)
+	}
+
+	// Check paths are matched the way the protected FileHandler matches them
+	files := NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"caf\u00e9/menu.html": {Data: []byte("Menu")},
+		"Team/notes.html":     {Data: []byte("Notes")},
+	}), MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html")))
+	files.SetCaseSensitivity(CaseInsensitive)
+	files.SetPathNormalizer(func(name string) string {
+		return strings.ReplaceAll(name, "e\u0301", "\u00e9")
+	})
+
+	h = NewClientCertHandler(files)
+	h.Allow("/caf\u00e9/", "builder")
+	h.Allow("/Team/", "viewer")
+	h.Allow("/team/", "builder")
+
+	for _, test := range []struct {
+		name        string
+		certificate *tls.Certificate
+		path        string
+		status      int
+	}{
+		{"builder", &builder, "/cafe\u0301/menu.html", http.StatusOK},
+		{"viewer", &viewer, "/caf\u00e9/menu.html", http.StatusForbidden},
+		{"viewer", &viewer, "/cafe\u0301/menu.html", http.StatusForbidden},
+		{"viewer", &viewer, "/CAF\u00c9/menu.html", http.StatusForbidden},
+		{"viewer", &viewer, "/team/notes.html", http.StatusForbidden},
+		{"builder", &builder, "/TEAM/notes.html", http.StatusForbidden},
+	} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+		request.URL.Path = test.path
+		request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{test.certificate.Leaf}}}
+		h.ServeHTTP(response, request)
+
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s on %s. Got: %d", test.status, test.name, test.path, response.Code)
+		}
+	}
+}
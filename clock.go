@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the package's time-dependent types the current time. Publish
+// schedules and the origin cache read the time from a Clock, so that tests
+// can check behaviour at exact times, and simulations can run faster or
+// slower than real time, without waiting on the system clock.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts an ordinary function to the Clock interface.
+type ClockFunc func() time.Time
+
+// Now returns f().
+func (f ClockFunc) Now() time.Time {
+
+	return f()
+}
+
+// SystemClock is the Clock used by default, which reads the system clock.
+var SystemClock Clock = ClockFunc(time.Now)
+
+// FixedClock is a Clock that always returns the same time until it is moved,
+// for use in tests. It is safe for concurrent use, so it can be moved while
+// handlers under test are serving requests.
+type FixedClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+// NewFixedClock returns a new FixedClock set to the given time.
+func NewFixedClock(now time.Time) *FixedClock {
+
+	return &FixedClock{now: now}
+}
+
+// Now returns the clock's current time.
+func (c *FixedClock) Now() time.Time {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+// Set sets the clock's current time.
+func (c *FixedClock) Set(now time.Time) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = now
+}
+
+// Advance moves the clock forward by the given duration.
+func (c *FixedClock) Advance(d time.Duration) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
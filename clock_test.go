@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+// Test the clocks
+func TestClock(t *testing.T) {
+
+	var (
+		start time.Time = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+		clock *FixedClock
+	)
+
+	// Check a FixedClock returns its time until it is moved
+	clock = NewFixedClock(start)
+
+	if now := clock.Now(); !now.Equal(start) {
+		t.Errorf("Expected %s from FixedClock. Got: %s", start, now)
+	}
+
+	clock.Advance(time.Minute)
+
+	if now := clock.Now(); !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("Expected %s from FixedClock. Got: %s", start.Add(time.Minute), now)
+	}
+
+	// Check a ClockFunc returns the result of its function
+	var c Clock = ClockFunc(func() time.Time { return start })
+
+	if now := c.Now(); !now.Equal(start) {
+		t.Errorf("Expected %s from ClockFunc. Got: %s", start, now)
+	}
+}
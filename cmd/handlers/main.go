@@ -0,0 +1,111 @@
+/*
+Command handlers serves a directory over HTTP using the handlers package.
+
+Usage:
+
+	handlers serve [flags] [directory]
+
+It is a drop-in replacement for "python -m http.server" that, unlike Go's
+built-in FileServer, never shows directory listings and responds to requests
+for missing files with a templated 404 page. The directory defaults to the
+current directory. The flags are:
+
+	-addr string
+		address to listen on (default ":8000")
+	-notfound string
+		path to a template for 404 pages, which can display {{.Path}}
+	-server-header string
+		value of the Server header, or "" to suppress it (default "handlers")
+	-trust-proxy
+		build redirects from X-Forwarded-Proto and X-Forwarded-Host
+	-quiet
+		do not log requests
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/olihawkins/handlers"
+)
+
+func main() {
+
+	if len(os.Args) < 2 || os.Args[1] != "serve" {
+		fmt.Fprintln(os.Stderr, "usage: handlers serve [flags] [directory]")
+		os.Exit(2)
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8000", "address to listen on")
+	notFoundPath := flags.String("notfound", "", "path to a template for 404 pages")
+	serverHeader := flags.String("server-header", "handlers", "value of the Server header")
+	trustProxy := flags.Bool("trust-proxy", false, "build redirects from forwarded headers")
+	quiet := flags.Bool("quiet", false, "do not log requests")
+	flags.Parse(os.Args[2:])
+
+	directory := "."
+
+	if flags.NArg() > 0 {
+		directory = flags.Arg(0)
+	}
+
+	// Create the NotFoundHandler from the given or built-in template
+	var nfh *handlers.NotFoundHandler
+
+	if *notFoundPath != "" {
+
+		var err error
+		nfh, err = handlers.LoadNotFoundHandler(*notFoundPath)
+
+		if err != nil {
+			log.Fatal(err)
+		}
+
+	} else {
+		nfh = handlers.NewDefaultNotFoundHandler()
+	}
+
+	// Create the FileHandler for the directory
+	fh := handlers.NewFileHandler("/", directory, nfh)
+	fh.SetTrustForwardedHeaders(*trustProxy)
+	handlers.SetServerHeader(*serverHeader)
+
+	var handler http.Handler = fh
+
+	if !*quiet {
+		handler = logRequests(handler)
+	}
+
+	log.Printf("Serving %s on %s", directory, *addr)
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
+
+// statusRecorder records the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs the method, path, status and duration of each request.
+func logRequests(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
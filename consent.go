@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// scriptSourcePattern matches an external script element and captures the
+// value of its src attribute.
+var scriptSourcePattern = regexp.MustCompile(
+	`(?is)<script\b[^>]*?\bsrc\s*=\s*["']?([^"'\s>]+)[^>]*>\s*</script\s*>`)
+
+// SetConsentBanner makes the handler enforce cookie consent on the HTML pages
+// it serves. Until the client has a cookie with the given name, each page has
+// the banner snippet inserted before its closing body tag, and any script
+// element whose src contains one of the given script patterns is removed, so
+// analytics and other tracking scripts are not loaded before consent is given.
+// Once the cookie is present pages are served unchanged. The banner is
+// responsible for setting the cookie when the user consents. The check is made
+// on the server, so pages served this way vary by cookie and are not cached
+// by shared caches.
+func (h *FileHandler) SetConsentBanner(cookieName string, banner string, scripts ...string) {
+
+	h.addTransform(func(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+
+		addVary(w, "Cookie")
+
+		if cookie, err := r.Cookie(cookieName); err == nil && cookie.Value != "" {
+			return page
+		}
+
+		page = scriptSourcePattern.ReplaceAllFunc(page, func(element []byte) []byte {
+
+			source := string(scriptSourcePattern.FindSubmatch(element)[1])
+
+			for _, script := range scripts {
+
+				if strings.Contains(source, script) {
+					return nil
+				}
+			}
+
+			return element
+		})
+
+		return insertBeforeBody(page, []byte(banner))
+	})
+}
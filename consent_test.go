@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler consent banner injection
+func TestConsentBanner(t *testing.T) {
+
+	const page string = `<html><body><p>Page</p>` +
+		`<script src="https://analytics.example.com/a.js"></script>` +
+		`<script src="/static/app.js"></script></body></html>`
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a FileHandler that enforces consent for the analytics script
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.html": {Data: []byte(page)},
+		"app.js":     {Data: []byte("app()")},
+	}), nfh)
+	h.SetConsentBanner("consent", `<div id="consent"></div>`, "analytics.example.com")
+
+	// Test ServeHTTP without the consent cookie
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the analytics script is removed and the banner is inserted
+	bodyString = response.Body.String()
+	expected := `<html><body><p>Page</p><script src="/static/app.js"></script>` +
+		`<div id="consent"></div></body></html>`
+
+	if bodyString != expected {
+		t.Errorf("Expected \"%s\" from FileHandler. Got: %s", expected, bodyString)
+	}
+
+	// Test ServeHTTP with the consent cookie
+	response = httptest.NewRecorder()
+	request.AddCookie(&http.Cookie{Name: "consent", Value: "yes"})
+	h.ServeHTTP(response, request)
+
+	// Check the page is unchanged
+	bodyString = response.Body.String()
+
+	if bodyString != page {
+		t.Errorf("Expected the original page from FileHandler. Got: %s", bodyString)
+	}
+
+	// Check the response varies by cookie
+	if vary := response.Header().Get("Vary"); vary != "Cookie" {
+		t.Errorf("Expected Vary \"Cookie\" from FileHandler. Got: %s", vary)
+	}
+
+	// Test ServeHTTP on a file that is not HTML
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/app.js", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the file is served unchanged
+	if bodyString = response.Body.String(); bodyString != "app()" {
+		t.Errorf("Expected \"app()\" from FileHandler. Got: %s", bodyString)
+	}
+
+	// Check a page over the transform limit gets a 500 without the page
+	h.SetTransformLimit(int64(len(page) / 2))
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError || strings.Contains(response.Body.String(), "<p>Page</p>") {
+		t.Errorf("Expected a 500 for a page over the transform limit. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Check the error is served by the handler's ErrorHandler if it has one
+	var reported string
+
+	eh := NewErrorHandler(template.Must(template.New("error").Parse(`Error {{.ErrorID}} on {{.Path}}`)))
+	eh.SetRequestIDHeader("X-Request-ID")
+	eh.OnError(func(r *http.Request, message string, status int, errorID string) {
+		reported = message
+	})
+	h.SetErrorHandler(eh)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	request.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError || response.Body.String() != "Error req-1 on /" {
+		t.Errorf("Expected the ErrorHandler's page for a page over the transform limit. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	if !strings.Contains(reported, "exceeds the transform limit") {
+		t.Errorf("Expected the transform limit error to be reported. Got: %q", reported)
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultRetryAfter is the delay a FileHandler asks clients to wait before
+// retrying a request that failed while its content was being synchronised, if
+// no other delay has been set.
+const DefaultRetryAfter time.Duration = 30 * time.Second
+
+// SetSyncCheck sets a function that reports whether the handler's content is
+// being synchronised, for example while a deploy is copying files into the
+// handler's directory. While it returns true, requests for missing files are
+// answered with a 503 and a Retry-After header of retryAfter, rather than a
+// 404, so that search engines do not drop pages that are only missing until
+// the deploy completes. The same applies at any time to files listed in the
+// handler's integrity manifest, whose absence means the content does not yet
+// match the manifest. If retryAfter is zero DefaultRetryAfter is used. Passing
+// a nil function turns the check off.
+func (h *FileHandler) SetSyncCheck(syncing func() bool, retryAfter time.Duration) {
+
+	h.syncing = syncing
+	h.retryAfter = retryAfter
+}
+
+// SyncMarker returns a sync check for use with SetSyncCheck which reports
+// whether the named marker file exists in fileSystem. Deploy scripts can
+// create the marker before they start copying content and remove it when they
+// have finished.
+func SyncMarker(fileSystem http.FileSystem, name string) func() bool {
+
+	return func() bool {
+
+		file, err := fileSystem.Open(name)
+
+		if err != nil {
+			return false
+		}
+
+		file.Close()
+		return true
+	}
+}
+
+// notFound responds to a request for a file that could not be found. If the
+// content is being synchronised, or the file is listed in the integrity
+// manifest, it serves a 503 with a Retry-After header, otherwise it serves a
+// 404 with the not found handler.
+func (h *FileHandler) notFound(w http.ResponseWriter, r *http.Request) {
+
+	if h.isSyncing() || (h.integrity != nil && h.integrity.Integrity(r.URL.Path) != "") {
+		h.serveUnavailable(w)
+		return
+	}
+
+	h.notFoundHandler.ServeHTTP(w, r)
+}
+
+// isSyncing reports whether the handler's sync check says the content is
+// being synchronised.
+func (h *FileHandler) isSyncing() bool {
+
+	return h.syncing != nil && h.syncing()
+}
+
+// serveUnavailable serves a 503 asking the client to retry after the
+// handler's retry delay.
+func (h *FileHandler) serveUnavailable(w http.ResponseWriter) {
+
+	retryAfter := h.retryAfter
+
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	w.Header().Set("Cache-Control", "no-store")
+	http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+}
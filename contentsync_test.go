@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test FileHandler responses while content is being synchronised
+func TestFileHandlerContentSync(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		fileSystem fstest.MapFS
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	fileSystem = fstest.MapFS{
+		"index.html":    {Data: []byte("Index")},
+		"static/app.js": {Data: []byte("app")},
+	}
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fileSystem), nfh)
+	h.SetSyncCheck(SyncMarker(http.FS(fileSystem), ".deploying"), 90*time.Second)
+
+	// Check a missing file is a 404 when no deploy is in progress
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/missing.html", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound without the marker. Got: %d", response.Code)
+	}
+
+	// Check a missing file is a 503 with Retry-After while the marker exists
+	fileSystem[".deploying"] = &fstest.MapFile{}
+
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected StatusServiceUnavailable with the marker. Got: %d", response.Code)
+	}
+
+	if retryAfter := response.Header().Get("Retry-After"); retryAfter != "90" {
+		t.Errorf("Expected Retry-After of 90. Got: %q", retryAfter)
+	}
+
+	// Check existing files are still served while the marker exists
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK for an existing file with the marker. Got: %d", response.Code)
+	}
+
+	// Check unsafe paths are still a 404 while the marker exists
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/a\\b.html", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound for an unsafe path. Got: %d", response.Code)
+	}
+
+	delete(fileSystem, ".deploying")
+
+	// Check a file missing from the integrity manifest is a 503 at any time
+	manifest, err := GenerateSRIManifest(fileSystem, "/")
+
+	if err != nil {
+		t.Fatalf("Expected no error from GenerateSRIManifest. Got: %s", err)
+	}
+
+	h.SetIntegrityManifest(manifest)
+	h.SetSyncCheck(nil, 0)
+	delete(fileSystem, "static/app.js")
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/static/app.js", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected StatusServiceUnavailable for a file in the manifest. Got: %d",
+			response.Code)
+	}
+
+	if retryAfter := response.Header().Get("Retry-After"); retryAfter != "30" {
+		t.Errorf("Expected the default Retry-After of 30. Got: %q", retryAfter)
+	}
+}
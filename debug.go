@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"net/netip"
+)
+
+// DebugHeader is the request header that carries the secret of a
+// DebugHandler.
+const DebugHeader string = "X-Handlers-Debug"
+
+// debugContextKey is the context key marking a request as allowed to see
+// debug headers.
+type debugContextKey struct{}
+
+// DebugHandler lets trusted clients see how the package's handlers dealt
+// with their requests, to make production problems tractable without
+// reading the wiring code. Requests that send the handler's secret in the
+// X-Handlers-Debug header, or that come from an allowed network, are passed
+// on marked for debugging, and the handlers below add X-Debug headers to
+// their responses: X-Debug-Handler names the FileHandler and its path,
+// X-Debug-Rewrite the rewrite or redirect rule applied, X-Debug-Variant the
+// device or image variant served, X-Debug-File the file served,
+// X-Debug-Backend the FailoverFileSystem backend that opened it, or whether
+// an OriginFileSystem served it from its cache or the origin, X-Debug-Cache
+// whether a processed image came from the cache, and X-Debug-Template the
+// template of an error or 404 page. Other requests are passed on unchanged.
+// Networks are matched against the address of the connection, so behind a
+// proxy the secret must be used. Responses vary by the X-Handlers-Debug
+// header, so that shared caches keep debug responses apart.
+type DebugHandler struct {
+	next     http.Handler
+	secret   [sha256.Size]byte
+	networks []netip.Prefix
+}
+
+// NewDebugHandler returns a new DebugHandler in front of next, which allows
+// requests carrying the given secret. An empty secret allows no request by
+// its header, so that only allowed networks see debug headers.
+func NewDebugHandler(next http.Handler, secret string) *DebugHandler {
+
+	h := &DebugHandler{next: next}
+
+	if secret != "" {
+		h.secret = sha256.Sum256([]byte(secret))
+	}
+
+	return h
+}
+
+// AllowNetwork allows requests from the network with the given CIDR prefix,
+// such as "10.0.0.0/8", or the single address, such as "::1", to see debug
+// headers without the secret. An error is returned if the network cannot be
+// parsed.
+func (h *DebugHandler) AllowNetwork(network string) error {
+
+	prefix, err := netip.ParsePrefix(network)
+
+	if err != nil {
+
+		address, addressErr := netip.ParseAddr(network)
+
+		if addressErr != nil {
+			return fmt.Errorf("handlers: invalid network %q", network)
+		}
+
+		prefix = netip.PrefixFrom(address, address.BitLen())
+	}
+
+	h.networks = append(h.networks, prefix.Masked())
+	return nil
+}
+
+// ServeHTTP passes the request on, marked for debugging if it is allowed to
+// see debug headers.
+func (h *DebugHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	addVary(w, DebugHeader)
+
+	if h.allowed(r) {
+		r = r.WithContext(context.WithValue(r.Context(), debugContextKey{}, true))
+	}
+
+	h.next.ServeHTTP(w, r)
+}
+
+// allowed reports whether the request carries the secret or comes from an
+// allowed network.
+func (h *DebugHandler) allowed(r *http.Request) bool {
+
+	if h.secret != [sha256.Size]byte{} {
+
+		if value := r.Header.Get(DebugHeader); value != "" {
+
+			sent := sha256.Sum256([]byte(value))
+
+			if subtle.ConstantTimeCompare(sent[:], h.secret[:]) == 1 {
+				return true
+			}
+		}
+	}
+
+	address, err := netip.ParseAddrPort(r.RemoteAddr)
+
+	if err != nil {
+		return false
+	}
+
+	for _, network := range h.networks {
+
+		if network.Contains(address.Addr().Unmap()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setDebugHeader sets the named debug header on the response if the request
+// is marked for debugging.
+func setDebugHeader(w http.ResponseWriter, r *http.Request, name string, value string) {
+
+	if r == nil {
+		return
+	}
+
+	if debugging, _ := r.Context().Value(debugContextKey{}).(bool); debugging {
+		w.Header().Set(name, value)
+	}
+}
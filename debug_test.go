@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test the DebugHandler
+func TestDebugHandler(t *testing.T) {
+
+	var (
+		h        *DebugHandler
+		fh       *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = NewNotFoundHandler(template.Must(template.New("notfound").Parse(`{{.Path}}`)))
+	fh = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"new/page.html": {Data: []byte("Page")},
+		"photo.png":     {Data: []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x00IEND\xaeB`\x82")},
+	}), nfh)
+
+	fh.AddRewrite("/old/", "/new/")
+	fh.SetStripImageMetadata(true)
+
+	h = NewDebugHandler(fh, "debug secret")
+
+	if err := h.AllowNetwork("10.0.0.0/8"); err != nil {
+		t.Fatalf("Expected no error from AllowNetwork. Got: %s", err)
+	}
+
+	if err := h.AllowNetwork("::1"); err != nil {
+		t.Fatalf("Expected no error from AllowNetwork. Got: %s", err)
+	}
+
+	if err := h.AllowNetwork("not a network"); err == nil {
+		t.Errorf("Expected an error from AllowNetwork for an invalid network")
+	}
+
+	tests := []struct {
+		path       string
+		remoteAddr string
+		secret     string
+		headers    map[string]string
+	}{
+		{"/old/page.html", "192.0.2.1:1234", "", map[string]string{
+			"X-Debug-Handler": "", "X-Debug-Rewrite": "", "X-Debug-File": "",
+		}},
+		{"/old/page.html", "192.0.2.1:1234", "wrong secret", map[string]string{
+			"X-Debug-Handler": "", "X-Debug-Rewrite": "",
+		}},
+		{"/old/page.html", "192.0.2.1:1234", "debug secret", map[string]string{
+			"X-Debug-Handler": "FileHandler /",
+			"X-Debug-Rewrite": "/old/ -> /new/page.html",
+			"X-Debug-File":    "/new/page.html",
+		}},
+		{"/old/page.html", "10.1.2.3:1234", "", map[string]string{
+			"X-Debug-Rewrite": "/old/ -> /new/page.html",
+		}},
+		{"/missing.html", "[::1]:1234", "", map[string]string{
+			"X-Debug-File":     "/missing.html",
+			"X-Debug-Template": "notfound",
+		}},
+		{"/photo.png", "10.1.2.3:1234", "", map[string]string{
+			"X-Debug-Cache": "miss",
+		}},
+		{"/photo.png", "10.1.2.3:1234", "", map[string]string{
+			"X-Debug-Cache": "hit",
+		}},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the address and secret
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		request.RemoteAddr = test.remoteAddr
+
+		if test.secret != "" {
+			request.Header.Set(DebugHeader, test.secret)
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the debug headers are only sent to allowed requests
+		for name, expected := range test.headers {
+
+			if value := response.Header().Get(name); value != expected {
+				t.Errorf("Expected %s %q for %s from %s. Got: %q", name, expected,
+					test.path, test.remoteAddr, value)
+			}
+		}
+
+		if vary := response.Header().Get("Vary"); vary != DebugHeader {
+			t.Errorf("Expected Vary: %s. Got: %q", DebugHeader, vary)
+		}
+	}
+
+	// Check the ErrorHandler names its template
+	eh := NewErrorHandler(template.Must(template.New("error").Parse(`{{.ErrorMessage}}`)))
+	h = NewDebugHandler(eh, "debug secret")
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	request.Header.Set(DebugHeader, "debug secret")
+	h.ServeHTTP(response, request)
+
+	if name := response.Header().Get("X-Debug-Template"); name != "error" {
+		t.Errorf("Expected X-Debug-Template \"error\". Got: %q", name)
+	}
+
+	// Check the FileHandler names the backend that served each file
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "Upstream")
+	}))
+	defer upstream.Close()
+
+	failover := NewFailoverFileSystem()
+	failover.Add("local", http.FS(fstest.MapFS{
+		"local.html": {Data: []byte("Local")},
+	}), 0)
+	failover.Add("mirror", NewOriginFileSystem(upstream.URL, t.TempDir(), time.Hour), 0)
+	h = NewDebugHandler(NewFileSystemHandler("/", failover, nfh), "debug secret")
+
+	for _, test := range []struct {
+		path    string
+		backend string
+	}{
+		{"/local.html", "local"},
+		{"/remote.html", "mirror (origin)"},
+		{"/remote.html", "mirror (cache)"},
+	} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		request.Header.Set(DebugHeader, "debug secret")
+		h.ServeHTTP(response, request)
+
+		if backend := response.Header().Get("X-Debug-Backend"); backend != test.backend {
+			t.Errorf("Expected X-Debug-Backend %q for %s. Got: %q", test.backend, test.path, backend)
+		}
+	}
+}
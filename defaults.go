@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"html/template"
+)
+
+// DefaultErrorMessage is the default error message used by the handler
+// returned by NewDefaultErrorHandler.
+const DefaultErrorMessage string = "Sorry, something went wrong. Please try again later."
+
+// defaultLayout is the layout shared by the built-in templates. Each page
+// defines its own "title" and "content" templates, and the "theme" template
+// and the branding function are provided when the templates are created.
+const defaultLayout string = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{template "title" .}}{{with branding}}{{with .SiteName}} - {{.}}{{end}}{{end}}</title>
+<style>{{template "theme"}}{{with branding}}{{with .AccentColor}}
+:root { --accent: {{.}}; }{{end}}{{end}}</style>
+</head>
+<body>
+{{with branding}}{{if or .SiteName .LogoSrc}}<header>{{if .LogoSrc}}<img src="{{.LogoSrc}}" alt=""> {{end}}{{.SiteName}}</header>
+{{end}}{{end}}<main>
+{{template "content" .}}
+</main>
+{{with branding}}{{if .FooterLinks}}<footer>
+<nav>{{range .FooterLinks}}<a href="{{.URL}}">{{.Text}}</a> {{end}}</nav>
+</footer>
+{{end}}{{end}}</body>
+</html>
+`
+
+// defaultErrorPage defines the title and content of the built-in error page.
+const defaultErrorPage string = `{{define "title"}}Error{{end}}
+{{define "content"}}<h1>Error</h1>
+<p>{{.ErrorMessage}}</p>{{end}}`
+
+// defaultNotFoundPage defines the title and content of the built-in 404 page.
+const defaultNotFoundPage string = `{{define "title"}}Not Found{{end}}
+{{define "content"}}<h1>Not Found</h1>
+<p>The page {{.Path}} could not be found.</p>{{end}}`
+
+// DefaultErrorTemplate returns the built-in error page template, which
+// displays {{.ErrorMessage}} in a minimal HTML page with the plain theme.
+func DefaultErrorTemplate() *template.Template {
+
+	return template.Must(ThemedErrorTemplate(ThemePlain))
+}
+
+// DefaultNotFoundTemplate returns the built-in 404 page template, which
+// displays {{.Path}} in a minimal HTML page with the plain theme.
+func DefaultNotFoundTemplate() *template.Template {
+
+	return template.Must(ThemedNotFoundTemplate(ThemePlain))
+}
+
+// NewDefaultErrorHandler returns a new ErrorHandler which uses the built-in
+// error page template, so that the package can be used before any templates
+// have been designed. The handler shows DefaultErrorMessage rather than the
+// messages passed to ServeError.
+func NewDefaultErrorHandler() *ErrorHandler {
+
+	return NewErrorHandler(DefaultErrorTemplate())
+}
+
+// NewDefaultNotFoundHandler returns a new NotFoundHandler which uses the
+// built-in 404 page template.
+func NewDefaultNotFoundHandler() *NotFoundHandler {
+
+	return NewNotFoundHandler(DefaultNotFoundTemplate())
+}
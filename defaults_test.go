@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the handlers with built-in templates
+func TestDefaultHandlers(t *testing.T) {
+
+	var (
+		eh         *ErrorHandler
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Test the default ErrorHandler hides the given message
+	eh = NewDefaultErrorHandler()
+
+	response = httptest.NewRecorder()
+	eh.ServeError(response, "Secret detail")
+
+	bodyString = response.Body.String()
+
+	if response.Code != http.StatusInternalServerError ||
+		!strings.Contains(bodyString, DefaultErrorMessage) ||
+		strings.Contains(bodyString, "Secret detail") {
+		t.Errorf("Expected the default message from ErrorHandler. Got: %d %s",
+			response.Code, bodyString)
+	}
+
+	// Test the default NotFoundHandler shows the path
+	nfh = NewDefaultNotFoundHandler()
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/missing", nil)
+	nfh.ServeHTTP(response, request)
+
+	bodyString = response.Body.String()
+
+	if response.Code != http.StatusNotFound || !strings.Contains(bodyString, "/missing") {
+		t.Errorf("Expected StatusNotFound and the path from NotFoundHandler. Got: %d %s",
+			response.Code, bodyString)
+	}
+
+	// Check the built-in templates pass the audit
+	if err := AuditErrorTemplate(DefaultErrorTemplate()); err != nil {
+		t.Errorf("Expected no error from AuditErrorTemplate. Got: %s", err)
+	}
+
+	if err := AuditNotFoundTemplate(DefaultNotFoundTemplate()); err != nil {
+		t.Errorf("Expected no error from AuditNotFoundTemplate. Got: %s", err)
+	}
+}
@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// mobileUserAgentPattern matches the user agents of phones. Tablets are
+// treated as desktops, as they usually display desktop pages well.
+var mobileUserAgentPattern = regexp.MustCompile(
+	`(?i)mobi|iphone|ipod|android.*mobile|windows phone|blackberry|opera mini`)
+
+// The values of a device override cookie.
+const (
+	DeviceMobile  string = "mobile"
+	DeviceDesktop string = "desktop"
+)
+
+// VariantPath maps the path of a file to the path of its variant.
+type VariantPath func(filePath string) string
+
+// MobileTree returns a VariantPath for sites that keep their mobile pages in
+// a separate tree, so the variant of /about/index.html is in /m/about/ when
+// the prefix is "/m".
+func MobileTree(prefix string) VariantPath {
+
+	return func(filePath string) string {
+		return path.Join(prefix, filePath)
+	}
+}
+
+// MobileSuffix returns a VariantPath for sites that keep their mobile pages
+// beside the desktop ones, so the variant of /about.html is /about.mobile.html
+// when the suffix is ".mobile".
+func MobileSuffix(suffix string) VariantPath {
+
+	return func(filePath string) string {
+
+		extension := path.Ext(filePath)
+		return strings.TrimSuffix(filePath, extension) + suffix + extension
+	}
+}
+
+// SetMobileVariants makes the handler serve the mobile variant of a file,
+// found with variant, to phones, for legacy sites that maintain separate
+// mobile pages. Files without a variant are served to every device. Responses
+// for files with a variant carry Vary: User-Agent, so caches keep the two
+// apart. If cookieName is not empty a cookie with that name and the value
+// DeviceMobile or DeviceDesktop overrides the detected device, so a site can
+// offer a link to the other version, and the responses also vary by cookie.
+// Passing a nil variant turns variants off.
+func (h *FileHandler) SetMobileVariants(variant VariantPath, cookieName string) {
+
+	h.mobileVariant = variant
+	h.deviceCookie = cookieName
+}
+
+// deviceVariant returns the path of the file to serve for the request in
+// place of filePath, and whether it is a variant. The response is marked as
+// varying if filePath has a variant.
+func (h *FileHandler) deviceVariant(w http.ResponseWriter, r *http.Request, filePath string) (string, bool) {
+
+	if h.mobileVariant == nil {
+		return filePath, false
+	}
+
+	variantPath := h.mobileVariant(filePath)
+
+	if variantPath == filePath || !safeRequestPath(variantPath) {
+		return filePath, false
+	}
+
+	if !h.isRegularFile(variantPath) {
+		return filePath, false
+	}
+
+	addVary(w, "User-Agent")
+
+	if h.deviceCookie != "" {
+		addVary(w, "Cookie")
+	}
+
+	if !h.isMobile(r) {
+		return filePath, false
+	}
+
+	return variantPath, true
+}
+
+// isMobile reports whether the request is from a phone, or has a cookie
+// asking for the mobile version.
+func (h *FileHandler) isMobile(r *http.Request) bool {
+
+	if h.deviceCookie != "" {
+
+		if cookie, err := r.Cookie(h.deviceCookie); err == nil {
+
+			switch cookie.Value {
+
+			case DeviceMobile:
+				return true
+
+			case DeviceDesktop:
+				return false
+			}
+		}
+	}
+
+	return mobileUserAgentPattern.MatchString(r.UserAgent())
+}
+
+// addVary adds a field to the Vary header of the response, unless it is
+// already listed.
+func addVary(w http.ResponseWriter, field string) {
+
+	for _, value := range w.Header().Values("Vary") {
+
+		for _, existing := range strings.Split(value, ",") {
+
+			if strings.EqualFold(strings.TrimSpace(existing), field) {
+				return
+			}
+		}
+	}
+
+	w.Header().Add("Vary", field)
+}
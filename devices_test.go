@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler mobile variants
+func TestMobileVariants(t *testing.T) {
+
+	const (
+		phone   string = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Mobile/15E148"
+		desktop string = "Mozilla/5.0 (X11; Linux x86_64) Firefox/120.0"
+	)
+
+	var (
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+
+	tree := NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.html":         {Data: []byte("Desktop home")},
+		"about.html":         {Data: []byte("Desktop about")},
+		"m/index.html":       {Data: []byte("Mobile home")},
+		"contact/index.html": {Data: []byte("Desktop contact")},
+	}), nfh)
+	tree.SetMobileVariants(MobileTree("/m"), "device")
+
+	suffix := NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"about.html":        {Data: []byte("Desktop about")},
+		"about.mobile.html": {Data: []byte("Mobile about")},
+	}), nfh)
+	suffix.SetMobileVariants(MobileSuffix(".mobile"), "")
+
+	tests := []struct {
+		h         *FileHandler
+		path      string
+		userAgent string
+		cookie    string
+		body      string
+		vary      bool
+	}{
+		{tree, "/", phone, "", "Mobile home", true},
+		{tree, "/", desktop, "", "Desktop home", true},
+		{tree, "/", phone, DeviceDesktop, "Desktop home", true},
+		{tree, "/", desktop, DeviceMobile, "Mobile home", true},
+		{tree, "/about.html", phone, "", "Desktop about", false},
+		{tree, "/contact/", phone, "", "Desktop contact", false},
+		{suffix, "/about.html", phone, "", "Mobile about", true},
+		{suffix, "/about.html", desktop, "", "Desktop about", true},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the user agent and cookie
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		request.Header.Set("User-Agent", test.userAgent)
+
+		if test.cookie != "" {
+			request.AddCookie(&http.Cookie{Name: "device", Value: test.cookie})
+		}
+
+		test.h.ServeHTTP(response, request)
+
+		// Check the right version is served
+		if body := response.Body.String(); body != test.body {
+			t.Errorf("Expected %q for %s with %q. Got: %q", test.body, test.path, test.cookie, body)
+		}
+
+		// Check responses for files with variants vary by user agent
+		vary := response.Header().Values("Vary")
+
+		if test.vary && (len(vary) == 0 || vary[0] != "User-Agent") {
+			t.Errorf("Expected Vary \"User-Agent\" for %s. Got: %q", test.path, vary)
+		}
+
+		if !test.vary && len(vary) != 0 {
+			t.Errorf("Expected no Vary header for %s. Got: %q", test.path, vary)
+		}
+	}
+}
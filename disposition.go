@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"unicode"
+)
+
+// The Content-Disposition types set by a FileHandler's disposition policy.
+const (
+	DispositionInline     string = "inline"
+	DispositionAttachment string = "attachment"
+)
+
+// DefaultDispositions returns the disposition policy used when a FileHandler
+// is given a nil policy. Documents and images that browsers display safely
+// are shown inline, and executables, installers, scripts and archives are
+// downloaded, so they are never run or rendered by the browser.
+func DefaultDispositions() map[string]string {
+
+	policy := make(map[string]string)
+
+	for _, extension := range []string{
+		".pdf", ".txt", ".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif",
+	} {
+		policy[extension] = DispositionInline
+	}
+
+	for _, extension := range []string{
+		".exe", ".msi", ".dll", ".bat", ".cmd", ".com", ".scr", ".ps1", ".sh",
+		".jar", ".apk", ".dmg", ".pkg", ".deb", ".rpm", ".appimage",
+		".zip", ".tar", ".gz", ".tgz", ".bz2", ".xz", ".7z", ".rar", ".iso",
+	} {
+		policy[extension] = DispositionAttachment
+	}
+
+	return policy
+}
+
+// SetContentDisposition sets the Content-Disposition policy of the handler,
+// which maps file extensions, such as ".pdf", to DispositionInline or
+// DispositionAttachment, so that whether a file is shown or downloaded does
+// not depend on the browser. Extensions are matched without regard to case.
+// Files with an extension not in the policy get defaultDisposition, or no
+// header if it is empty. The header names the file, with characters that are
+// unsafe in a file name removed, so that downloads are saved under their own
+// name. A nil policy uses DefaultDispositions. By default no Content-Disposition
+// header is set.
+func (h *FileHandler) SetContentDisposition(policy map[string]string, defaultDisposition string) {
+
+	if policy == nil {
+		policy = DefaultDispositions()
+	}
+
+	h.dispositions = make(map[string]string, len(policy))
+
+	for extension, disposition := range policy {
+		h.dispositions[strings.ToLower(extension)] = disposition
+	}
+
+	h.defaultDisposition = defaultDisposition
+}
+
+// setContentDisposition sets the Content-Disposition header for the named
+// file according to the handler's policy.
+func (h *FileHandler) setContentDisposition(w http.ResponseWriter, name string) {
+
+	if h.dispositions == nil {
+		return
+	}
+
+	disposition, ok := h.dispositions[strings.ToLower(path.Ext(name))]
+
+	if !ok {
+		disposition = h.defaultDisposition
+	}
+
+	if disposition == "" {
+		return
+	}
+
+	value := disposition
+
+	if filename := sanitizeFilename(name); filename != "" {
+
+		if formatted := mime.FormatMediaType(disposition, map[string]string{"filename": filename}); formatted != "" {
+			value = formatted
+		}
+	}
+
+	w.Header().Set("Content-Disposition", value)
+}
+
+// sanitizeFilename removes characters that are unsafe in a downloaded file's
+// name: control characters, path separators, quotes and characters Windows
+// does not allow, and leading dots, so a download cannot be saved as a hidden
+// file.
+func sanitizeFilename(name string) string {
+
+	name = strings.Map(func(c rune) rune {
+
+		if unicode.IsControl(c) || strings.ContainsRune(`/\"'<>:|?*`, c) {
+			return -1
+		}
+
+		return c
+	}, strings.ToValidUTF8(name, ""))
+
+	return strings.TrimLeft(strings.TrimSpace(name), ".")
+}
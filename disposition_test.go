@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler Content-Disposition policies
+func TestContentDisposition(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"report.pdf":      {Data: []byte("PDF")},
+		"setup.EXE":       {Data: []byte("EXE")},
+		"archive.zip":     {Data: []byte("ZIP")},
+		"page.html":       {Data: []byte("<p>Page</p>")},
+		"notes.md":        {Data: []byte("Notes")},
+		"..hidden \".pdf": {Data: []byte("PDF")},
+		"résumé.pdf":      {Data: []byte("PDF")},
+	}), nfh)
+
+	// Check no header is set by default
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/report.pdf", nil)
+	h.ServeHTTP(response, request)
+
+	if disposition := response.Header().Get("Content-Disposition"); disposition != "" {
+		t.Errorf("Expected no Content-Disposition by default. Got: %s", disposition)
+	}
+
+	h.SetContentDisposition(nil, "")
+
+	tests := []struct {
+		path        string
+		disposition string
+	}{
+		{"/report.pdf", `inline; filename=report.pdf`},
+		{"/setup.EXE", `attachment; filename=setup.EXE`},
+		{"/archive.zip", `attachment; filename=archive.zip`},
+		{"/page.html", ``},
+		{"/..hidden%20%22.pdf", `inline; filename="hidden .pdf"`},
+		{"/r%C3%A9sum%C3%A9.pdf", `inline; filename*=utf-8''r%C3%A9sum%C3%A9.pdf`},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the file
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the disposition follows the policy with a safe file name
+		if disposition := response.Header().Get("Content-Disposition"); disposition != test.disposition {
+			t.Errorf("Expected %q for %s. Got: %q", test.disposition, test.path, disposition)
+		}
+	}
+
+	// Check the default disposition applies to other extensions
+	h.SetContentDisposition(map[string]string{".MD": DispositionInline}, DispositionAttachment)
+
+	for path, expected := range map[string]string{
+		"/notes.md":   "inline; filename=notes.md",
+		"/report.pdf": "attachment; filename=report.pdf",
+	} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", path, nil)
+		h.ServeHTTP(response, request)
+
+		if disposition := response.Header().Get("Content-Disposition"); disposition != expected {
+			t.Errorf("Expected %q for %s. Got: %q", expected, path, disposition)
+		}
+	}
+}
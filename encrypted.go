@@ -0,0 +1,356 @@
+package handlers
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+)
+
+// The encrypted file format starts with a header made up of the magic string,
+// the plaintext chunk size as a big-endian uint32, and a random nonce prefix.
+// The header is followed by the plaintext in chunks of chunkSize bytes, each
+// sealed with AES-GCM. The nonce for each chunk is the nonce prefix followed
+// by the chunk's index as a big-endian uint32, and the additional data is a
+// single byte which is 1 for the last chunk and 0 otherwise, so that a file
+// cannot be truncated at a chunk boundary without detection.
+const (
+	encryptedMagic      string = "HNDLENC1"
+	encryptedPrefixSize int    = 8
+	encryptedHeaderSize int    = len(encryptedMagic) + 4 + encryptedPrefixSize
+	encryptedOverhead   int    = 16
+)
+
+// DefaultChunkSize is the chunk size used by EncryptFile if none is given.
+const DefaultChunkSize int = 64 * 1024
+
+// KeyFunc returns the AES key used to decrypt the named file. The key must be
+// 16, 24 or 32 bytes long. A KeyFunc can return a key held in configuration or
+// fetch one from a key management service.
+type KeyFunc func(name string) ([]byte, error)
+
+// StaticKey returns a KeyFunc that uses the same key for every file.
+func StaticKey(key []byte) KeyFunc {
+
+	return func(name string) ([]byte, error) {
+		return key, nil
+	}
+}
+
+// EncryptedFileSystem is an http.FileSystem that transparently decrypts files
+// stored in another file system, for environments where content must be
+// encrypted at rest but served in plaintext to authorized clients. Files must
+// have been written with EncryptFile. Files are decrypted a chunk at a time, so
+// range requests only decrypt the chunks they need. Directories are passed
+// through unchanged.
+type EncryptedFileSystem struct {
+	fileSystem http.FileSystem
+	key        KeyFunc
+}
+
+// NewEncryptedFileSystem returns a new EncryptedFileSystem that decrypts
+// files from fileSystem with keys returned by key.
+func NewEncryptedFileSystem(fileSystem http.FileSystem, key KeyFunc) *EncryptedFileSystem {
+
+	return &EncryptedFileSystem{
+		fileSystem: fileSystem,
+		key:        key,
+	}
+}
+
+// Open opens the named file and prepares it for decryption.
+func (fs *EncryptedFileSystem) Open(name string) (http.File, error) {
+
+	file, err := fs.fileSystem.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	finfo, err := file.Stat()
+
+	if err != nil || finfo.IsDir() {
+		return file, err
+	}
+
+	encrypted, err := fs.open(name, file, finfo)
+
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return encrypted, nil
+}
+
+// open reads the header of an encrypted file and returns a file that
+// decrypts its contents.
+func (fs *EncryptedFileSystem) open(name string, file http.File, finfo os.FileInfo) (*encryptedFile, error) {
+
+	header := make([]byte, encryptedHeaderSize)
+
+	if _, err := io.ReadFull(file, header); err != nil {
+		return nil, fmt.Errorf("handlers: %s is not an encrypted file", name)
+	}
+
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, fmt.Errorf("handlers: %s is not an encrypted file", name)
+	}
+
+	chunkSize := int64(binary.BigEndian.Uint32(header[len(encryptedMagic):]))
+
+	if chunkSize == 0 {
+		return nil, fmt.Errorf("handlers: %s has an invalid chunk size", name)
+	}
+
+	key, err := fs.key(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newEncryptedAEAD(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Work out the size of the plaintext from the size of the file
+	sealedSize := chunkSize + int64(encryptedOverhead)
+	bodySize := finfo.Size() - int64(encryptedHeaderSize)
+	size := (bodySize / sealedSize) * chunkSize
+
+	if remainder := bodySize % sealedSize; remainder > 0 {
+		size += remainder - int64(encryptedOverhead)
+	}
+
+	if size < 0 {
+		return nil, fmt.Errorf("handlers: %s is truncated", name)
+	}
+
+	return &encryptedFile{
+		File:      file,
+		info:      encryptedFileInfo{FileInfo: finfo, size: size},
+		aead:      aead,
+		prefix:    header[len(encryptedMagic)+4:],
+		chunkSize: chunkSize,
+		size:      size,
+		chunk:     -1,
+	}, nil
+}
+
+// EncryptFile encrypts everything read from src with the given key and writes
+// it to dst in the format read by EncryptedFileSystem. The plaintext is sealed
+// in chunks of chunkSize bytes; if chunkSize is zero DefaultChunkSize is
+// used.
+func EncryptFile(dst io.Writer, src io.Reader, key []byte, chunkSize int) error {
+
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+
+	aead, err := newEncryptedAEAD(key)
+
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, encryptedHeaderSize)
+	copy(header, encryptedMagic)
+	binary.BigEndian.PutUint32(header[len(encryptedMagic):], uint32(chunkSize))
+	prefix := header[len(encryptedMagic)+4:]
+
+	if _, err = rand.Read(prefix); err != nil {
+		return err
+	}
+
+	if _, err = dst.Write(header); err != nil {
+		return err
+	}
+
+	// Read one chunk ahead so the last chunk can be marked as such
+	current := make([]byte, chunkSize)
+	next := make([]byte, chunkSize)
+	n, err := io.ReadFull(src, current)
+
+	for index := uint32(0); ; index++ {
+
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last := err != nil
+		var nextN int
+
+		if !last {
+
+			nextN, err = io.ReadFull(src, next)
+
+			if err == io.EOF {
+				last = true
+			}
+		}
+
+		sealed := aead.Seal(nil, encryptedNonce(prefix, index), current[:n],
+			encryptedAdditionalData(last))
+
+		if _, writeErr := dst.Write(sealed); writeErr != nil {
+			return writeErr
+		}
+
+		if last {
+			return nil
+		}
+
+		current, next, n = next, current, nextN
+	}
+}
+
+// newEncryptedAEAD returns an AES-GCM AEAD for the given key.
+func newEncryptedAEAD(key []byte) (cipher.AEAD, error) {
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// encryptedNonce returns the nonce for the chunk with the given index.
+func encryptedNonce(prefix []byte, index uint32) []byte {
+
+	nonce := make([]byte, encryptedPrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encryptedPrefixSize:], index)
+	return nonce
+}
+
+// encryptedAdditionalData returns the additional data for a chunk.
+func encryptedAdditionalData(last bool) []byte {
+
+	if last {
+		return []byte{1}
+	}
+
+	return []byte{0}
+}
+
+// encryptedFile is an open encrypted file, which decrypts chunks as they are
+// read. The most recently decrypted chunk is kept for subsequent reads.
+type encryptedFile struct {
+	http.File
+	info      encryptedFileInfo
+	aead      cipher.AEAD
+	prefix    []byte
+	chunkSize int64
+	size      int64
+	offset    int64
+	chunk     int64
+	plaintext []byte
+}
+
+// Read returns plaintext from the current offset, decrypting the chunk that
+// holds it unless it is the one last decrypted.
+func (f *encryptedFile) Read(p []byte) (int, error) {
+
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	index := f.offset / f.chunkSize
+
+	if index != f.chunk {
+
+		if err := f.load(index); err != nil {
+			return 0, err
+		}
+	}
+
+	start := f.offset - index*f.chunkSize
+
+	if start >= int64(len(f.plaintext)) {
+		return 0, io.ErrUnexpectedEOF
+	}
+
+	n := copy(p, f.plaintext[start:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+// Seek sets the offset in the plaintext of the next Read. Nothing is read or
+// decrypted until then.
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+
+	switch whence {
+
+	case io.SeekCurrent:
+		offset += f.offset
+
+	case io.SeekEnd:
+		offset += f.size
+	}
+
+	if offset < 0 {
+		return 0, errors.New("handlers: negative seek offset")
+	}
+
+	f.offset = offset
+	return offset, nil
+}
+
+// Stat returns the file's information with the size of its plaintext.
+func (f *encryptedFile) Stat() (fs.FileInfo, error) {
+
+	return f.info, nil
+}
+
+// load reads and decrypts the chunk with the given index.
+func (f *encryptedFile) load(index int64) error {
+
+	sealedSize := f.chunkSize + int64(encryptedOverhead)
+	sealed := make([]byte, sealedSize)
+
+	_, err := f.File.Seek(int64(encryptedHeaderSize)+index*sealedSize, io.SeekStart)
+
+	if err != nil {
+		return err
+	}
+
+	n, err := io.ReadFull(f.File, sealed)
+
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	last := (index+1)*f.chunkSize >= f.size
+	plaintext, err := f.aead.Open(sealed[:0], encryptedNonce(f.prefix, uint32(index)),
+		sealed[:n], encryptedAdditionalData(last))
+
+	if err != nil {
+		return fmt.Errorf("handlers: failed to decrypt chunk %d: %s", index, err)
+	}
+
+	f.chunk = index
+	f.plaintext = plaintext
+	return nil
+}
+
+// encryptedFileInfo reports the size of the plaintext of an encrypted file.
+type encryptedFileInfo struct {
+	fs.FileInfo
+	size int64
+}
+
+// Size returns the size of the plaintext rather than of the stored file.
+func (i encryptedFileInfo) Size() int64 {
+
+	return i.size
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test EncryptFile and EncryptedFileSystem
+func TestEncryptedFileSystem(t *testing.T) {
+
+	var (
+		fs        *EncryptedFileSystem
+		key       []byte
+		plaintext string
+		encrypted bytes.Buffer
+		file      http.File
+		contents  []byte
+		err       error
+	)
+
+	// Encrypt a file spanning several chunks
+	key = bytes.Repeat([]byte{7}, 32)
+	plaintext = strings.Repeat("0123456789", 10)
+
+	if err = EncryptFile(&encrypted, strings.NewReader(plaintext), key, 16); err != nil {
+		t.Fatalf("Expected no error from EncryptFile. Got: %s", err)
+	}
+
+	sealed := encrypted.Bytes()
+	fs = NewEncryptedFileSystem(http.FS(fstest.MapFS{
+		"file.txt":      {Data: sealed},
+		"truncated.txt": {Data: sealed[:len(sealed)-32]},
+		"plain.txt":     {Data: []byte(plaintext)},
+	}), StaticKey(key))
+
+	// Test reading the whole file
+	file, err = fs.Open("/file.txt")
+
+	if err != nil {
+		t.Fatalf("Expected no error from EncryptedFileSystem. Got: %s", err)
+	}
+
+	contents, err = io.ReadAll(file)
+
+	if err != nil || string(contents) != plaintext {
+		t.Errorf("Expected the plaintext from EncryptedFileSystem. Got: %s %v",
+			contents, err)
+	}
+
+	// Check the reported size is the size of the plaintext
+	if finfo, _ := file.Stat(); finfo.Size() != int64(len(plaintext)) {
+		t.Errorf("Expected size %d from EncryptedFileSystem. Got: %d",
+			len(plaintext), finfo.Size())
+	}
+
+	// Test reading a range from the middle of the file
+	file.Seek(35, io.SeekStart)
+	contents = make([]byte, 10)
+	io.ReadFull(file, contents)
+	file.Close()
+
+	if string(contents) != "5678901234" {
+		t.Errorf("Expected \"5678901234\" from EncryptedFileSystem. Got: %s", contents)
+	}
+
+	// Test a file truncated at a chunk boundary fails to decrypt
+	file, err = fs.Open("/truncated.txt")
+
+	if err == nil {
+		_, err = io.ReadAll(file)
+		file.Close()
+	}
+
+	if err == nil {
+		t.Errorf("Expected an error from EncryptedFileSystem for a truncated file")
+	}
+
+	// Test an unencrypted file is rejected
+	if _, err = fs.Open("/plain.txt"); err == nil {
+		t.Errorf("Expected an error from EncryptedFileSystem for a plaintext file")
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"time"
+)
+
+// SetExtra sets values passed to the handler's template with every error, such
+// as a support email address or the site's status page, which the template can
+// access with tags like {{.Extra.support}}. The map is not copied, and must
+// not be changed while the handler is serving requests. By default no values
+// are passed.
+func (h *ErrorHandler) SetExtra(extra map[string]interface{}) {
+
+	h.extra = extra
+}
+
+// SetClock sets the clock used for the time of each error passed to the
+// template. By default the handler uses SystemClock.
+func (h *ErrorHandler) SetClock(clock Clock) {
+
+	h.clock = clock
+}
+
+// WithExtra sets values passed to the handler's template with every error, as
+// SetExtra does.
+func WithExtra(extra map[string]interface{}) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.SetExtra(extra)
+	}
+}
+
+// now returns the current time from the handler's clock.
+func (h *ErrorHandler) now() time.Time {
+
+	if h.clock == nil {
+		return SystemClock.Now()
+	}
+
+	return h.clock.Now()
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the request data passed to ErrorHandler templates
+func TestErrorTemplateData(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(
+		`{{.Method}} {{.Path}} at {{.Time.Format "2006-01-02 15:04"}}: ` +
+			`{{.ErrorMessage}} ({{.ErrorID}}) {{.Extra.support}}`))
+
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Sorry"),
+		WithExtra(map[string]interface{}{"support": "help@example.com"}))
+	h.SetClock(NewFixedClock(time.Date(2030, 1, 1, 12, 30, 0, 0, time.UTC)))
+	h.SetRequestIDHeader("X-Request-ID")
+
+	// Check the request's details are passed to the template
+	response = httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/orders/<new>", nil)
+	request.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(response, request)
+
+	expected := "POST /orders/&lt;new&gt; at 2030-01-01 12:30: Sorry (req-1) help@example.com"
+
+	if body := response.Body.String(); body != expected {
+		t.Errorf("Expected %q. Got: %q", expected, body)
+	}
+
+	// Check the method and path are empty without a request
+	h = NewErrorHandler(template.Must(template.New("error").Parse(`[{{.Method}}][{{.Path}}]`)))
+	response = httptest.NewRecorder()
+	h.ServeError(response, "Detailed error")
+
+	if body := response.Body.String(); body != "[][]" {
+		t.Errorf("Expected no method or path without a request. Got: %q", body)
+	}
+
+	// Check ServeErrorRequest passes the request's details to the template
+	h = NewErrorHandler(errorTemplate, WithDisplayErrors(true))
+	h.SetClock(NewFixedClock(time.Date(2030, 1, 1, 12, 30, 0, 0, time.UTC)))
+	h.SetRequestIDHeader("X-Request-ID")
+	response = httptest.NewRecorder()
+	request = httptest.NewRequest("GET", "/orders/1", nil)
+	request.Header.Set("X-Request-ID", "req-2")
+	h.ServeErrorRequest(response, request, "Upstream failed", http.StatusBadGateway)
+
+	expected = "GET /orders/1 at 2030-01-01 12:30: Upstream failed (req-2) "
+
+	if body := response.Body.String(); body != expected || response.Code != http.StatusBadGateway {
+		t.Errorf("Expected %q with StatusBadGateway. Got: %d %q", expected, response.Code, body)
+	}
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+// requestIDPattern matches the request ids accepted from a request header.
+// Other values are replaced with a generated id, so that a client cannot
+// inject markup or log lines through the header.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// SetRequestIDHeader sets the name of a request header, such as X-Request-ID,
+// whose value is used as the error id of each error served for a request, so
+// that the id matches the one recorded by a proxy or by the application's own
+// logs. Values longer than 128 characters, or containing characters other
+// than letters, digits, dots, colons, underscores and hyphens, are ignored.
+// If the header is not set, or the error is served without a request, an id
+// is generated. By default ids are always generated.
+func (h *ErrorHandler) SetRequestIDHeader(name string) {
+
+	h.requestIDHeader = name
+}
+
+// SetRandom sets the random source used to generate error ids. By default
+// ids are read from crypto/rand. Tests can pass a SeededRandom so that the
+// ids are reproducible.
+func (h *ErrorHandler) SetRandom(random io.Reader) {
+
+	h.random = random
+}
+
+// errorID returns the id of an error served for the request, which is nil
+// when the handler is called without one. An error is returned if the random
+// source fails, rather than an id that every such error would share.
+func (h *ErrorHandler) errorID(r *http.Request) (string, error) {
+
+	if r != nil && h.requestIDHeader != "" {
+
+		if id := r.Header.Get(h.requestIDHeader); requestIDPattern.MatchString(id) {
+			return id, nil
+		}
+	}
+
+	random := h.random
+
+	if random == nil {
+		random = cryptoRandom
+	}
+
+	id := make([]byte, 8)
+
+	if _, err := io.ReadFull(random, id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// Test ErrorHandler error ids
+func TestErrorHandlerErrorID(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(`{{.ErrorMessage}} ({{.ErrorID}})`))
+
+	// Check ids from the same seed are reproducible
+	first := NewErrorHandler(errorTemplate, WithDefaultMessage("Error"))
+	first.SetRandom(SeededRandom(1))
+	second := NewErrorHandler(errorTemplate, WithDefaultMessage("Error"))
+	second.SetRandom(SeededRandom(1))
+
+	if a, b := serveBody(first, "/"), serveBody(second, "/"); string(a) != string(b) {
+		t.Errorf("Expected the same id from the same seed. Got: %s and %s", a, b)
+	}
+
+	if a, b := serveBody(first, "/"), serveBody(first, "/"); string(a) == string(b) {
+		t.Errorf("Expected a new id for each error. Got: %s twice", a)
+	}
+
+	// Check a failing random source does not give errors a shared id
+	failing := NewErrorHandler(errorTemplate, WithDefaultMessage("Error"))
+	failing.SetRandom(iotest.ErrReader(errors.New("no entropy")))
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	failing.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError || strings.Contains(response.Body.String(), "Error (") {
+		t.Errorf("Expected a plain 500 without randomness. Got: %d %q",
+			response.Code, response.Body.String())
+	}
+
+	// Test ServeHTTP with a request id header
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Error"))
+	h.SetRandom(SeededRandom(1))
+	h.SetRequestIDHeader("X-Request-ID")
+
+	// Generate the ids expected when the header is not used
+	reference := NewErrorHandler(errorTemplate, WithDefaultMessage("Error"))
+	reference.SetRandom(SeededRandom(1))
+
+	tests := []struct {
+		requestID string
+		body      string
+	}{
+		{"abc-123", "Error (abc-123)"},
+		{"<script>", string(serveBody(reference, "/"))},
+		{"", string(serveBody(reference, "/"))},
+	}
+
+	for _, test := range tests {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+		request.Header.Set("X-Request-ID", test.requestID)
+		h.ServeHTTP(response, request)
+
+		// Check valid request ids are used and others are replaced
+		if body := response.Body.String(); body != test.body {
+			t.Errorf("Expected %q for request id %q. Got: %q", test.body, test.requestID, body)
+		}
+	}
+}
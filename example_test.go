@@ -0,0 +1,126 @@
+package handlers_test
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing/fstest"
+
+	"github.com/olihawkins/handlers"
+)
+
+// A NotFoundHandler serves a templated 404 page for any request.
+func ExampleNotFoundHandler() {
+
+	tmpl := template.Must(template.New("notfound").Parse(
+		"<p>{{.Path}} was not found</p>"))
+	nfh := handlers.NewNotFoundHandler(tmpl)
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("GET", "/missing", nil)
+	nfh.ServeHTTP(response, request)
+
+	fmt.Println(response.Code, response.Body.String())
+	// Output: 404 <p>/missing was not found</p>
+}
+
+// An ErrorHandler is usually called from inside another handler when
+// something goes wrong.
+func ExampleErrorHandler_ServeError() {
+
+	tmpl := template.Must(template.New("error").Parse(
+		"<p>{{.ErrorMessage}}</p>"))
+	eh := handlers.NewErrorHandler(tmpl, handlers.WithDefaultMessage("Something went wrong"))
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		err := errors.New("database unavailable")
+		eh.ServeError(w, err.Error())
+	})
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	fmt.Println(response.Code, response.Body.String())
+	// Output: 500 <p>Something went wrong</p>
+}
+
+// A FileHandler serves files and responds to requests for missing files with
+// its NotFoundHandler.
+func ExampleFileHandler() {
+
+	site := fstest.MapFS{
+		"index.html": {Data: []byte("Home")},
+	}
+
+	fh := handlers.NewFileSystemHandler("/", http.FS(site),
+		handlers.NewDefaultNotFoundHandler())
+
+	for _, target := range []string{"/", "/missing.html"} {
+
+		response := httptest.NewRecorder()
+		fh.ServeHTTP(response, httptest.NewRequest("GET", target, nil))
+		fmt.Println(target, response.Code)
+	}
+
+	// Output:
+	// / 200
+	// /missing.html 404
+}
+
+// FeatureFlags evaluate flags for each request and pass them to the
+// package's templates.
+func ExampleFeatureFlags_Handler() {
+
+	flags := handlers.NewFeatureFlags(map[string]int{"new_design": 100})
+	tmpl := template.Must(template.New("notfound").Parse(
+		"{{if .Flags.new_design}}new{{else}}old{{end}} 404"))
+
+	handler := flags.Handler(handlers.NewNotFoundHandler(tmpl))
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/missing", nil))
+
+	fmt.Println(response.Body.String())
+	// Output: new 404
+}
+
+// An AnalyticsHandler counts the page views sent to it as beacons.
+func ExampleAnalyticsHandler() {
+
+	ah, _ := handlers.NewAnalyticsHandler("", 0)
+	defer ah.Close()
+
+	beacon := `{"page": "https://example.com/about", "referrer": "https://search.example/"}`
+	response := httptest.NewRecorder()
+	ah.ServeHTTP(response, httptest.NewRequest("POST", "/analytics", strings.NewReader(beacon)))
+
+	report := ah.Report()
+	fmt.Println(response.Code, report.Pages["/about"], report.Referrers["search.example"])
+	// Output: 204 1 1
+}
+
+// The built-in templates can be themed and branded without writing a
+// template.
+func ExampleBrandedNotFoundTemplate() {
+
+	tmpl, err := handlers.BrandedNotFoundTemplate(handlers.ThemeBranded, handlers.Branding{
+		SiteName:    "Example",
+		AccentColor: "#0a7",
+	})
+
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	response := httptest.NewRecorder()
+	handlers.NewNotFoundHandler(tmpl).ServeHTTP(response,
+		httptest.NewRequest("GET", "/missing", nil))
+
+	fmt.Println(strings.Contains(response.Body.String(), "<title>Not Found - Example</title>"))
+	// Output: true
+}
@@ -0,0 +1,152 @@
+/*
+Command demo is a small server that wires the handlers package together.
+
+Usage:
+
+	go run ./examples/demo [-addr :8000]
+
+It serves an embedded static site with a FileHandler, a JSON API whose
+missing endpoints get JSON 404s, and themed error and 404 pages from the
+built-in templates, with every request logged. The same server is exercised
+by the demo's tests, so it doubles as an integration test of the composed
+handlers.
+*/
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/olihawkins/handlers"
+)
+
+// site holds the static site served by the demo.
+//
+//go:embed site
+var site embed.FS
+
+// main starts the demo server on the address given with -addr.
+func main() {
+
+	addr := flag.String("addr", ":8000", "address to listen on")
+	flag.Parse()
+
+	server, err := newServer()
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("Serving the demo on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, logRequests(server)))
+}
+
+// newServer returns the demo's handler.
+func newServer() (http.Handler, error) {
+
+	branding := handlers.Branding{
+		SiteName:    "Handlers demo",
+		AccentColor: "#0a7a5c",
+		FooterLinks: []handlers.BrandingLink{{Text: "Home", URL: "/"}},
+	}
+
+	notFoundTemplate, err := handlers.BrandedNotFoundTemplate(handlers.ThemeBranded, branding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	errorTemplate, err := handlers.BrandedErrorTemplate(handlers.ThemeBranded, branding)
+
+	if err != nil {
+		return nil, err
+	}
+
+	nfh := handlers.NewNotFoundHandler(notFoundTemplate)
+	eh := handlers.NewErrorHandler(errorTemplate)
+	nfh.SetCharset("utf-8")
+	eh.SetCharset("utf-8")
+
+	content, err := fs.Sub(site, "site")
+
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", handlers.NewFileSystemHandler("/", http.FS(content), nfh))
+	mux.Handle("/api/", newAPI(eh))
+
+	handlers.SetServerHeader("handlers-demo")
+
+	return mux, nil
+}
+
+// newAPI returns the demo's JSON API. Missing endpoints get a JSON 404 rather
+// than the site's HTML 404 page, and failures are reported with the
+// ErrorHandler.
+func newAPI(eh *handlers.ErrorHandler) http.Handler {
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/api/time", func(w http.ResponseWriter, r *http.Request) {
+
+		writeJSON(w, http.StatusOK, map[string]string{
+			"time": time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+
+	mux.HandleFunc("/api/fail", func(w http.ResponseWriter, r *http.Request) {
+
+		eh.ServeError(w, errors.New("the demo endpoint always fails").Error())
+	})
+
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"error": "not found",
+			"path":  r.URL.Path,
+		})
+	})
+
+	return mux
+}
+
+// writeJSON writes value to the response as JSON with the given status.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// statusRecorder records the status code written to a response.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+// WriteHeader records the status before writing it.
+func (r *statusRecorder) WriteHeader(status int) {
+
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs the method, path, status and duration of each request.
+func logRequests(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, recorder.status, time.Since(start))
+	})
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the demo server end to end
+func TestDemo(t *testing.T) {
+
+	server, err := newServer()
+
+	if err != nil {
+		t.Fatalf("Expected no error from newServer. Got: %s", err)
+	}
+
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		body        string
+	}{
+		{"/", http.StatusOK, "text/html", "<h1>Handlers demo</h1>"},
+		{"/about/", http.StatusOK, "text/html", "<h1>About</h1>"},
+		{"/about", http.StatusFound, "", ""},
+		{"/missing.html", http.StatusNotFound, "text/html", "<title>Not Found - Handlers demo</title>"},
+		{"/api/time", http.StatusOK, "application/json", `"time"`},
+		{"/api/missing", http.StatusNotFound, "application/json", `"error":"not found"`},
+		{"/api/fail", http.StatusInternalServerError, "text/html", "Sorry, something went wrong"},
+	}
+
+	for _, test := range tests {
+
+		// Test the server on the path
+		response := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", test.path, nil)
+		logRequests(server).ServeHTTP(response, request)
+
+		// Check the status, content type and body
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+
+		if contentType := response.Header().Get("Content-Type"); !strings.HasPrefix(contentType, test.contentType) {
+			t.Errorf("Expected Content-Type %s for %s. Got: %s",
+				test.contentType, test.path, contentType)
+		}
+
+		if !strings.Contains(response.Body.String(), test.body) {
+			t.Errorf("Expected %q in the body for %s. Got: %s",
+				test.body, test.path, response.Body.String())
+		}
+
+		// Check the Server header is set by the package's handlers
+		if test.path != "/api/time" && test.path != "/api/missing" &&
+			response.Header().Get("Server") != "handlers-demo" {
+
+			t.Errorf("Expected the demo Server header for %s. Got: %q",
+				test.path, response.Header().Get("Server"))
+		}
+	}
+}
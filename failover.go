@@ -0,0 +1,181 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// FailoverFileSystem is an http.FileSystem that chains several backends. Each
+// file is opened from the first backend that has it, so content can be served
+// from a mix of stores while it is migrated between them, and a backend that
+// fails or times out is skipped in favour of the next. The backend that
+// opened a file can be found with FileBackend, and when the file system is
+// served by a FileHandler it counts how many responses each backend has
+// served.
+type FailoverFileSystem struct {
+	backends []failoverBackend
+	mutex    sync.Mutex
+	served   map[string]int64
+}
+
+// failoverBackend is a named backend with the time allowed to open a file.
+type failoverBackend struct {
+	name       string
+	fileSystem http.FileSystem
+	timeout    time.Duration
+}
+
+// failoverFile is a file opened by a FailoverFileSystem, which records the
+// name of the backend that opened it and the file system to count it in.
+type failoverFile struct {
+	http.File
+	backend    string
+	fileSystem *FailoverFileSystem
+}
+
+// backendFile is implemented by the files of file systems that record which
+// backend served them, so that FileHandler can report it in X-Debug-Backend.
+type backendFile interface {
+	backendName() string
+}
+
+// openResult holds the return values of a backend's Open method.
+type openResult struct {
+	file http.File
+	err  error
+}
+
+// NewFailoverFileSystem returns a new FailoverFileSystem with no backends.
+func NewFailoverFileSystem() *FailoverFileSystem {
+
+	return &FailoverFileSystem{
+		served: make(map[string]int64),
+	}
+}
+
+// Add adds a backend with the given name to the end of the chain. If timeout
+// is greater than zero, the backend is skipped when opening a file takes
+// longer than timeout. Backends should be added before the file system is used.
+func (fs *FailoverFileSystem) Add(name string, fileSystem http.FileSystem, timeout time.Duration) {
+
+	fs.backends = append(fs.backends, failoverBackend{
+		name:       name,
+		fileSystem: fileSystem,
+		timeout:    timeout,
+	})
+}
+
+// Open opens the named file from the first backend that can open it. If no
+// backend has the file the error is os.ErrNotExist, otherwise it is the error
+// from the last backend that failed.
+func (fs *FailoverFileSystem) Open(name string) (http.File, error) {
+
+	err := error(os.ErrNotExist)
+
+	for _, backend := range fs.backends {
+
+		file, openErr := backend.open(name)
+
+		if openErr == nil {
+			return &failoverFile{File: file, backend: backend.name, fileSystem: fs}, nil
+		}
+
+		if !errors.Is(openErr, os.ErrNotExist) {
+			err = openErr
+		}
+	}
+
+	return nil, err
+}
+
+// Served returns the number of responses each backend has served, by name.
+// A response is counted when a FileHandler serves a file's contents, so files
+// opened only to look for index pages, variants or other candidates are not.
+func (fs *FailoverFileSystem) Served() map[string]int64 {
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	served := make(map[string]int64, len(fs.served))
+
+	for name, count := range fs.served {
+		served[name] = count
+	}
+
+	return served
+}
+
+// FileBackend returns the name of the backend that opened a file returned by
+// a FailoverFileSystem, or an empty string for any other file.
+func FileBackend(file http.File) string {
+
+	if file, ok := file.(*failoverFile); ok {
+		return file.backend
+	}
+
+	return ""
+}
+
+// backendName returns the name of the backend that opened the file, followed
+// by where the backend's own file came from if it records that too, as in
+// "mirror (stale cache)".
+func (f *failoverFile) backendName() string {
+
+	if file, ok := f.File.(backendFile); ok {
+		return f.backend + " (" + file.backendName() + ")"
+	}
+
+	return f.backend
+}
+
+// countServed counts a response served from a file against the backend of the
+// FailoverFileSystem that opened it, if any.
+func countServed(file http.File) {
+
+	if file, ok := file.(*failoverFile); ok {
+
+		file.fileSystem.mutex.Lock()
+		file.fileSystem.served[file.backend]++
+		file.fileSystem.mutex.Unlock()
+	}
+}
+
+// open opens the named file from the backend within the backend's timeout.
+func (b failoverBackend) open(name string) (http.File, error) {
+
+	if b.timeout <= 0 {
+		return b.fileSystem.Open(name)
+	}
+
+	results := make(chan openResult, 1)
+
+	go func() {
+		file, err := b.fileSystem.Open(name)
+		results <- openResult{file, err}
+	}()
+
+	timer := time.NewTimer(b.timeout)
+	defer timer.Stop()
+
+	select {
+
+	case result := <-results:
+
+		return result.file, result.err
+
+	case <-timer.C:
+
+		// Close the file if the backend opens it after the timeout
+		go func() {
+			if result := <-results; result.err == nil {
+				result.file.Close()
+			}
+		}()
+
+		return nil, fmt.Errorf("handlers: backend %q timed out opening %s", b.name, name)
+	}
+}
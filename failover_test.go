@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// slowFileSystem is an http.FileSystem that takes too long to open files.
+type slowFileSystem struct{}
+
+func (slowFileSystem) Open(name string) (http.File, error) {
+
+	time.Sleep(100 * time.Millisecond)
+	return nil, os.ErrNotExist
+}
+
+// Test FailoverFileSystem functions and methods
+func TestFailoverFileSystem(t *testing.T) {
+
+	var (
+		fs       *FailoverFileSystem
+		file     http.File
+		contents []byte
+		err      error
+	)
+
+	// Chain a slow backend, a primary store and a secondary store
+	fs = NewFailoverFileSystem()
+	fs.Add("slow", slowFileSystem{}, 10*time.Millisecond)
+	fs.Add("primary", http.FS(fstest.MapFS{
+		"both.html": {Data: []byte("Primary")},
+	}), 0)
+	fs.Add("secondary", http.FS(fstest.MapFS{
+		"both.html":      {Data: []byte("Secondary")},
+		"secondary.html": {Data: []byte("Secondary")},
+	}), 0)
+
+	// Test Open prefers the earlier backend
+	file, err = fs.Open("/both.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error from FailoverFileSystem. Got: %s", err)
+	}
+
+	contents, _ = io.ReadAll(file)
+	file.Close()
+
+	if string(contents) != "Primary" || FileBackend(file) != "primary" {
+		t.Errorf("Expected \"Primary\" from the primary backend. Got: %s from %s",
+			contents, FileBackend(file))
+	}
+
+	// Test Open falls through to the later backend
+	file, err = fs.Open("/secondary.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error from FailoverFileSystem. Got: %s", err)
+	}
+
+	file.Close()
+
+	if FileBackend(file) != "secondary" {
+		t.Errorf("Expected a file from the secondary backend. Got: %s",
+			FileBackend(file))
+	}
+
+	// Test Open reports the timeout when no other backend has the file
+	if _, err = fs.Open("/missing.html"); err == nil || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected the timeout error from FailoverFileSystem. Got: %v", err)
+	}
+
+	// Check files opened directly are not counted as served
+	if served := fs.Served(); len(served) != 0 {
+		t.Errorf("Expected no responses counted for opened files. Got: %v", served)
+	}
+
+	// Test a FileHandler serving the file system
+	h := NewFileSystemHandler("/", fs, MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html")))
+
+	for _, path := range []string{"/both.html", "/both.html", "/secondary.html", "/missing.html", "/"} {
+
+		request, _ := http.NewRequest("GET", path, nil)
+		h.ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	// Check only the files served are counted
+	served := fs.Served()
+
+	if served["primary"] != 2 || served["secondary"] != 1 || served["slow"] != 0 {
+		t.Errorf("Expected two responses from primary and one from secondary. Got: %v", served)
+	}
+}
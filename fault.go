@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// FaultKind is a kind of fault injected by a FaultHandler.
+type FaultKind int
+
+const (
+	// FaultLatency delays the response by the fault's Delay before it is
+	// served.
+	FaultLatency FaultKind = iota
+
+	// FaultError answers the request with the fault's Status, or 500 if it
+	// has none, instead of serving it.
+	FaultError
+
+	// FaultTruncate serves the response but stops after the fault's Bytes
+	// bytes of the body, and closes the connection, so the client receives
+	// a truncated body.
+	FaultTruncate
+
+	// FaultReset closes the connection without sending a response.
+	FaultReset
+)
+
+// Fault describes a fault injected by a FaultHandler. Probability is the
+// chance, from 0 to 1, that the fault is injected into a matching request.
+type Fault struct {
+	Kind        FaultKind
+	Probability float64
+	Delay       time.Duration
+	Status      int
+	Bytes       int64
+}
+
+// faultRule is a fault injected into requests under a path prefix.
+type faultRule struct {
+	prefix string
+	fault  Fault
+}
+
+// FaultHandler injects faults into the responses of another handler, so that
+// applications composing the package's handlers can test how their clients,
+// proxies and fallbacks behave when a server is slow, fails, or drops
+// connections. Faults are added for path prefixes and injected at random
+// with the given probabilities. Latency faults are applied first and add up;
+// then the first error, truncation or reset fault that fires, if any, is
+// applied. The handler is intended for development and testing and must not
+// be used in production.
+type FaultHandler struct {
+	next   http.Handler
+	rules  []faultRule
+	random io.Reader
+}
+
+// NewFaultHandler returns a new FaultHandler in front of next, which injects
+// no faults until they are added with AddFault.
+func NewFaultHandler(next http.Handler) *FaultHandler {
+
+	return &FaultHandler{
+		next:   next,
+		random: cryptoRandom,
+	}
+}
+
+// AddFault adds a fault injected into requests whose paths start with prefix.
+// The prefix "/" matches every request.
+func (h *FaultHandler) AddFault(prefix string, fault Fault) {
+
+	h.rules = append(h.rules, faultRule{prefix: prefix, fault: fault})
+}
+
+// SetRandom sets the random source used to decide whether faults are
+// injected. By default the handler reads from crypto/rand. Tests can pass a
+// SeededRandom so that the faults injected are reproducible.
+func (h *FaultHandler) SetRandom(random io.Reader) {
+
+	h.random = random
+}
+
+// ServeHTTP serves the request with the next handler, injecting any faults
+// that fire for it.
+func (h *FaultHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	var (
+		delay    time.Duration
+		terminal *Fault
+	)
+
+	for i := range h.rules {
+
+		rule := &h.rules[i]
+
+		if !strings.HasPrefix(r.URL.Path, rule.prefix) || !h.fires(rule.fault.Probability) {
+			continue
+		}
+
+		if rule.fault.Kind == FaultLatency {
+			delay += rule.fault.Delay
+		} else if terminal == nil {
+			terminal = &rule.fault
+		}
+	}
+
+	if delay > 0 {
+
+		timer := time.NewTimer(delay)
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return
+		}
+	}
+
+	if terminal == nil {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	switch terminal.Kind {
+
+	case FaultError:
+
+		status := terminal.Status
+
+		if status == 0 {
+			status = http.StatusInternalServerError
+		}
+
+		http.Error(w, http.StatusText(status), status)
+
+	case FaultTruncate:
+
+		h.next.ServeHTTP(&truncatingWriter{ResponseWriter: w, remaining: terminal.Bytes}, r)
+
+		// Send what was written, then abort the response so that the client
+		// sees the body end early, rather than as complete
+		http.NewResponseController(w).Flush()
+		panic(http.ErrAbortHandler)
+
+	case FaultReset:
+
+		resetConnection(w)
+	}
+}
+
+// fires reports whether a fault with the given probability is injected.
+func (h *FaultHandler) fires(probability float64) bool {
+
+	if probability <= 0 {
+		return false
+	}
+
+	if probability >= 1 {
+		return true
+	}
+
+	var value [8]byte
+
+	if _, err := io.ReadFull(h.random, value[:]); err != nil {
+		return false
+	}
+
+	// Use the top 53 bits for a uniform value in [0, 1)
+	return float64(binary.BigEndian.Uint64(value[:])>>11)/(1<<53) < probability
+}
+
+// resetConnection closes the client's connection without a response, with a
+// TCP reset where possible.
+func resetConnection(w http.ResponseWriter) {
+
+	conn, _, err := http.NewResponseController(w).Hijack()
+
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		tcp.SetLinger(0)
+	}
+
+	conn.Close()
+}
+
+// truncatingWriter passes a response through to the client until a number of
+// bytes of its body have been written, and discards the rest.
+type truncatingWriter struct {
+	http.ResponseWriter
+	remaining int64
+}
+
+// Write passes p through until the byte limit is reached, then reports the
+// rest as written without sending it, so that the handler carries on and the
+// client sees a short body.
+func (w *truncatingWriter) Write(p []byte) (int, error) {
+
+	if w.remaining <= 0 {
+		return len(p), nil
+	}
+
+	if int64(len(p)) > w.remaining {
+
+		n, err := w.ResponseWriter.Write(p[:w.remaining])
+		w.remaining -= int64(n)
+
+		if err != nil {
+			return n, err
+		}
+
+		return len(p), nil
+	}
+
+	n, err := w.ResponseWriter.Write(p)
+	w.remaining -= int64(n)
+	return n, err
+}
+
+// Unwrap returns the response writer being truncated, so that
+// http.ResponseController can still flush the short body or hijack the
+// connection.
+func (w *truncatingWriter) Unwrap() http.ResponseWriter {
+
+	return w.ResponseWriter
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the FaultHandler
+func TestFaultHandler(t *testing.T) {
+
+	var (
+		h        *FaultHandler
+		response *httptest.ResponseRecorder
+	)
+
+	body := strings.Repeat("0123456789", 100)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Write([]byte(body))
+	})
+
+	h = NewFaultHandler(next)
+	h.SetRandom(SeededRandom(1))
+	h.AddFault("/slow/", Fault{Kind: FaultLatency, Probability: 1, Delay: 20 * time.Millisecond})
+	h.AddFault("/flaky/", Fault{Kind: FaultError, Probability: 0.5, Status: http.StatusBadGateway})
+	h.AddFault("/never/", Fault{Kind: FaultError, Probability: 0})
+	h.AddFault("/truncated/", Fault{Kind: FaultTruncate, Probability: 1, Bytes: 10})
+	h.AddFault("/reset/", Fault{Kind: FaultReset, Probability: 1})
+
+	// Check requests without faults are served normally
+	for _, path := range []string{"/", "/never/"} {
+
+		response = httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("GET", path, nil))
+
+		if response.Code != http.StatusOK || response.Body.String() != body {
+			t.Errorf("Expected %s to be served normally. Got: %d", path, response.Code)
+		}
+	}
+
+	// Check latency is added
+	start := time.Now()
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/slow/page", nil))
+
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond || response.Code != http.StatusOK {
+		t.Errorf("Expected a delayed 200. Got: %d after %s", response.Code, elapsed)
+	}
+
+	// Check errors are injected with their probability
+	failures := 0
+
+	for i := 0; i < 200; i++ {
+
+		response = httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("GET", "/flaky/page", nil))
+
+		if response.Code == http.StatusBadGateway {
+			failures++
+		}
+	}
+
+	if failures < 60 || failures > 140 {
+		t.Errorf("Expected about half of the requests to fail. Got: %d of 200", failures)
+	}
+
+	// Check truncated bodies and resets are seen by a real client
+	server := httptest.NewServer(h)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/truncated/page")
+
+	if err != nil {
+		t.Fatalf("Expected a response for a truncated body. Got: %s", err)
+	}
+
+	received, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if err == nil || string(received) != body[:10] {
+		t.Errorf("Expected a truncated body and a read error. Got: %q %v", received, err)
+	}
+
+	if _, err := http.Get(server.URL + "/reset/page"); err == nil {
+		t.Errorf("Expected an error for a reset connection")
+	}
+}
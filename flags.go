@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FlagCookieName is the name of the cookie holding the id used to place a
+// client in percentage rollouts, so that it sees the same flags on every
+// request.
+const FlagCookieName string = "handlers_flags"
+
+// flagsContextKey is the context key for the flags evaluated for a request.
+type flagsContextKey struct{}
+
+// FeatureFlags holds a set of named feature flags, each enabled for a
+// percentage of clients between 0 and 100. The flags are evaluated once per
+// request by the handler returned by Handler, which places the results in the
+// request context where they can be read with FlagEnabled and RequestFlags,
+// and where the package's templated handlers pass them to their templates.
+type FeatureFlags struct {
+	percentages map[string]int
+	random      io.Reader
+}
+
+// NewFeatureFlags returns a new FeatureFlags with the given percentages.
+func NewFeatureFlags(percentages map[string]int) *FeatureFlags {
+
+	flags := &FeatureFlags{
+		percentages: make(map[string]int),
+		random:      cryptoRandom,
+	}
+
+	for name, percentage := range percentages {
+		flags.percentages[name] = clampPercentage(percentage)
+	}
+
+	return flags
+}
+
+// LoadFeatureFlags returns a new FeatureFlags read from the JSON file at
+// flagsPath. The file holds an object mapping flag names to either a boolean,
+// to turn a flag on or off for everyone, or a percentage.
+func LoadFeatureFlags(flagsPath string) (*FeatureFlags, error) {
+
+	contents, err := os.ReadFile(flagsPath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+
+	if err = json.Unmarshal(contents, &values); err != nil {
+		return nil, err
+	}
+
+	percentages := make(map[string]int)
+
+	for name, value := range values {
+
+		switch value := value.(type) {
+
+		case bool:
+			percentages[name] = boolPercentage(value)
+
+		case float64:
+			percentages[name] = int(value)
+
+		default:
+			return nil, fmt.Errorf("handlers: invalid value for flag %q in %s", name, flagsPath)
+		}
+	}
+
+	return NewFeatureFlags(percentages), nil
+}
+
+// FeatureFlagsFromEnv returns a new FeatureFlags read from the environment
+// variables whose names start with prefix. The rest of the variable's name,
+// in lower case, is the name of the flag, and its value is "true", "false" or
+// a percentage. For example, with the prefix "FLAG_" the variable
+// FLAG_NEW_HEADER=25 enables the flag "new_header" for a quarter of clients.
+func FeatureFlagsFromEnv(prefix string) (*FeatureFlags, error) {
+
+	percentages := make(map[string]int)
+
+	for _, variable := range os.Environ() {
+
+		if !strings.HasPrefix(variable, prefix) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(variable, prefix), "=", 2)
+		name := strings.ToLower(parts[0])
+
+		if on, err := strconv.ParseBool(parts[1]); err == nil {
+			percentages[name] = boolPercentage(on)
+			continue
+		}
+
+		percentage, err := strconv.Atoi(parts[1])
+
+		if err != nil {
+			return nil, fmt.Errorf("handlers: invalid value for flag %q in environment", name)
+		}
+
+		percentages[name] = percentage
+	}
+
+	return NewFeatureFlags(percentages), nil
+}
+
+// SetRandom sets the random source used to generate client ids. By default
+// ids are read from crypto/rand. Tests can pass a SeededRandom so that new
+// clients are always placed in the same buckets.
+func (f *FeatureFlags) SetRandom(random io.Reader) {
+
+	f.random = random
+}
+
+// Handler returns a handler that evaluates the flags for each request and
+// places the results in the request context before calling next. Clients are
+// given an id in a cookie the first time they are seen, and a client's id
+// decides whether it falls within each flag's percentage.
+func (f *FeatureFlags) Handler(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := ""
+
+		if cookie, err := r.Cookie(FlagCookieName); err == nil {
+			id = cookie.Value
+		}
+
+		if id == "" {
+
+			var err error
+			id, err = newFlagID(f.random)
+
+			// Without an id the client cannot be placed in a bucket, so
+			// serve the request with every flag off
+			if err != nil {
+				ctx := context.WithValue(r.Context(), flagsContextKey{}, map[string]bool{})
+				next.ServeHTTP(w, r.WithContext(ctx))
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     FlagCookieName,
+				Value:    id,
+				Path:     "/",
+				MaxAge:   365 * 24 * 60 * 60,
+				HttpOnly: true,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		ctx := context.WithValue(r.Context(), flagsContextKey{}, f.Evaluate(id))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Evaluate returns whether each flag is enabled for the client with the
+// given id.
+func (f *FeatureFlags) Evaluate(id string) map[string]bool {
+
+	enabled := make(map[string]bool, len(f.percentages))
+
+	for name, percentage := range f.percentages {
+		enabled[name] = flagBucket(name, id) < percentage
+	}
+
+	return enabled
+}
+
+// RequestFlags returns the flags evaluated for the request, or an empty map if
+// the request has not passed through a FeatureFlags handler.
+func RequestFlags(r *http.Request) map[string]bool {
+
+	if r == nil {
+		return map[string]bool{}
+	}
+
+	if flags, ok := r.Context().Value(flagsContextKey{}).(map[string]bool); ok {
+		return flags
+	}
+
+	return map[string]bool{}
+}
+
+// FlagEnabled reports whether the named flag is enabled for the request.
+func FlagEnabled(r *http.Request, name string) bool {
+
+	return RequestFlags(r)[name]
+}
+
+// flagBucket returns the bucket between 0 and 99 that the client with the
+// given id falls into for the named flag. Hashing the flag name with the id
+// means each flag's rollout reaches a different set of clients.
+func flagBucket(name string, id string) int {
+
+	hash := fnv.New32a()
+	hash.Write([]byte(name + "\x00" + id))
+	return int(hash.Sum32() % 100)
+}
+
+// newFlagID returns a new client id read from the given random source.
+func newFlagID(random io.Reader) (string, error) {
+
+	id := make([]byte, 16)
+
+	if _, err := io.ReadFull(random, id); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(id), nil
+}
+
+// boolPercentage returns the percentage equivalent to a flag being on or off.
+func boolPercentage(on bool) int {
+
+	if on {
+		return 100
+	}
+
+	return 0
+}
+
+// clampPercentage limits a percentage to the range 0 to 100.
+func clampPercentage(percentage int) int {
+
+	if percentage < 0 {
+		return 0
+	}
+
+	if percentage > 100 {
+		return 100
+	}
+
+	return percentage
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+// Test FeatureFlags functions and methods
+func TestFeatureFlags(t *testing.T) {
+
+	var (
+		flags      *FeatureFlags
+		nfh        *NotFoundHandler
+		h          http.Handler
+		flagsPath  string
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+		err        error
+	)
+
+	// Load flags from a file
+	flagsPath = filepath.Join(t.TempDir(), "flags.json")
+	os.WriteFile(flagsPath, []byte(`{"on": true, "off": false, "half": 50}`), 0644)
+
+	flags, err = LoadFeatureFlags(flagsPath)
+
+	if err != nil {
+		t.Fatalf("Expected no error from LoadFeatureFlags. Got: %s", err)
+	}
+
+	// Check a percentage rollout reaches roughly the right share of clients
+	enabled := 0
+	random := SeededRandom(1)
+
+	for i := 0; i < 1000; i++ {
+
+		id, _ := newFlagID(random)
+
+		if flags.Evaluate(id)["half"] {
+			enabled++
+		}
+	}
+
+	if enabled < 400 || enabled > 600 {
+		t.Errorf("Expected about 500 of 1000 clients to get the half flag. Got: %d",
+			enabled)
+	}
+
+	// Test the flags reach a NotFoundHandler template through the context
+	nfh = NewNotFoundHandler(template.Must(template.New("notfound").Parse(
+		"{{if .Flags.on}}on{{end}}{{if .Flags.off}}off{{end}}")))
+	h = flags.Handler(nfh)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/path", nil)
+	h.ServeHTTP(response, request)
+
+	bodyString = response.Body.String()
+
+	if bodyString != "on" {
+		t.Errorf("Expected \"on\" from NotFoundHandler. Got: %s", bodyString)
+	}
+
+	// Check the client was given an id so it keeps the same flags
+	cookies := response.Result().Cookies()
+
+	if len(cookies) != 1 || cookies[0].Name != FlagCookieName {
+		t.Fatalf("Expected a %s cookie from the flags handler. Got: %v",
+			FlagCookieName, cookies)
+	}
+
+	// Check ids from a seeded random source are reproducible
+	flags.SetRandom(SeededRandom(42))
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+	first := response.Result().Cookies()
+
+	flags.SetRandom(SeededRandom(42))
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+	second := response.Result().Cookies()
+
+	if len(first) != 1 || len(second) != 1 || first[0].Value != second[0].Value {
+		t.Errorf("Expected the same id from the same seed. Got: %v and %v", first, second)
+	}
+
+	// Check a failing random source turns the flags off without an id
+	flags.SetRandom(iotest.ErrReader(errors.New("no entropy")))
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+
+	if response.Body.String() != "" || len(response.Result().Cookies()) != 0 {
+		t.Errorf("Expected no flags and no id without randomness. Got: %q %v",
+			response.Body.String(), response.Result().Cookies())
+	}
+
+	// Test the flags are read from the environment
+	t.Setenv("TESTFLAG_NEW_HEADER", "true")
+	t.Setenv("TESTFLAG_ROLLOUT", "0")
+
+	flags, err = FeatureFlagsFromEnv("TESTFLAG_")
+
+	if err != nil {
+		t.Fatalf("Expected no error from FeatureFlagsFromEnv. Got: %s", err)
+	}
+
+	evaluated := flags.Evaluate(cookies[0].Value)
+
+	if !evaluated["new_header"] || evaluated["rollout"] {
+		t.Errorf("Expected new_header on and rollout off. Got: %v", evaluated)
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"html/template"
+	"path/filepath"
+)
+
+// WithFuncs adds functions that the handler's template file can call, such as
+// helpers for asset URLs, translations or date formatting. The functions must
+// be known before the template is parsed, so the option applies to handlers
+// created with LoadErrorHandler or MustLoadErrorHandler, and to templates
+// parsed again by WithTemplateReloading. A template passed to NewErrorHandler
+// should be given its functions with Funcs before it is parsed. WithFuncs may
+// be used more than once, and later functions replace earlier ones with the
+// same name.
+func WithFuncs(funcs template.FuncMap) ErrorOption {
+
+	return func(h *ErrorHandler) {
+
+		if h.funcs == nil {
+			h.funcs = make(template.FuncMap, len(funcs))
+		}
+
+		for name, fn := range funcs {
+			h.funcs[name] = fn
+		}
+	}
+}
+
+// parseTemplateFile parses the template file at path, making the given
+// functions available to it. The template is named after the file, as it is
+// by template.ParseFiles.
+func parseTemplateFile(path string, funcs ...template.FuncMap) (*template.Template, error) {
+
+	t := template.New(filepath.Base(path))
+
+	for _, fm := range funcs {
+		t.Funcs(fm)
+	}
+
+	return t.ParseFiles(path)
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Test template functions in the Load constructors
+func TestLoadWithFuncs(t *testing.T) {
+
+	var (
+		dir   string
+		funcs template.FuncMap
+		body  string
+	)
+
+	dir = t.TempDir()
+	funcs = template.FuncMap{"upper": strings.ToUpper}
+
+	errorPath := filepath.Join(dir, "error.html")
+	notFoundPath := filepath.Join(dir, "notfound.html")
+	os.WriteFile(errorPath, []byte(`{{upper .ErrorMessage}}`), 0644)
+	os.WriteFile(notFoundPath, []byte(`{{upper .Path}}`), 0644)
+
+	// Check the functions are available to the templates
+	eh := MustLoadErrorHandler(errorPath, WithDefaultMessage("failed"), WithFuncs(funcs))
+
+	if body = string(serveBody(eh, "/")); body != "FAILED" {
+		t.Errorf("Expected the error template to call its function. Got: %q", body)
+	}
+
+	nfh := MustLoadNotFoundHandler(notFoundPath, funcs)
+
+	if body = string(serveBody(nfh, "/missing")); body != "/MISSING" {
+		t.Errorf("Expected the not found template to call its function. Got: %q", body)
+	}
+
+	// Check templates using unknown functions fail to load
+	if _, err := LoadErrorHandler(errorPath); err == nil {
+		t.Errorf("Expected an error loading a template without its functions")
+	}
+
+	if _, err := LoadNotFoundHandler(notFoundPath); err == nil {
+		t.Errorf("Expected an error loading a template without its functions")
+	}
+
+	// Check the functions survive reloading
+	eh = MustLoadErrorHandler(errorPath, WithDefaultMessage("failed"),
+		WithFuncs(funcs), WithTemplateReloading(true))
+	os.WriteFile(errorPath, []byte(`{{upper .ErrorMessage}}!`), 0644)
+
+	if body = string(serveBody(eh, "/")); body != "FAILED!" {
+		t.Errorf("Expected the reloaded template to call its function. Got: %q", body)
+	}
+
+	// Check later functions replace earlier ones
+	eh = MustLoadErrorHandler(errorPath, WithDefaultMessage("failed"), WithFuncs(funcs),
+		WithFuncs(template.FuncMap{"upper": strings.ToLower}))
+
+	if body = string(serveBody(eh, "/")); body != "failed!" {
+		t.Errorf("Expected the later function to be used. Got: %q", body)
+	}
+
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// Fuzz FileHandler's request path processing
+func FuzzFileHandlerPath(f *testing.F) {
+
+	var (
+		root   string = f.TempDir()
+		site   string = filepath.Join(root, "site")
+		secret string = "handlersFuzzSecret"
+	)
+
+	// Create a site with a file and a directory, and a file outside the site
+	// which must never be served
+	os.MkdirAll(filepath.Join(site, "sub"), 0755)
+	os.WriteFile(filepath.Join(site, "index.html"), []byte("Index"), 0644)
+	os.WriteFile(filepath.Join(site, "sub", "index.html"), []byte("Sub"), 0644)
+	os.WriteFile(filepath.Join(root, "secret.txt"), []byte(secret), 0644)
+
+	nfh := NewDefaultNotFoundHandler()
+
+	seeds := []string{
+		"/files/",
+		"/files/sub",
+		"/files//sub",
+		"/files/sub/index.html",
+		"/files/../secret.txt",
+		"/files/%2e%2e/secret.txt",
+		"/files/%2fsub",
+		"/files/sub%5c..%5c..%5csecret.txt",
+		"/files/con.txt",
+		"/files/\x00",
+		"//files//sub",
+		"//0000",
+	}
+
+	for _, seed := range seeds {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, target string) {
+
+		u, err := url.ParseRequestURI(target)
+
+		if err != nil {
+			return
+		}
+
+		for _, caseSensitivity := range []CaseSensitivity{CaseDefault, CaseInsensitive} {
+
+			h := NewFileHandler("/files/", site, nfh)
+			h.SetCaseSensitivity(caseSensitivity)
+
+			response := httptest.NewRecorder()
+			request := &http.Request{Method: "GET", URL: u, Header: http.Header{}}
+			h.ServeHTTP(response, request)
+
+			// Check nothing outside the site is served
+			if strings.Contains(response.Body.String(), secret) {
+				t.Fatalf("Served a file outside the site for %q", target)
+			}
+
+			// Check redirects stay on the same host
+			location := response.Header().Get("Location")
+
+			if strings.HasPrefix(location, "//") || strings.HasPrefix(location, "/\\") ||
+				strings.Contains(location, "://") {
+
+				t.Fatalf("Redirected off the site to %q for %q", location, target)
+			}
+		}
+	})
+}
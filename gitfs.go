@@ -0,0 +1,430 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxWebhookBody is the maximum size in bytes of a webhook request body.
+const maxWebhookBody int64 = 1 << 20
+
+// GitFileSystem is an http.FileSystem that serves the files of a git
+// repository at a given ref, without checking them out, for push-to-deploy
+// static hosting. The repository is usually a bare mirror of the site's
+// repository. Files are read with the git command, which must be installed,
+// and every file's modification time is the commit time of the ref, so
+// Last-Modified changes when new content is deployed. Update fetches new
+// commits and moves the file system to the ref's new commit, and
+// WebhookHandler calls it when a push is reported.
+type GitFileSystem struct {
+	repository string
+	ref        string
+	mutex      sync.RWMutex
+	commit     string
+	modTime    time.Time
+}
+
+// NewGitFileSystem returns a new GitFileSystem serving the files of the
+// repository at the given ref, such as "main" or "refs/tags/v1.2".
+func NewGitFileSystem(repository string, ref string) (*GitFileSystem, error) {
+
+	// A ref starting with a dash would be read by git as an option
+	if ref == "" || strings.HasPrefix(ref, "-") {
+		return nil, fmt.Errorf("handlers: invalid git ref %q", ref)
+	}
+
+	fs := &GitFileSystem{
+		repository: repository,
+		ref:        ref,
+	}
+
+	if err := fs.resolve(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+// Commit returns the commit currently being served.
+func (fs *GitFileSystem) Commit() string {
+
+	fs.mutex.RLock()
+	defer fs.mutex.RUnlock()
+
+	return fs.commit
+}
+
+// Update fetches from the repository's remotes, if it has any, and moves the
+// file system to the commit the ref now points to. Requests already being
+// served finish with the commit they started with.
+func (fs *GitFileSystem) Update() error {
+
+	remotes, err := fs.git("remote")
+
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(remotes)) > 0 {
+
+		if _, err := fs.git("fetch", "--quiet", "--all", "--prune"); err != nil {
+			return err
+		}
+	}
+
+	return fs.resolve()
+}
+
+// WebhookHandler returns a handler for push webhooks, which calls Update when
+// it receives a POST request. The request must carry an X-Hub-Signature-256
+// header with the HMAC-SHA256 of its body with secret, in the form sent by
+// GitHub, Gitea and others, and requests without a valid signature get a 401.
+// WebhookHandler panics if secret is empty, as the hook would otherwise let
+// anyone trigger updates.
+func (fs *GitFileSystem) WebhookHandler(secret []byte) http.Handler {
+
+	requireWebhookSecret(secret)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !acceptWebhook(w, r, secret) {
+			return
+		}
+
+		if err := fs.Update(); err != nil {
+			serveInternalError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// requireWebhookSecret panics if a webhook handler is created without a
+// secret to check its requests with.
+func requireWebhookSecret(secret []byte) {
+
+	if len(secret) == 0 {
+		panic("handlers: a webhook secret is required")
+	}
+}
+
+// acceptWebhook checks that a webhook request is a POST with a valid
+// signature, and responds with an error and returns false if it is not.
+func acceptWebhook(w http.ResponseWriter, r *http.Request, secret []byte) bool {
+
+	setServerHeader(w)
+
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return false
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebhookBody))
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return false
+	}
+
+	if len(secret) == 0 || !validWebhookSignature(secret, body, r.Header.Get("X-Hub-Signature-256")) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return false
+	}
+
+	return true
+}
+
+// validWebhookSignature reports whether signature is the HMAC-SHA256 of body
+// with secret, in the form "sha256=<hex>".
+func validWebhookSignature(secret []byte, body []byte, signature string) bool {
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(signature), []byte(expected))
+}
+
+// Open opens the named file or directory at the current commit.
+func (fs *GitFileSystem) Open(name string) (http.File, error) {
+
+	fs.mutex.RLock()
+	commit, modTime := fs.commit, fs.modTime
+	fs.mutex.RUnlock()
+
+	name = path.Clean("/" + name)
+
+	if name == "/" {
+		return fs.openDirectory(commit, name, modTime)
+	}
+
+	output, err := fs.git("ls-tree", "-z", "-l", commit, "--", strings.TrimPrefix(name, "/"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	entries := parseGitTree(output, modTime)
+
+	if len(entries) != 1 {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	entry := entries[0]
+
+	switch entry.objectType {
+
+	case "tree":
+
+		return fs.openDirectory(commit, name, modTime)
+
+	case "blob":
+
+		contents, err := fs.git("cat-file", "blob", entry.object)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return &gitFile{info: entry, reader: bytes.NewReader(contents)}, nil
+	}
+
+	return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+}
+
+// openDirectory opens the named directory at the given commit.
+func (fs *GitFileSystem) openDirectory(commit string, name string, modTime time.Time) (http.File, error) {
+
+	treeish := commit
+
+	if name != "/" {
+		treeish = commit + ":" + strings.TrimPrefix(name, "/")
+	}
+
+	output, err := fs.git("ls-tree", "-z", "-l", treeish)
+
+	if err != nil {
+		return nil, err
+	}
+
+	info := gitFileInfo{name: path.Base(name), objectType: "tree", modTime: modTime}
+
+	return &gitFile{
+		info:     info,
+		reader:   bytes.NewReader(nil),
+		children: parseGitTree(output, modTime),
+	}, nil
+}
+
+// resolve moves the file system to the commit the ref points to.
+func (fs *GitFileSystem) resolve() error {
+
+	output, err := fs.git("log", "-1", "--format=%H %ct", fs.ref, "--")
+
+	if err != nil {
+		return err
+	}
+
+	fields := strings.Fields(string(output))
+
+	if len(fields) != 2 {
+		return fmt.Errorf("handlers: cannot resolve %s in %s", fs.ref, fs.repository)
+	}
+
+	seconds, err := strconv.ParseInt(fields[1], 10, 64)
+
+	if err != nil {
+		return fmt.Errorf("handlers: cannot resolve %s in %s", fs.ref, fs.repository)
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	fs.commit = fields[0]
+	fs.modTime = time.Unix(seconds, 0)
+	return nil
+}
+
+// git runs a git command in the repository and returns its output.
+func (fs *GitFileSystem) git(args ...string) ([]byte, error) {
+
+	command := exec.Command("git", append([]string{"--git-dir", fs.repository}, args...)...)
+
+	// Treat paths literally rather than as pathspecs with magic or globs
+	command.Env = append(os.Environ(), "GIT_LITERAL_PATHSPECS=1")
+
+	var stderr bytes.Buffer
+	command.Stderr = &stderr
+
+	output, err := command.Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("handlers: git %s failed: %s", args[0],
+			strings.TrimSpace(stderr.String()))
+	}
+
+	return output, nil
+}
+
+// parseGitTree parses the output of git ls-tree -z -l into file infos. Entries
+// are separated by NUL bytes, so that git gives names as they are rather than
+// quoting those with special or non-ASCII characters.
+func parseGitTree(output []byte, modTime time.Time) []gitFileInfo {
+
+	var entries []gitFileInfo
+
+	for _, line := range strings.Split(string(output), "\x00") {
+
+		// Each line is "<mode> <type> <object> <size>\t<name>"
+		tab := strings.IndexByte(line, '\t')
+
+		if tab < 0 {
+			continue
+		}
+
+		fields := strings.Fields(line[:tab])
+
+		if len(fields) != 4 {
+			continue
+		}
+
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+
+		entries = append(entries, gitFileInfo{
+			name:       path.Base(line[tab+1:]),
+			objectType: fields[1],
+			object:     fields[2],
+			size:       size,
+			modTime:    modTime,
+		})
+	}
+
+	return entries
+}
+
+// gitFile is an open file or directory in a GitFileSystem. File contents are
+// read into memory when the file is opened.
+type gitFile struct {
+	info     gitFileInfo
+	reader   *bytes.Reader
+	children []gitFileInfo
+	offset   int
+}
+
+// Read reads the blob's contents from memory.
+func (f *gitFile) Read(p []byte) (int, error) {
+
+	return f.reader.Read(p)
+}
+
+// Seek sets the offset of the next Read within the blob's contents.
+func (f *gitFile) Seek(offset int64, whence int) (int64, error) {
+
+	return f.reader.Seek(offset, whence)
+}
+
+// Close does nothing, as the contents are already in memory and no git
+// process is left running.
+func (f *gitFile) Close() error {
+
+	return nil
+}
+
+// Stat returns the tree entry the file was opened from.
+func (f *gitFile) Stat() (os.FileInfo, error) {
+
+	return f.info, nil
+}
+
+// Readdir returns the entries of a directory, count at a time if count is
+// greater than zero.
+func (f *gitFile) Readdir(count int) ([]os.FileInfo, error) {
+
+	if f.info.objectType != "tree" {
+		return nil, fmt.Errorf("handlers: %s is not a directory", f.info.name)
+	}
+
+	remaining := f.children[f.offset:]
+
+	if count > 0 && len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if count > 0 && count < len(remaining) {
+		remaining = remaining[:count]
+	}
+
+	f.offset += len(remaining)
+	infos := make([]os.FileInfo, len(remaining))
+
+	for i := range remaining {
+		infos[i] = remaining[i]
+	}
+
+	return infos, nil
+}
+
+// gitFileInfo describes an entry in a git tree.
+type gitFileInfo struct {
+	name       string
+	objectType string
+	object     string
+	size       int64
+	modTime    time.Time
+}
+
+// Name returns the entry's name within its tree.
+func (i gitFileInfo) Name() string {
+
+	return i.name
+}
+
+// Size returns the size of a blob as reported by git, or zero for a tree.
+func (i gitFileInfo) Size() int64 {
+
+	return i.size
+}
+
+// Mode returns read-only permissions, with the directory bit set for trees.
+func (i gitFileInfo) Mode() os.FileMode {
+
+	if i.IsDir() {
+		return os.ModeDir | 0555
+	}
+
+	return 0444
+}
+
+// ModTime returns the time of the commit the ref points to, as git does not
+// record when individual files changed.
+func (i gitFileInfo) ModTime() time.Time {
+
+	return i.modTime
+}
+
+// IsDir reports whether the entry is a tree.
+func (i gitFileInfo) IsDir() bool {
+
+	return i.objectType == "tree"
+}
+
+// Sys returns nil. The entry's object id is kept for the file system's own
+// use.
+func (i gitFileInfo) Sys() interface{} {
+
+	return nil
+}
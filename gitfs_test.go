@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the GitFileSystem
+func TestGitFileSystem(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	root := t.TempDir()
+	work := filepath.Join(root, "work")
+	bare := filepath.Join(root, "site.git")
+
+	git := func(dir string, args ...string) {
+
+		command := exec.Command("git", append([]string{"-C", dir,
+			"-c", "user.name=Test", "-c", "user.email=test@example.com"}, args...)...)
+		command.Env = append(os.Environ(), "GIT_COMMITTER_DATE=2030-01-01T00:00:00Z")
+
+		if output, err := command.CombinedOutput(); err != nil {
+			t.Fatalf("git %s failed: %s %s", args[0], err, output)
+		}
+	}
+
+	// Create a repository with a site and push it to a bare repository
+	os.MkdirAll(filepath.Join(work, "docs"), 0755)
+	os.WriteFile(filepath.Join(work, "index.html"), []byte("Version 1"), 0644)
+	os.WriteFile(filepath.Join(work, "docs", "index.html"), []byte("Docs"), 0644)
+	os.WriteFile(filepath.Join(work, "café.html"), []byte("Café"), 0644)
+
+	git(root, "init", "--quiet", "--bare", "--initial-branch=main", bare)
+	git(root, "init", "--quiet", "--initial-branch=main", work)
+	git(work, "add", ".")
+	git(work, "commit", "--quiet", "-m", "First")
+	git(work, "push", "--quiet", bare, "main")
+
+	// Check refs that git would read as options are rejected
+	if _, err := NewGitFileSystem(bare, "--output=log"); err == nil {
+		t.Errorf("Expected an error for a ref starting with a dash")
+	}
+
+	fs, err := NewGitFileSystem(bare, "main")
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewGitFileSystem. Got: %s", err)
+	}
+
+	nfh := MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", fs, nfh)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/", http.StatusOK, "Version 1"},
+		{"/docs/", http.StatusOK, "Docs"},
+		{"/docs", http.StatusFound, ""},
+		{"/missing.html", http.StatusNotFound, ""},
+		{"/caf%C3%A9.html", http.StatusOK, "Café"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the path
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the status and body
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+
+		if test.body != "" && response.Body.String() != test.body {
+			t.Errorf("Expected %q for %s. Got: %q", test.body, test.path, response.Body.String())
+		}
+	}
+
+	// Check names with non-ASCII characters are read as they are
+	file, err := fs.Open("/café.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error opening a file with a UTF-8 name. Got: %s", err)
+	}
+
+	info, _ := file.Stat()
+	file.Close()
+
+	if info.Name() != "café.html" {
+		t.Errorf("Expected the name café.html. Got: %s", info.Name())
+	}
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/caf%C3%A9.html", nil)
+	h.ServeHTTP(response, request)
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Expected an HTML Content-Type for café.html. Got: %s", contentType)
+	}
+
+	// Check Last-Modified is the commit time
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	expected := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)
+
+	if lastModified := response.Header().Get("Last-Modified"); lastModified != expected {
+		t.Errorf("Expected Last-Modified %s. Got: %s", expected, lastModified)
+	}
+
+	// Push a new commit
+	os.WriteFile(filepath.Join(work, "index.html"), []byte("Version 2"), 0644)
+	git(work, "commit", "--quiet", "-am", "Second")
+	git(work, "push", "--quiet", bare, "main")
+
+	// Check a webhook without a valid signature is refused
+	secret := []byte("webhook secret")
+	webhook := fs.WebhookHandler(secret)
+	body := `{"ref": "refs/heads/main"}`
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST", "/hooks/push", strings.NewReader(body))
+	request.Header.Set("X-Hub-Signature-256", "sha256=00")
+	webhook.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Expected StatusUnauthorized for a bad signature. Got: %d", response.Code)
+	}
+
+	// Check a signed webhook moves the file system to the new commit
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST", "/hooks/push", strings.NewReader(body))
+	request.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	webhook.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNoContent {
+		t.Errorf("Expected StatusNoContent from the webhook. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Body.String() != "Version 2" {
+		t.Errorf("Expected \"Version 2\" after the webhook. Got: %s", response.Body.String())
+	}
+}
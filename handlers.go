@@ -23,148 +23,389 @@ package handlers
 
 import (
 	"bytes"
+	"context"
 	"html/template"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
-	"os"
-	"path/filepath"
+	"path"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+	"unicode"
 )
 
+// serverHeader holds the value of the Server header set on every response
+// written by the package's handlers. If it has not been set the header is left
+// alone, and if it has been set to an empty string the header is removed.
+var serverHeader atomic.Value
+
+// hideErrorDetails records whether the details of internal errors, such as a
+// template failing to execute, are hidden from the client.
+var hideErrorDetails atomic.Value
+
+// SetServerHeader sets the value of the Server header on every response
+// written by the package's handlers, so that the banner presented to clients
+// and security scanners is controlled in one place. Passing an empty string
+// suppresses the header, removing any value set earlier in the handler chain.
+func SetServerHeader(value string) {
+
+	serverHeader.Store(value)
+}
+
+// SetHideErrorDetails controls whether the details of internal errors are
+// hidden from the client. By default, if a handler's template fails to execute
+// the error is reported to the client with the built-in http error. When hide
+// is true only the generic status text is sent.
+func SetHideErrorDetails(hide bool) {
+
+	hideErrorDetails.Store(hide)
+}
+
+// setServerHeader applies the package's Server header policy to the response.
+func setServerHeader(w http.ResponseWriter) {
+
+	value, ok := serverHeader.Load().(string)
+
+	if !ok {
+		return
+	}
+
+	if value == "" {
+		w.Header().Del("Server")
+		return
+	}
+
+	w.Header().Set("Server", value)
+}
+
+// serveInternalError reports an internal error with the built-in http error,
+// hiding its details if the package has been told to do so.
+func serveInternalError(w http.ResponseWriter, err error) {
+
+	message := err.Error()
+
+	if hide, _ := hideErrorDetails.Load().(bool); hide {
+		message = http.StatusText(http.StatusInternalServerError)
+	}
+
+	http.Error(w, message, http.StatusInternalServerError)
+}
+
+// writeTemplate writes the executed template in buffer to the response with
+// the given status code. If a charset or content language is given the
+// corresponding headers are set, otherwise the content type is sniffed. As the
+// response is already buffered its Content-Length is always set, so that HEAD
+// requests and intermediaries see the exact size of the body.
+func writeTemplate(w http.ResponseWriter, buffer *bytes.Buffer, status int,
+	charset string, contentLanguage string) {
+
+	if charset != "" {
+		w.Header().Set("Content-Type", "text/html; charset="+charset)
+	}
+
+	if contentLanguage != "" {
+		w.Header().Set("Content-Language", contentLanguage)
+	}
+
+	w.Header().Set("Content-Length", strconv.Itoa(buffer.Len()))
+	w.WriteHeader(status)
+	buffer.WriteTo(w)
+}
+
 // ErrorMessage holds the message passed to the error template. The template
-// can access the message field with the {{.ErrorMessage}} tag.
+// can access the message field with the {{.ErrorMessage}} tag. ErrorID holds
+// an id identifying the error, which users can quote so that it can be found
+// in the server's logs, and can be accessed with the {{.ErrorID}} tag. Flags
+// holds the feature flags evaluated for the request, when the handler is given
+// one, and can be accessed with tags like {{if .Flags.name}}. Claims holds the
+// claims of the token or login that authenticated the request, if any, and can
+// be accessed with tags like {{.Claims.sub}}. StackTrace holds the stack trace
+// of the code that served the error, when the handler captures them, and can
+// be accessed with the {{.StackTrace}} tag. Path and Method hold the path and
+// method of the request that failed, and are empty when the error is served
+// without a request. Time holds the time the error was served, and can be
+// formatted with tags like {{.Time.Format "2 Jan 2006 15:04"}}. Extra holds
+// the values set with SetExtra, and can be accessed with tags like
+// {{.Extra.name}}.
 type ErrorMessage struct {
 	ErrorMessage string
+	ErrorID      string
+	StackTrace   string
+	Path         string
+	Method       string
+	Time         time.Time
+	Extra        map[string]interface{}
+	Flags        map[string]bool
+	Claims       map[string]interface{}
 }
 
 // ErrorHandler serves error messages with the given template. The template
 // can access the message served by the handler with the {{.ErrorMessage}} tag.
 type ErrorHandler struct {
-	template       *template.Template
-	defaultMessage string
-	displayErrors  bool
+	template        *template.Template
+	defaultMessage  string
+	displayErrors   bool
+	charset         string
+	contentLanguage string
+	limits          templateLimits
+	status          int
+	format          ErrorFormat
+	problemType     string
+	requestIDHeader string
+	random          io.Reader
+	stackTraces     bool
+	logger          *slog.Logger
+	onError         []func(r *http.Request, message string, status int, errorID string)
+	templatePath    string
+	reloadTemplate  bool
+	funcs           template.FuncMap
+	statusTemplates map[int]*template.Template
+	extra           map[string]interface{}
+	clock           Clock
 }
 
 // NewErrorHandler returns a new ErrorHandler with the handler values initialised.
 // The handler uses the given template to print an error message. The template
-// must display {{.ErrorMessage}}. The default error message is set to message.
-// The display argument controls whether error messages passed to the handler's
-// ServeError function are shown to the user on the error page, or whether the
-// default error message is shown instead. This allows detailed error messages
-// to be printed to the screen during development, but turned off in production.
-// The handler's AlwaysServeError method forces the display of a particular
-// error message even if displayErrors is set to false.
-func NewErrorHandler(template *template.Template, defaultMessage string, displayErrors bool) *ErrorHandler {
-
-	return &ErrorHandler{
+// must display {{.ErrorMessage}}. The handler is configured with options such
+// as WithDefaultMessage and WithDisplayErrors. By default the handler shows
+// DefaultErrorMessage rather than the messages passed to its ServeError
+// function, so that detailed error messages are only printed to the screen
+// when they are turned on during development. The handler's AlwaysServeError
+// method forces the display of a particular error message even if errors are
+// not displayed.
+func NewErrorHandler(template *template.Template, options ...ErrorOption) *ErrorHandler {
+
+	h := &ErrorHandler{
 		template:       template,
-		defaultMessage: defaultMessage,
-		displayErrors:  displayErrors,
+		defaultMessage: DefaultErrorMessage,
 	}
+
+	for _, option := range options {
+		option(h)
+	}
+
+	return h
 }
 
 // LoadErrorHandler is a convenience function that returns a new ErrorHandler
 // using the template file specified by templatePath. The function first loads
-// the template and then creates the ErrorHandler using NewErrorHandler.
-func LoadErrorHandler(templatePath string, defaultMessage string, displayErrors bool) *ErrorHandler {
+// the template and then creates the ErrorHandler using NewErrorHandler with
+// the given options. Functions given with WithFuncs are available to the
+// template. An error is returned if the template cannot be loaded.
+func LoadErrorHandler(templatePath string, options ...ErrorOption) (*ErrorHandler, error) {
 
-	template, err := template.ParseFiles(templatePath)
+	// Apply the options first, so that their functions are known to the parse
+	h := NewErrorHandler(nil, options...)
+	template, err := parseTemplateFile(templatePath, h.funcs)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return NewErrorHandler(template, defaultMessage, displayErrors)
+	h.template = template
+	h.templatePath = templatePath
+	return h, nil
 }
 
-// ServeError serves the appropriate error message in the error template
-// depending on the value of displayErrors. If displayErrors is true then
-// the given message is shown, otherwise the default error message is shown.
-func (h *ErrorHandler) ServeError(w http.ResponseWriter, message string) {
+// MustLoadErrorHandler is like LoadErrorHandler but panics if the template
+// cannot be loaded. It is intended for use in tests and at startup, where a
+// missing template is a programming error.
+func MustLoadErrorHandler(templatePath string, options ...ErrorOption) *ErrorHandler {
 
-	var (
-		templateData *ErrorMessage
-		buffer       bytes.Buffer
-	)
+	h, err := LoadErrorHandler(templatePath, options...)
 
-	if h.displayErrors {
+	if err != nil {
+		panic(err)
+	}
 
-		templateData = &ErrorMessage{message}
+	return h
+}
 
-	} else {
+// SetCharset sets the charset given in the Content-Type header of the
+// handler's responses, for example "utf-8". By default the header is not set
+// explicitly and net/http detects the content type from the response body.
+func (h *ErrorHandler) SetCharset(charset string) {
 
-		templateData = &ErrorMessage{h.defaultMessage}
-	}
+	h.charset = charset
+}
 
-	// Execute template into buffer
-	err := h.template.Execute(&buffer, templateData)
+// SetContentLanguage sets the Content-Language header of the handler's
+// responses, for example "en-GB". By default the header is not set.
+func (h *ErrorHandler) SetContentLanguage(language string) {
 
-	// If template execution fails, fall back to the built-in http error
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	h.contentLanguage = language
+}
 
-	// Otherwise serve the error in the error template
-	w.WriteHeader(http.StatusInternalServerError)
-	buffer.WriteTo(w)
-	return
+// SetDefaultStatus sets the status code of the responses written by
+// ServeError, AlwaysServeError and ServeHTTP. By default it is 500.
+func (h *ErrorHandler) SetDefaultStatus(status int) {
+
+	h.status = status
+}
+
+// ServeError serves the appropriate error message in the error template
+// depending on whether errors are displayed. If the handler was created
+// WithDisplayErrors(true) then the given message is shown, otherwise the
+// default error message is shown. The error is served without a request, so
+// its id is always generated, and the template, logs and error callbacks have
+// no method, path or remote address. Use ServeErrorRequest to include them.
+func (h *ErrorHandler) ServeError(w http.ResponseWriter, message string) {
+
+	h.ServeErrorWithStatus(w, message, h.defaultStatus())
+}
+
+// ServeErrorWithStatus serves the appropriate error message in the error
+// template with the given status code, so that the same template can be used
+// for failures such as 502, 503 and 504. The message shown depends on
+// whether errors are displayed, and the error has no request, as for
+// ServeError.
+func (h *ErrorHandler) ServeErrorWithStatus(w http.ResponseWriter, message string, status int) {
+
+	h.serveError(w, nil, message, status, !h.displayErrors, h.stackTrace())
+}
+
+// ServeErrorRequest serves the appropriate error message in the error
+// template with the given status code, as ServeErrorWithStatus does, for the
+// request being handled. The request's id header, method, path and remote
+// address are used in the error id, the template and the logs, and the
+// request is passed to the error callbacks.
+func (h *ErrorHandler) ServeErrorRequest(w http.ResponseWriter, r *http.Request, message string, status int) {
+
+	h.serveError(w, r, message, status, !h.displayErrors, h.stackTrace())
 }
 
 // AlwaysServeError serves the given error message in the error template.
 // This method overrides the default error message, irrespective of whether
-// displayErrors is false, and ensures that the given message is always shown.
+// errors are displayed, and ensures that the given message is always shown.
+// The error is served without a request, as for ServeError.
 func (h *ErrorHandler) AlwaysServeError(w http.ResponseWriter, message string) {
 
-	var buffer bytes.Buffer
-	templateData := &ErrorMessage{message}
+	h.serveError(w, nil, message, h.defaultStatus(), false, "")
+}
 
-	// Execute template into buffer
-	err := h.template.Execute(&buffer, templateData)
+// ServeHTTP serves the default error message in the error template.
+func (h *ErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	h.serveError(w, r, h.defaultMessage, h.defaultStatus(), false, "")
+}
+
+// defaultStatus returns the status code set with SetDefaultStatus, or 500.
+func (h *ErrorHandler) defaultStatus() int {
+
+	if h.status == 0 {
+		return http.StatusInternalServerError
+	}
+
+	return h.status
+}
+
+// serveError serves the message in the error template with the given status
+// code and stack trace, which is empty if none was captured. If hide is true
+// the message is logged but the default message is shown instead. The
+// request is nil for errors served by ServeError and the other methods that
+// are not given one.
+func (h *ErrorHandler) serveError(w http.ResponseWriter, r *http.Request, message string,
+	status int, hide bool, stackTrace string) {
+
+	setServerHeader(w)
+
+	errorID, err := h.errorID(r)
 
-	// If template execution fails, fall back to the built-in http error
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serveInternalError(w, err)
 		return
 	}
 
-	// Otherwise serve the error in the error template
-	w.WriteHeader(http.StatusInternalServerError)
-	buffer.WriteTo(w)
-	return
-}
+	h.reportError(r, message, status, errorID)
 
-// ServeHTTP serves the default error message in the error template.
-func (h *ErrorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if hide {
+		message = h.defaultMessage
+	}
+
+	format := h.format
+
+	if format == ErrorFormatNegotiated {
+		format = negotiateErrorFormat(r)
+		addVary(w, "Accept")
+	}
+
+	switch format {
+
+	case ErrorFormatProblemJSON:
+
+		h.serveProblem(w, r, message, errorID, status)
+		return
+
+	case ErrorFormatText:
+
+		h.serveText(w, message, errorID, status)
+		return
+	}
 
 	var buffer bytes.Buffer
-	templateData := &ErrorMessage{h.defaultMessage}
+	templateData := &ErrorMessage{
+		ErrorMessage: message,
+		ErrorID:      errorID,
+		StackTrace:   stackTrace,
+		Time:         h.now(),
+		Extra:        h.extra,
+		Flags:        RequestFlags(r),
+		Claims:       RequestClaims(r),
+	}
+
+	ctx := context.Background()
+
+	if r != nil {
+		ctx = r.Context()
+		templateData.Path = r.URL.Path
+		templateData.Method = r.Method
+	}
 
 	// Execute template into buffer
-	err := h.template.Execute(&buffer, templateData)
+	tmpl, err := h.currentTemplate(status)
+
+	if err == nil {
+		setDebugHeader(w, r, "X-Debug-Template", tmpl.Name())
+		err = executeTemplate(ctx, tmpl, templateData, &buffer, h.limits)
+	}
 
-	// If template execution fails, fall back to the built-in http error
+	// If template parsing or execution fails, fall back to the built-in http error
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serveInternalError(w, err)
 		return
 	}
 
 	// Otherwise serve the error in the error template
-	w.WriteHeader(http.StatusInternalServerError)
-	buffer.WriteTo(w)
-	return
+	writeTemplate(w, &buffer, status, h.charset, h.contentLanguage)
 }
 
 // NotFoundData holds the path passed to the handler's template. The template
-// can access the message field with the {{.Path}} tag.
+// can access the message field with the {{.Path}} tag. Query holds the values
+// of any query parameters the handler has been told to pass to the template,
+// which can be accessed with tags like {{.Query.q}}. Flags holds the feature
+// flags evaluated for the request, which can be accessed with tags like
+// {{if .Flags.name}}. Claims holds the claims of the token or login that
+// authenticated the request, if any, which can be accessed with tags like
+// {{.Claims.sub}}.
 type NotFoundData struct {
-	Path string
+	Path   string
+	Query  map[string]string
+	Flags  map[string]bool
+	Claims map[string]interface{}
 }
 
 // NotFoundHandler serves a 404 with the given template. The template
 // can access the path to the file not found with {{.Path}} tag.
 type NotFoundHandler struct {
-	template *template.Template
+	template        *template.Template
+	queryParams     []string
+	charset         string
+	contentLanguage string
+	limits          templateLimits
+	brokenLinks     *brokenLinkReporter
+	clock           Clock
 }
 
 // NewNotFoundHandler returns a new NotFoundHandler with the handler values
@@ -179,92 +420,398 @@ func NewNotFoundHandler(template *template.Template) *NotFoundHandler {
 
 // LoadNotFoundHandler is a convenience function that returns a new NotFoundHandler
 // using the template file specified by templatePath. The function first loads the
-// template and then creates the NotFoundHandler using NewNotFoundHandler.
-func LoadNotFoundHandler(templatePath string) *NotFoundHandler {
+// template and then creates the NotFoundHandler using NewNotFoundHandler. Any
+// funcs given are made available to the template, so that it can call helper
+// functions. An error is returned if the template cannot be loaded.
+func LoadNotFoundHandler(templatePath string, funcs ...template.FuncMap) (*NotFoundHandler, error) {
 
-	template, err := template.ParseFiles(templatePath)
+	template, err := parseTemplateFile(templatePath, funcs...)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
-	return NewNotFoundHandler(template)
+	return NewNotFoundHandler(template), nil
+}
+
+// MustLoadNotFoundHandler is like LoadNotFoundHandler but panics if the
+// template cannot be loaded. It is intended for use in tests and at startup,
+// where a missing template is a programming error.
+func MustLoadNotFoundHandler(templatePath string, funcs ...template.FuncMap) *NotFoundHandler {
+
+	h, err := LoadNotFoundHandler(templatePath, funcs...)
+
+	if err != nil {
+		panic(err)
+	}
+
+	return h
+}
+
+// SetCharset sets the charset given in the Content-Type header of the
+// handler's responses, for example "utf-8". By default the header is not set
+// explicitly and net/http detects the content type from the response body.
+func (h *NotFoundHandler) SetCharset(charset string) {
+
+	h.charset = charset
+}
+
+// SetContentLanguage sets the Content-Language header of the handler's
+// responses, for example "en-GB". By default the header is not set.
+func (h *NotFoundHandler) SetContentLanguage(language string) {
+
+	h.contentLanguage = language
+}
+
+// SetQueryParams sets the names of the query parameters whose values are
+// passed to the handler's template. Parameters not named here are never made
+// available to the template. Values are sanitized before they are passed on:
+// invalid UTF-8 and control characters are removed and the value is truncated
+// to maxQueryValueLength runes. The template is still responsible for escaping
+// the values, which html/template does automatically for the context in which
+// they are printed.
+func (h *NotFoundHandler) SetQueryParams(names ...string) {
+
+	h.queryParams = names
 }
 
 // ServeHTTP serves the path in the handler's template.
 func (h *NotFoundHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	setServerHeader(w)
+
+	if h.brokenLinks != nil {
+
+		clock := h.clock
+
+		if clock == nil {
+			clock = SystemClock
+		}
+
+		h.brokenLinks.check(r, clock.Now())
+	}
+
+	setDebugHeader(w, r, "X-Debug-Template", h.template.Name())
+
 	var buffer bytes.Buffer
-	templateData := &NotFoundData{r.URL.Path}
+	templateData := &NotFoundData{
+		Path:   r.URL.Path,
+		Query:  h.query(r),
+		Flags:  RequestFlags(r),
+		Claims: RequestClaims(r),
+	}
 
 	// Execute template into buffer
-	err := h.template.Execute(&buffer, templateData)
+	err := executeTemplate(r.Context(), h.template, templateData, &buffer, h.limits)
 
 	// If template execution fails, report it with the built-in http error
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		serveInternalError(w, err)
 		return
 	}
 
 	// Otherwise serve the 404 in the not found template
-	w.WriteHeader(http.StatusNotFound)
-	buffer.WriteTo(w)
+	writeTemplate(w, &buffer, http.StatusNotFound, h.charset, h.contentLanguage)
 	return
 }
 
+// query returns the sanitized values of the allowed query parameters.
+func (h *NotFoundHandler) query(r *http.Request) map[string]string {
+
+	query := make(map[string]string)
+
+	if len(h.queryParams) == 0 {
+		return query
+	}
+
+	values := r.URL.Query()
+
+	for _, name := range h.queryParams {
+
+		if value, ok := values[name]; ok && len(value) > 0 {
+			query[name] = sanitizeQueryValue(value[0])
+		}
+	}
+
+	return query
+}
+
+// maxQueryValueLength is the maximum number of runes of a query parameter
+// value that is passed to a template.
+const maxQueryValueLength int = 256
+
+// sanitizeQueryValue removes invalid UTF-8 and control characters from the
+// given value and truncates it to maxQueryValueLength runes.
+func sanitizeQueryValue(value string) string {
+
+	var (
+		builder strings.Builder
+		length  int
+	)
+
+	for _, c := range strings.ToValidUTF8(value, "") {
+
+		if length == maxQueryValueLength {
+			break
+		}
+
+		if unicode.IsControl(c) {
+			continue
+		}
+
+		builder.WriteRune(c)
+		length++
+	}
+
+	return builder.String()
+}
+
 // FileHandler serves files requested under the given url path from the given
-// directory. The url path should be the same as the path to which the handler
-// is bound with http.Handle. If the file is not found the handler serves a 404
-// using the given notFoundHandler. The notFoundHandler can be any Handler, but
-// its ServeHTTP method should return a 404. Unlike Go's built-in FileServer,
-// FileHandler will not return directory listings for directories without an
-// index.html and will instead respond with a 404.
+// directory or file system. The url path should be the same as the path to
+// which the handler is bound with http.Handle. If the file is not found the
+// handler serves a 404 using the given notFoundHandler. The notFoundHandler
+// can be any Handler, but its ServeHTTP method should return a 404. Unlike
+// Go's built-in FileServer, FileHandler will not return directory listings for
+// directories without an index.html and will instead respond with a 404.
 type FileHandler struct {
-	urlPath         string
-	directory       string
-	notFoundHandler http.Handler
+	urlPath               string
+	fileSystem            http.FileSystem
+	notFoundHandler       http.Handler
+	trustForwardedHeaders bool
+	integrity             *SRIManifest
+	transforms            []htmlTransform
+	transformLimit        int64
+	rewrites              []rewriteRule
+	mobileVariant         VariantPath
+	deviceCookie          string
+	negotiateImages       bool
+	strippedImages        *strippedImageCache
+	sanitizedSVGs         *strippedImageCache
+	dispositions          map[string]string
+	defaultDisposition    string
+	blocked               map[string]bool
+	downloadBlocked       bool
+	untrusted             bool
+	sandbox               bool
+	mountPrefix           string
+	caseSensitivity       CaseSensitivity
+	normalize             func(string) string
+	syncing               func() bool
+	retryAfter            time.Duration
+	schedule              *PublishSchedule
+	goneHandler           http.Handler
+	errorHandler          *ErrorHandler
 }
 
 // NewFileHandler returns a new FileHandler with the handler values initialised.
+// The handler serves files from the given directory on the local disk.
 func NewFileHandler(urlPath string, directory string, notFoundHandler http.Handler) *FileHandler {
 
+	return NewFileSystemHandler(urlPath, http.Dir(directory), notFoundHandler)
+}
+
+// NewFileSystemHandler returns a new FileHandler that serves files from the
+// given http.FileSystem rather than a directory on the local disk. This lets
+// the handler serve content from any storage backend that implements the
+// http.FileSystem interface, such as an object store or an embedded file
+// system wrapped with http.FS, with the same behaviour as a local directory.
+func NewFileSystemHandler(urlPath string, fileSystem http.FileSystem, notFoundHandler http.Handler) *FileHandler {
+
 	return &FileHandler{
 		urlPath:         urlPath,
-		directory:       directory,
+		fileSystem:      fileSystem,
 		notFoundHandler: notFoundHandler,
 	}
 }
 
-// ServeHTTP is a wrapper around http.ServeFile, with paths and response
+// SetTrustForwardedHeaders controls whether the handler trusts the
+// X-Forwarded-Proto and X-Forwarded-Host headers set by a proxy. When trust is
+// true, the redirects generated by the handler use absolute urls built from the
+// scheme and host in these headers, so that a server behind a TLS-terminating
+// proxy does not redirect clients to http. Only enable this when the handler is
+// behind a proxy that sets or strips these headers.
+func (h *FileHandler) SetTrustForwardedHeaders(trust bool) {
+
+	h.trustForwardedHeaders = trust
+}
+
+// SetCaseSensitivity sets the policy the handler uses to match the case of
+// request paths against file names. By default matching is left to the file
+// system, which means the same site can behave differently on a developer's
+// machine and in production. CaseSensitive and CaseInsensitive make lookups
+// consistent on every platform, at the cost of reading each directory in the
+// path on every request.
+func (h *FileHandler) SetCaseSensitivity(policy CaseSensitivity) {
+
+	h.caseSensitivity = policy
+}
+
+// SetPathNormalizer sets a function used to normalize the Unicode in request
+// paths and file names before they are compared, so that a file whose name is
+// stored in one normalization form can be found with a path in another. For
+// example, files uploaded from macOS often have decomposed (NFD) names, while
+// browsers usually send composed (NFC) paths. Passing norm.NFC.String from
+// golang.org/x/text/unicode/norm makes both forms find the same file. Like the
+// non-default case sensitivity policies, normalization reads each directory in
+// the path on every request. Passing nil turns normalization off.
+func (h *FileHandler) SetPathNormalizer(normalize func(string) string) {
+
+	h.normalize = normalize
+}
+
+// SetErrorHandler sets the ErrorHandler used to serve the handler's internal
+// errors, such as a page over the transform limit, a file that no longer
+// matches the integrity manifest or an image that cannot be stripped, so that
+// they are served in its template, with an error id, and reach its logger and
+// callbacks like the site's other errors. The error describes the problem and
+// is shown only if the ErrorHandler displays errors. Without an ErrorHandler
+// these errors are served as plain 500 responses.
+func (h *FileHandler) SetErrorHandler(errorHandler *ErrorHandler) {
+
+	h.errorHandler = errorHandler
+}
+
+// SetIntegrityManifest sets a manifest of Subresource Integrity hashes which
+// the handler uses to check the files it serves. If a file in the manifest no
+// longer matches its hash the handler responds with a 500 rather than serving
+// content that browsers will refuse to run, or with a 503 if the content is
+// being synchronised (see SetSyncCheck). Files are only hashed again when
+// their size or modification time changes.
+func (h *FileHandler) SetIntegrityManifest(manifest *SRIManifest) {
+
+	h.integrity = manifest
+}
+
+// ServeHTTP is a wrapper around http.ServeContent, with paths and response
 // values modified to provide the appropriate behaviour for the FileHandler.
 func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
+	setServerHeader(w)
+	setDebugHeader(w, r, "X-Debug-Handler", "FileHandler "+h.urlPath)
+
 	const indexPage string = "index.html"
 
+	// If the request's path cannot be looked up safely on every platform,
+	// return a 404 before any rule can copy it into a new path or a redirect
+	if !safeRequestPath(r.URL.Path) || !safeEscapedPath(r.URL) {
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Apply any rewrite or redirect rule to the request
+	r, redirected := h.rewrite(w, r)
+
+	if redirected {
+		return
+	}
+
+	// If the request is not under the handler's path, or its path cannot be
+	// looked up safely on every platform, return a 404
+	if len(r.URL.Path) < len(h.urlPath)-1 || !safeRequestPath(r.URL.Path) ||
+		!safeEscapedPath(r.URL) {
+
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
 	var (
 		requestPath string = r.URL.Path[len(h.urlPath)-1:]
 		filePath    string
 	)
 
+	// If the request is for an index page redirect to its directory
+	if strings.HasSuffix(r.URL.Path, "/"+indexPage) {
+
+		directoryPath := strings.TrimSuffix(r.URL.Path, indexPage)
+		h.redirect(w, r, "./", directoryPath, http.StatusMovedPermanently)
+		return
+	}
+
 	// If the request path ends in "/" ...
 	if strings.HasSuffix(r.URL.Path, "/") {
 
 		// Set the target filepath to index.html
-		filePath = h.directory + filepath.FromSlash(requestPath+indexPage)
+		filePath = requestPath + indexPage
 
 	} else {
 
 		// Otherwise set the target filepath to the named file
-		filePath = h.directory + filepath.FromSlash(requestPath)
+		filePath = requestPath
+	}
+
+	// Remove any "." segments and duplicate slashes from the filepath
+	filePath = path.Clean("/" + filePath)
+
+	// Apply the case sensitivity and normalization policies to the path
+	filePath, found := h.resolvePath(filePath)
+
+	// If no file matches under the policy return a 404, or a 503 if the
+	// content is being synchronised
+	if !found {
+
+		h.notFound(w, r)
+		return
+	}
+
+	// If the content is not yet published or has expired, say so. The file
+	// that would be opened is checked, rather than the request path, so that
+	// other spellings of the path cannot reach it early
+	if h.serveScheduled(w, r, filePath) {
+		return
+	}
+
+	// If the file has a blocked extension, and is not to be downloaded,
+	// respond as if it did not exist
+	blocked := h.isBlocked(filePath)
+
+	if blocked && !h.downloadBlocked {
+
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	// Serve the file's variant for the client's device, if it has one
+	integrityPath := r.URL.Path
+
+	if variantPath, ok := h.deviceVariant(w, r, filePath); ok {
+		filePath = variantPath
+		integrityPath = h.urlPath[:len(h.urlPath)-1] + variantPath
+		setDebugHeader(w, r, "X-Debug-Variant", variantPath)
+	}
+
+	// Serve the image in the best format the client accepts
+	if imagePath, ok := h.imageVariant(w, r, filePath); ok {
+		filePath = imagePath
+		integrityPath = h.urlPath[:len(h.urlPath)-1] + imagePath
+		setDebugHeader(w, r, "X-Debug-Variant", imagePath)
+	}
+
+	setDebugHeader(w, r, "X-Debug-File", filePath)
+
+	// Try to open the file
+	file, err := h.fileSystem.Open(filePath)
+
+	// If Open fails return a 404 or a 503
+	if err != nil {
+
+		h.notFound(w, r)
+		return
+	}
+
+	defer file.Close()
+
+	// Say which backend the file came from, if the file system records it
+	if backend, ok := file.(backendFile); ok {
+		setDebugHeader(w, r, "X-Debug-Backend", backend.backendName())
 	}
 
 	// Try to get file info
-	finfo, err := os.Stat(filePath)
+	finfo, err := file.Stat()
 
-	// If Stat fails return a 404
+	// If Stat fails return a 404 or a 503
 	if err != nil {
 
-		h.notFoundHandler.ServeHTTP(w, r)
+		h.notFound(w, r)
 		return
 	}
 
@@ -274,13 +821,150 @@ func (h *FileHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// If the target file is a directory redirect to the path with a slash
 	case mode.IsDir():
 
-		http.Redirect(w, r, r.URL.Path+"/", http.StatusFound)
+		h.redirect(w, r, r.URL.Path+"/", r.URL.Path+"/", http.StatusFound)
 
 	// Otherwise serve the file
 	case mode.IsRegular():
 
-		http.ServeFile(w, r, filePath)
+		// If there is an integrity manifest check the file still matches it
+		if h.integrity != nil {
+
+			if err := h.integrity.verify(integrityPath, file, finfo); err != nil {
+
+				// A mismatch is expected while content is being synchronised
+				if h.isSyncing() {
+					h.serveUnavailable(w)
+					return
+				}
+
+				h.serveError(w, r, err)
+				return
+			}
+		}
+
+		// Count the response against the backend that served the file
+		countServed(file)
+
+		// Serve a blocked file only as a download
+		if blocked {
+			setDownloadHeaders(w, finfo.Name())
+			h.setUntrustedHeaders(w, finfo.Name())
+			http.ServeContent(w, r, finfo.Name(), finfo.ModTime(), file)
+			return
+		}
+
+		// Say whether the file should be shown or downloaded
+		h.setContentDisposition(w, finfo.Name())
+
+		// Protect against untrusted content being run as the site's own
+		h.setUntrustedHeaders(w, finfo.Name())
+
+		// If the file is an image whose metadata must be removed serve the result
+		if h.strippedImages != nil && isStrippableImage(filePath) {
+			h.serveStripped(w, r, h.strippedImages, file, filePath, finfo)
+			return
+		}
+
+		// If the file is an SVG image that must be sanitized serve the result
+		if h.sanitizedSVGs != nil && isSVG(filePath) {
+			h.serveStripped(w, r, h.sanitizedSVGs, file, filePath, finfo)
+			return
+		}
+
+		// If the file is an HTML page with transforms to apply serve the result
+		if len(h.transforms) > 0 && isHTML(finfo.Name()) {
+			h.serveTransformed(w, r, file, finfo.Name())
+			return
+		}
+
+		// If the file is a style sheet whose urls must be mounted serve the result
+		if h.mountPrefix != "" && isCSS(finfo.Name()) {
+			h.serveMountedCSS(w, r, file, finfo)
+			return
+		}
+
+		http.ServeContent(w, r, finfo.Name(), finfo.ModTime(), file)
 	}
 
 	return
 }
+
+// serveError serves an internal error with the handler's ErrorHandler, or as
+// a plain 500 if it has none.
+func (h *FileHandler) serveError(w http.ResponseWriter, r *http.Request, err error) {
+
+	if h.errorHandler == nil {
+		serveInternalError(w, err)
+		return
+	}
+
+	h.errorHandler.ServeErrorRequest(w, r, err.Error(), http.StatusInternalServerError)
+}
+
+// redirect redirects the request to the given location with the given status,
+// keeping the query string. If forwarded headers are trusted, an absolute url
+// for the given path is used instead of the location.
+func (h *FileHandler) redirect(w http.ResponseWriter, r *http.Request,
+	location string, path string, status int) {
+
+	if h.trustForwardedHeaders {
+		location = h.redirectURL(r, path)
+	}
+
+	// A location starting with two slashes would be read by the client as a
+	// url on another host
+	if strings.HasPrefix(location, "//") {
+		location = "/" + strings.TrimLeft(location, "/")
+	}
+
+	if query := r.URL.RawQuery; query != "" {
+		location += "?" + query
+	}
+
+	w.Header().Set("Location", location)
+	w.WriteHeader(status)
+}
+
+// redirectURL returns the url to use when redirecting the request to the given
+// path. If forwarded headers are not trusted the path is returned unchanged.
+// Otherwise an absolute url is built using the scheme and host from the
+// forwarded headers, falling back to the scheme and host of the request.
+func (h *FileHandler) redirectURL(r *http.Request, path string) string {
+
+	if !h.trustForwardedHeaders {
+		return path
+	}
+
+	scheme := "http"
+
+	if r.TLS != nil {
+		scheme = "https"
+	}
+
+	if proto := forwardedValue(r, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	host := r.Host
+
+	if forwardedHost := forwardedValue(r, "X-Forwarded-Host"); forwardedHost != "" &&
+		!strings.ContainsAny(forwardedHost, "/\\@ ") {
+		host = forwardedHost
+	}
+
+	return scheme + "://" + host + path
+}
+
+// forwardedValue returns the first value of the named forwarding header. If a
+// request has passed through several proxies the first value is the one set by
+// the proxy nearest the client.
+func forwardedValue(r *http.Request, name string) string {
+
+	value := r.Header.Get(name)
+
+	if i := strings.Index(value, ","); i >= 0 {
+		value = value[:i]
+	}
+
+	return strings.ToLower(strings.TrimSpace(value))
+}
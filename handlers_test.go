@@ -1,10 +1,14 @@
 package handlers
 
 import (
+	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"testing/fstest"
 )
 
 // Test ErrorHandler functions and methods
@@ -26,7 +30,11 @@ func TestErrorHandler(t *testing.T) {
 	templatePath = filepath.FromSlash("templates/error.html")
 
 	// Get an ErrorHandler with the example template and display errors on
-	h = LoadErrorHandler(templatePath, defaultMessage, true)
+	h, err := LoadErrorHandler(templatePath, WithDefaultMessage(defaultMessage), WithDisplayErrors(true))
+
+	if err != nil {
+		t.Fatalf("Expected no error from LoadErrorHandler. Got: %s", err)
+	}
 
 	// Test ServeError with display errors on
 	response = httptest.NewRecorder()
@@ -65,7 +73,7 @@ func TestErrorHandler(t *testing.T) {
 	}
 
 	// Get an ErrorHandler with the example template and display errors off
-	h = LoadErrorHandler(templatePath, defaultMessage, false)
+	h = MustLoadErrorHandler(templatePath, WithDefaultMessage(defaultMessage))
 
 	// Test ServeError with display errors off
 	response = httptest.NewRecorder()
@@ -104,6 +112,66 @@ func TestErrorHandler(t *testing.T) {
 	}
 }
 
+// Test ErrorHandler status codes
+func TestErrorHandlerStatus(t *testing.T) {
+
+	const (
+		defaultMessage string = "Default error message"
+		customMessage  string = "Test ServeErrorWithStatus"
+	)
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	// Get an ErrorHandler with the example template and display errors off
+	h = MustLoadErrorHandler(filepath.FromSlash("templates/error.html"),
+		WithDefaultMessage(defaultMessage))
+
+	// Test ServeErrorWithStatus with display errors off
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, customMessage, http.StatusBadGateway)
+
+	// Check the status code and the default message
+	if response.Code != http.StatusBadGateway || response.Body.String() != "Error: "+defaultMessage {
+		t.Errorf("Expected StatusBadGateway with the default message. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Test ServeErrorWithStatus with display errors on
+	h = MustLoadErrorHandler(filepath.FromSlash("templates/error.html"),
+		WithDefaultMessage(defaultMessage), WithDisplayErrors(true))
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, customMessage, http.StatusGatewayTimeout)
+
+	// Check the status code and the custom message
+	if response.Code != http.StatusGatewayTimeout || response.Body.String() != "Error: "+customMessage {
+		t.Errorf("Expected StatusGatewayTimeout with the custom message. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Test the default status with ServeError, AlwaysServeError and ServeHTTP
+	h.SetDefaultStatus(http.StatusServiceUnavailable)
+	request, _ = http.NewRequest("GET", "/", nil)
+
+	for name, serve := range map[string]func(w http.ResponseWriter){
+		"ServeError":       func(w http.ResponseWriter) { h.ServeError(w, customMessage) },
+		"AlwaysServeError": func(w http.ResponseWriter) { h.AlwaysServeError(w, customMessage) },
+		"ServeHTTP":        func(w http.ResponseWriter) { h.ServeHTTP(w, request) },
+	} {
+
+		response = httptest.NewRecorder()
+		serve(response)
+
+		// Check the default status is used
+		if response.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected StatusServiceUnavailable from %s. Got: %d", name, response.Code)
+		}
+	}
+}
+
 // Test NotFoundHandler functions and methods
 func TestNotFoundHandler(t *testing.T) {
 
@@ -119,7 +187,11 @@ func TestNotFoundHandler(t *testing.T) {
 	templatePath = filepath.FromSlash("templates/notfound.html")
 
 	// Get a NotFoundHandler with the not found template
-	h = LoadNotFoundHandler(templatePath)
+	h, err := LoadNotFoundHandler(templatePath)
+
+	if err != nil {
+		t.Fatalf("Expected no error from LoadNotFoundHandler. Got: %s", err)
+	}
 
 	// Test ServeHTTP with an arbitrary path
 	response = httptest.NewRecorder()
@@ -139,6 +211,23 @@ func TestNotFoundHandler(t *testing.T) {
 		t.Errorf("Expected \"Not Found: /path"+
 			"\" from NotFoundHandler. Got: %s", bodyString)
 	}
+
+	// Check the Content-Length header matches the body
+	if length := response.Header().Get("Content-Length"); length != "16" {
+		t.Errorf("Expected Content-Length \"16\" from NotFoundHandler. Got: %s",
+			length)
+	}
+
+	// Test ServeHTTP with a HEAD request
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("HEAD", "/path", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the Content-Length header reports the size of the page
+	if length := response.Header().Get("Content-Length"); length != "16" {
+		t.Errorf("Expected Content-Length \"16\" from NotFoundHandler. Got: %s",
+			length)
+	}
 }
 
 // Test FileHandler functions and methods
@@ -162,7 +251,7 @@ func TestFileHandler(t *testing.T) {
 
 	// Get a NotFoundHandler with the not found template
 	templatePath = filepath.FromSlash("templates/notfound.html")
-	nfh = LoadNotFoundHandler(templatePath)
+	nfh = MustLoadNotFoundHandler(templatePath)
 
 	// Get a FileHandler on the testdata directory for the path "/testdata/"
 	h = NewFileHandler("/testdata/", "./testdata", nfh)
@@ -357,3 +446,492 @@ func TestFileHandler(t *testing.T) {
 			"\" from FileHandler. Got: %s", bodyString)
 	}
 }
+
+// Test NotFoundHandler query parameters
+func TestNotFoundHandlerQueryParams(t *testing.T) {
+
+	var (
+		h          *NotFoundHandler
+		tmpl       *template.Template
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a NotFoundHandler that prints the allowed and disallowed parameters
+	tmpl = template.Must(template.New("notfound").Parse(
+		"Not Found: {{.Query.q}}|{{.Query.secret}}"))
+	h = NewNotFoundHandler(tmpl)
+	h.SetQueryParams("q")
+
+	// Test ServeHTTP with a query containing markup and a control character
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET",
+		"/search?q=%3Cb%3Ego%3C%2Fb%3E%07&secret=value", nil)
+	h.ServeHTTP(response, request)
+
+	// Check status code
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound from NotFoundHandler. Got: %d",
+			response.Code)
+	}
+
+	// Check the allowed value is escaped and the other value is not shown
+	bodyString = response.Body.String()
+
+	if bodyString != "Not Found: &lt;b&gt;go&lt;/b&gt;|" {
+		t.Errorf("Expected \"Not Found: &lt;b&gt;go&lt;/b&gt;|"+
+			"\" from NotFoundHandler. Got: %s", bodyString)
+	}
+
+	// Check long values are truncated
+	if value := sanitizeQueryValue(strings.Repeat("a", 300)); len(value) != maxQueryValueLength {
+		t.Errorf("Expected a value of length %d from sanitizeQueryValue. Got: %d",
+			maxQueryValueLength, len(value))
+	}
+}
+
+// Test SetServerHeader and SetHideErrorDetails
+func TestBannerSuppression(t *testing.T) {
+
+	var (
+		h          *ErrorHandler
+		tmpl       *template.Template
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Restore the default settings when the test is done
+	defer func() {
+		serverHeader = atomic.Value{}
+		hideErrorDetails = atomic.Value{}
+	}()
+
+	// Get an ErrorHandler with a template that fails to execute
+	tmpl = template.Must(template.New("error").Parse("{{.Missing}}"))
+	h = NewErrorHandler(tmpl, WithDefaultMessage("Default error message"), WithDisplayErrors(true))
+
+	// Test ServeHTTP with a custom Server header and error details hidden
+	SetServerHeader("handlers")
+	SetHideErrorDetails(true)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the Server header
+	if server := response.Header().Get("Server"); server != "handlers" {
+		t.Errorf("Expected Server header \"handlers\" from ErrorHandler. Got: %s",
+			server)
+	}
+
+	// Check the response body does not contain the template error
+	bodyString = response.Body.String()
+
+	if bodyString != "Internal Server Error\n" {
+		t.Errorf("Expected \"Internal Server Error\" from ErrorHandler. Got: %s",
+			bodyString)
+	}
+
+	// Test ServeHTTP with the Server header suppressed
+	SetServerHeader("")
+
+	response = httptest.NewRecorder()
+	response.Header().Set("Server", "upstream")
+	h.ServeHTTP(response, request)
+
+	// Check the Server header has been removed
+	if server := response.Header().Get("Server"); server != "" {
+		t.Errorf("Expected no Server header from ErrorHandler. Got: %s", server)
+	}
+}
+
+// Test FileHandler redirects with trusted forwarded headers
+func TestFileHandlerForwardedRedirects(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		location string
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	// Get a FileHandler that trusts forwarded headers
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileHandler("/testdata/", "./testdata", nfh)
+	h.SetTrustForwardedHeaders(true)
+
+	// Test ServeHTTP on "/testdata/sub1" from behind a TLS-terminating proxy
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "http://internal/testdata/sub1", nil)
+	request.Header.Set("X-Forwarded-Proto", "https")
+	request.Header.Set("X-Forwarded-Host", "example.com, internal")
+	h.ServeHTTP(response, request)
+
+	// Check the redirect is to the absolute https url
+	location = response.Header().Get("Location")
+
+	if location != "https://example.com/testdata/sub1/" {
+		t.Errorf("Expected a redirect to \"https://example.com/testdata/sub1/\" "+
+			"from FileHandler. Got: %s", location)
+	}
+
+	// Test ServeHTTP on "/testdata/sub1/index.html" with an invalid host
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "http://internal/testdata/sub1/index.html", nil)
+	request.Header.Set("X-Forwarded-Proto", "https")
+	request.Header.Set("X-Forwarded-Host", "evil.com/path")
+	h.ServeHTTP(response, request)
+
+	// Check status code for moved permanently
+	if response.Code != http.StatusMovedPermanently {
+		t.Errorf("Expected StatusMovedPermanently from FileHandler. Got: %d",
+			response.Code)
+	}
+
+	// Check the redirect falls back to the request host
+	location = response.Header().Get("Location")
+
+	if location != "https://internal/testdata/sub1/" {
+		t.Errorf("Expected a redirect to \"https://internal/testdata/sub1/\" "+
+			"from FileHandler. Got: %s", location)
+	}
+}
+
+// Test charset and content language headers on templated responses
+func TestContentHeaders(t *testing.T) {
+
+	var (
+		eh       *ErrorHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	// Get an ErrorHandler and a NotFoundHandler with explicit content headers
+	eh = MustLoadErrorHandler(filepath.FromSlash("templates/error.html"),
+		WithDefaultMessage("Default error message"), WithDisplayErrors(true))
+	eh.SetCharset("utf-8")
+	eh.SetContentLanguage("en-GB")
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	nfh.SetCharset("iso-8859-1")
+	nfh.SetContentLanguage("fr")
+
+	// Test ErrorHandler ServeError
+	response = httptest.NewRecorder()
+	eh.ServeError(response, "Test")
+
+	// Check the content headers
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Expected Content-Type \"text/html; charset=utf-8\" "+
+			"from ErrorHandler. Got: %s", contentType)
+	}
+
+	if language := response.Header().Get("Content-Language"); language != "en-GB" {
+		t.Errorf("Expected Content-Language \"en-GB\" from ErrorHandler. Got: %s",
+			language)
+	}
+
+	// Test NotFoundHandler ServeHTTP
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/path", nil)
+	nfh.ServeHTTP(response, request)
+
+	// Check the content headers
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/html; charset=iso-8859-1" {
+		t.Errorf("Expected Content-Type \"text/html; charset=iso-8859-1\" "+
+			"from NotFoundHandler. Got: %s", contentType)
+	}
+
+	if language := response.Header().Get("Content-Language"); language != "fr" {
+		t.Errorf("Expected Content-Language \"fr\" from NotFoundHandler. Got: %s",
+			language)
+	}
+}
+
+// Test FileHandler with a file system other than a local directory
+func TestFileSystemHandler(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		fileSystem fstest.MapFS
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a FileHandler on an in-memory file system for the path "/files/"
+	fileSystem = fstest.MapFS{
+		"index.html":     {Data: []byte("Index")},
+		"sub/page.html":  {Data: []byte("Page")},
+		"sub/other.html": {Data: []byte("Other")},
+	}
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/files/", http.FS(fileSystem), nfh)
+
+	// Test ServeHTTP on "/files/"
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/files/", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the response body contains the contents of index.html
+	bodyString = response.Body.String()
+
+	if response.Code != http.StatusOK || bodyString != "Index" {
+		t.Errorf("Expected StatusOK and \"Index\" from FileHandler. Got: %d %s",
+			response.Code, bodyString)
+	}
+
+	// Test ServeHTTP on "/files/sub/page.html"
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/files/sub/page.html", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the response body contains the contents of sub/page.html
+	bodyString = response.Body.String()
+
+	if response.Code != http.StatusOK || bodyString != "Page" {
+		t.Errorf("Expected StatusOK and \"Page\" from FileHandler. Got: %d %s",
+			response.Code, bodyString)
+	}
+
+	// Test ServeHTTP on "/files/sub/" which has no index.html
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/files/sub/", nil)
+	h.ServeHTTP(response, request)
+
+	// Check status code for not found
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound from FileHandler. Got: %d",
+			response.Code)
+	}
+}
+
+// Test FileHandler with paths that are unsafe on some platforms
+func TestFileHandlerUnsafePaths(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	// Get a FileHandler on an in-memory file system with awkward file names
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/files/", http.FS(fstest.MapFS{
+		"page.html":    {Data: []byte("Page")},
+		"con.txt":      {Data: []byte("Device")},
+		"sub/nul":      {Data: []byte("Device")},
+		"page.html. ":  {Data: []byte("Stripped")},
+		"a\\b.html":    {Data: []byte("Backslash")},
+		"page.txt:ads": {Data: []byte("Stream")},
+	}), nfh)
+
+	for _, test := range []struct {
+		path   string
+		status int
+	}{
+		{"/files/page.html", http.StatusOK},
+		{"/files/con.txt", http.StatusNotFound},
+		{"/files/CON", http.StatusNotFound},
+		{"/files/sub/nul", http.StatusNotFound},
+		{"/files/page.html.%20", http.StatusNotFound},
+		{"/files/a%5Cb.html", http.StatusNotFound},
+		{"/files/page.txt:ads", http.StatusNotFound},
+		{"/", http.StatusNotFound},
+	} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check status code
+		if response.Code != test.status {
+			t.Errorf("Expected %d from FileHandler for %s. Got: %d",
+				test.status, test.path, response.Code)
+		}
+	}
+}
+
+// Test FileHandler case sensitivity policies
+func TestFileHandlerCaseSensitivity(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a FileHandler on an in-memory file system
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"Docs/Index.html": {Data: []byte("Index")},
+		"Docs/page.html":  {Data: []byte("Lower")},
+		"Docs/PAGE.html":  {Data: []byte("Upper")},
+	}), nfh)
+
+	for _, test := range []struct {
+		policy CaseSensitivity
+		path   string
+		status int
+		body   string
+	}{
+		{CaseSensitive, "/Docs/Index.html", http.StatusOK, "Index"},
+		{CaseSensitive, "/Docs/page.html", http.StatusOK, "Lower"},
+		{CaseSensitive, "/docs/page.html", http.StatusNotFound, "Not Found: /docs/page.html"},
+		{CaseInsensitive, "/docs/page.html", http.StatusOK, "Lower"},
+		{CaseInsensitive, "/DOCS/PAGE.html", http.StatusOK, "Upper"},
+		{CaseInsensitive, "/docs/INDEX.HTML", http.StatusOK, "Index"},
+		{CaseInsensitive, "/docs", http.StatusFound, ""},
+		{CaseInsensitive, "/docs/missing.html", http.StatusNotFound, "Not Found: /docs/missing.html"},
+	} {
+
+		h.SetCaseSensitivity(test.policy)
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the status code and body
+		bodyString = response.Body.String()
+
+		if response.Code != test.status || bodyString != test.body {
+			t.Errorf("Expected %d \"%s\" from FileHandler for %s. Got: %d %s",
+				test.status, test.body, test.path, response.Code, bodyString)
+		}
+	}
+}
+
+// Test FileHandler path normalization
+func TestFileHandlerPathNormalizer(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a FileHandler on a file system with a decomposed file name, and a
+	// normalizer that composes the one character used in the test
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"cafe\u0301.html": {Data: []byte("Decomposed")},
+	}), nfh)
+	h.SetPathNormalizer(func(name string) string {
+		return strings.ReplaceAll(name, "e\u0301", "\u00e9")
+	})
+
+	// Test ServeHTTP with a composed path
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/caf%C3%A9.html", nil)
+	h.ServeHTTP(response, request)
+
+	// Check the decomposed file is served
+	bodyString = response.Body.String()
+
+	if response.Code != http.StatusOK || bodyString != "Decomposed" {
+		t.Errorf("Expected StatusOK and \"Decomposed\" from FileHandler. Got: %d %s",
+			response.Code, bodyString)
+	}
+}
+
+// Test FileHandler decoding of percent-encoded paths
+func TestFileHandlerPercentEncoding(t *testing.T) {
+
+	var (
+		h          *FileHandler
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Get a FileHandler on a file system with names that need encoding
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"a b.html":       {Data: []byte("Space")},
+		"a+b.html":       {Data: []byte("Plus")},
+		"a%20b.html":     {Data: []byte("Literal")},
+		"100%.html":      {Data: []byte("Percent")},
+		"dir/file.html":  {Data: []byte("File")},
+		"dir%2Ffile.htm": {Data: []byte("Encoded")},
+	}), nfh)
+
+	for _, test := range []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/a%20b.html", http.StatusOK, "Space"},
+		{"/a+b.html", http.StatusOK, "Plus"},
+		{"/a%2Bb.html", http.StatusOK, "Plus"},
+		{"/a%2520b.html", http.StatusOK, "Literal"},
+		{"/100%25.html", http.StatusOK, "Percent"},
+		{"/dir/file.html", http.StatusOK, "File"},
+		{"/dir%2Ffile.html", http.StatusNotFound, ""},
+		{"/dir%2ffile.html", http.StatusNotFound, ""},
+		{"/dir%252Ffile.htm", http.StatusOK, "Encoded"},
+		{"/dir%5Cfile.html", http.StatusNotFound, ""},
+		{"/%2e%2e/dir/file.html", http.StatusNotFound, ""},
+		{"/dir/%2E%2E/dir/file.html", http.StatusNotFound, ""},
+		{"/dir/../dir/file.html", http.StatusNotFound, ""},
+		{"/dir/%2e/file.html", http.StatusOK, "File"},
+	} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the status code, and the body for files that are found
+		bodyString = response.Body.String()
+
+		if response.Code != test.status || (test.body != "" && bodyString != test.body) {
+			t.Errorf("Expected %d \"%s\" from FileHandler for %s. Got: %d %s",
+				test.status, test.body, test.path, response.Code, bodyString)
+		}
+	}
+}
+
+// Test the Load functions with a missing template
+func TestLoadMissingTemplate(t *testing.T) {
+
+	missing := filepath.FromSlash("templates/missing.html")
+
+	// Check the Load functions return errors
+	if h, err := LoadErrorHandler(missing); err == nil || h != nil {
+		t.Errorf("Expected an error from LoadErrorHandler. Got: %v", err)
+	}
+
+	if h, err := LoadNotFoundHandler(missing); err == nil || h != nil {
+		t.Errorf("Expected an error from LoadNotFoundHandler. Got: %v", err)
+	}
+
+	// Check the MustLoad functions panic
+	for name, load := range map[string]func(){
+		"MustLoadErrorHandler":    func() { MustLoadErrorHandler(missing) },
+		"MustLoadNotFoundHandler": func() { MustLoadNotFoundHandler(missing) },
+	} {
+
+		func() {
+
+			defer func() {
+
+				if recover() == nil {
+					t.Errorf("Expected %s to panic", name)
+				}
+			}()
+
+			load()
+		}()
+	}
+}
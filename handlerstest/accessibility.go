@@ -0,0 +1,233 @@
+package handlerstest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// MinimumContrast is the lowest contrast ratio between text and background
+// colours accepted by CheckAccessibility, the WCAG AA level for normal text.
+const MinimumContrast float64 = 4.5
+
+// Patterns used to find the parts of a page that are checked.
+var (
+	htmlTagPattern     = regexp.MustCompile(`(?is)<html\b[^>]*>`)
+	langPattern        = regexp.MustCompile(`(?i)\blang\s*=\s*"([^"]*)"`)
+	titlePattern       = regexp.MustCompile(`(?is)<title>(.*?)</title>`)
+	mainPattern        = regexp.MustCompile(`(?i)<main\b|\brole\s*=\s*"main"`)
+	imgTagPattern      = regexp.MustCompile(`(?is)<img\b[^>]*>`)
+	altPattern         = regexp.MustCompile(`(?i)\salt\s*=`)
+	stylePattern       = regexp.MustCompile(`(?is)<style[^>]*>(.*?)</style>`)
+	cssRulePattern     = regexp.MustCompile(`([^{}]+)\{([^{}]*)\}`)
+	cssVariablePattern = regexp.MustCompile(`var\(\s*(--[a-zA-Z0-9-]+)\s*(?:,\s*([^)]*))?\)`)
+	hexColorPattern    = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// CheckAccessibility serves request with handler and reports a test error for
+// each problem AccessibilityProblems finds in the response.
+func CheckAccessibility(t testing.TB, handler http.Handler, request *http.Request) {
+
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+
+	for _, problem := range AccessibilityProblems(response.Body.String()) {
+		t.Errorf("%s %s: %s", request.Method, request.URL.Path, problem)
+	}
+}
+
+// AccessibilityProblems checks an HTML page for basic accessibility
+// requirements and returns a description of each problem it finds. It checks
+// that the html element has a lang attribute, that the page has a title and
+// a main landmark, that every image has an alt attribute, and that the text
+// and background colours set by each rule of the page's stylesheets, using
+// the body's background where a rule sets no background, have a contrast
+// ratio of at least MinimumContrast. Only hexadecimal colours and custom
+// properties set to them are compared. The checks are not a substitute for a
+// full audit, but catch the common mistakes in error and 404 pages.
+func AccessibilityProblems(page string) []string {
+
+	var problems []string
+
+	htmlTag := htmlTagPattern.FindString(page)
+
+	if match := langPattern.FindStringSubmatch(htmlTag); match == nil || strings.TrimSpace(match[1]) == "" {
+		problems = append(problems, "the html element has no lang attribute")
+	}
+
+	if match := titlePattern.FindStringSubmatch(page); match == nil || strings.TrimSpace(match[1]) == "" {
+		problems = append(problems, "the page has no title")
+	}
+
+	if !mainPattern.MatchString(page) {
+		problems = append(problems, "the page has no main landmark")
+	}
+
+	for _, img := range imgTagPattern.FindAllString(page, -1) {
+
+		if !altPattern.MatchString(img) {
+			problems = append(problems, fmt.Sprintf("image %s has no alt attribute", img))
+		}
+	}
+
+	return append(problems, contrastProblems(page)...)
+}
+
+// cssRule holds the colours set by a stylesheet rule.
+type cssRule struct {
+	selector   string
+	color      string
+	background string
+}
+
+// contrastProblems checks the contrast of the colours set by the rules of the
+// page's stylesheets.
+func contrastProblems(page string) []string {
+
+	var (
+		problems []string
+		rules    []cssRule
+	)
+
+	variables := make(map[string]string)
+	bodyBackground := "#ffffff"
+
+	for _, style := range stylePattern.FindAllStringSubmatch(page, -1) {
+
+		for _, match := range cssRulePattern.FindAllStringSubmatch(style[1], -1) {
+
+			rule := cssRule{selector: strings.TrimSpace(match[1])}
+
+			for _, declaration := range strings.Split(match[2], ";") {
+
+				property, value, ok := strings.Cut(declaration, ":")
+
+				if !ok {
+					continue
+				}
+
+				property = strings.ToLower(strings.TrimSpace(property))
+				value = strings.TrimSpace(value)
+
+				switch {
+
+				case strings.HasPrefix(property, "--"):
+					variables[property] = value
+
+				case property == "color":
+					rule.color = value
+
+				case property == "background" || property == "background-color":
+					rule.background = value
+				}
+			}
+
+			rules = append(rules, rule)
+		}
+	}
+
+	resolve := func(value string) string {
+
+		return cssVariablePattern.ReplaceAllStringFunc(value, func(reference string) string {
+
+			match := cssVariablePattern.FindStringSubmatch(reference)
+
+			if value, ok := variables[match[1]]; ok {
+				return value
+			}
+
+			return strings.TrimSpace(match[2])
+		})
+	}
+
+	for _, rule := range rules {
+
+		if rule.selector == "body" && rule.background != "" {
+			bodyBackground = resolve(rule.background)
+		}
+	}
+
+	for _, rule := range rules {
+
+		if rule.color == "" {
+			continue
+		}
+
+		background := bodyBackground
+
+		if rule.background != "" {
+			background = resolve(rule.background)
+		}
+
+		foreground := resolve(rule.color)
+		ratio, ok := contrastRatio(foreground, background)
+
+		if ok && ratio < MinimumContrast {
+			problems = append(problems, fmt.Sprintf("%s has a contrast ratio of %.2f "+
+				"between %s and %s, below %.1f", rule.selector, ratio, foreground,
+				background, MinimumContrast))
+		}
+	}
+
+	return problems
+}
+
+// contrastRatio returns the WCAG contrast ratio of two hexadecimal colours,
+// and false if either is not a hexadecimal colour.
+func contrastRatio(first string, second string) (float64, bool) {
+
+	firstLuminance, ok := relativeLuminance(first)
+
+	if !ok {
+		return 0, false
+	}
+
+	secondLuminance, ok := relativeLuminance(second)
+
+	if !ok {
+		return 0, false
+	}
+
+	lighter := math.Max(firstLuminance, secondLuminance)
+	darker := math.Min(firstLuminance, secondLuminance)
+
+	return (lighter + 0.05) / (darker + 0.05), true
+}
+
+// relativeLuminance returns the WCAG relative luminance of a hexadecimal
+// colour in the form #rgb or #rrggbb.
+func relativeLuminance(color string) (float64, bool) {
+
+	if !hexColorPattern.MatchString(color) {
+		return 0, false
+	}
+
+	hex := color[1:]
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+
+	var channels [3]float64
+
+	for i := range channels {
+
+		value, _ := strconv.ParseUint(hex[2*i:2*i+2], 16, 8)
+		channel := float64(value) / 255
+
+		if channel <= 0.03928 {
+			channels[i] = channel / 12.92
+		} else {
+			channels[i] = math.Pow((channel+0.055)/1.055, 2.4)
+		}
+	}
+
+	return 0.2126*channels[0] + 0.7152*channels[1] + 0.0722*channels[2], true
+}
@@ -0,0 +1,82 @@
+package handlerstest
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/olihawkins/handlers"
+)
+
+// Test that the built-in templates pass the accessibility checks
+func TestBuiltInTemplatesAccessibility(t *testing.T) {
+
+	branding := handlers.Branding{
+		SiteName:    "Example",
+		LogoURL:     "https://example.com/logo.png",
+		AccentColor: "#2b59c3",
+		FooterLinks: []handlers.BrandingLink{{Text: "Home", URL: "/"}},
+	}
+
+	request := httptest.NewRequest("GET", handlers.PreviewNotFoundPath, nil)
+
+	for _, theme := range []string{handlers.ThemePlain, handlers.ThemeDark, handlers.ThemeBranded} {
+
+		errorTemplate := template.Must(handlers.BrandedErrorTemplate(theme, branding))
+		notFoundTemplate := template.Must(handlers.BrandedNotFoundTemplate(theme, branding))
+
+		// Check the error and 404 pages of the theme
+		CheckAccessibility(t, handlers.NewErrorHandler(errorTemplate, handlers.WithDefaultMessage("Error")), request)
+		CheckAccessibility(t, handlers.NewNotFoundHandler(notFoundTemplate), request)
+	}
+
+	CheckAccessibility(t, handlers.NewDefaultNotFoundHandler(), request)
+}
+
+// Test AccessibilityProblems
+func TestAccessibilityProblems(t *testing.T) {
+
+	tests := []struct {
+		page     string
+		problems []string
+	}{
+		{
+			`<html lang="en"><title>Page</title><style>body { color: #000; background: #fff; }</style>` +
+				`<main><img src="a.png" alt=""></main></html>`,
+			nil,
+		},
+		{
+			`<html><title> </title><div><img src="a.png"></div></html>`,
+			[]string{"no lang", "no title", "no main", "no alt"},
+		},
+		{
+			`<html lang="en"><title>Page</title><style>:root { --accent: #ffff00; }` +
+				`header { background: var(--accent, #2b59c3); color: #ffffff; }</style><main></main></html>`,
+			[]string{"header has a contrast ratio of 1.07"},
+		},
+		{
+			`<html lang="en"><title>Page</title><style>body { background: #16181d; }` +
+				`a { color: #222222; }</style><main></main></html>`,
+			[]string{"a has a contrast ratio"},
+		},
+	}
+
+	for _, test := range tests {
+
+		problems := AccessibilityProblems(test.page)
+
+		// Check each expected problem is found, and no others
+		if len(problems) != len(test.problems) {
+			t.Errorf("Expected %d problems for %s. Got: %q", len(test.problems), test.page, problems)
+			continue
+		}
+
+		for i, expected := range test.problems {
+
+			if !strings.Contains(problems[i], expected) {
+				t.Errorf("Expected a problem containing %q. Got: %q", expected, problems[i])
+			}
+		}
+	}
+}
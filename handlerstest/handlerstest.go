@@ -0,0 +1,131 @@
+/*
+Package handlerstest provides utilities for testing the pages served by the
+handlers package.
+
+Its snapshot helpers render a templated handler with canonical sample data and
+compare the response body with a golden file stored under testdata/snapshots,
+so that unintended changes to the markup of error and 404 pages are caught by
+a site's tests. When a change is intended, run the tests with the
+-handlerstest.update flag to rewrite the golden files, and review the diff.
+
+Its accessibility checks report common problems in served pages, and Replay
+re-issues requests recorded by a handlers.RecordingHandler against a local
+handler tree, so that failures reported from production can be reproduced.
+*/
+package handlerstest
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/olihawkins/handlers"
+)
+
+// update rewrites golden files with the current output rather than comparing.
+var update = flag.Bool("handlerstest.update", false, "update snapshot golden files")
+
+// SnapshotDirectory is the directory, relative to the test's package, in which
+// golden files are stored.
+var SnapshotDirectory string = filepath.Join("testdata", "snapshots")
+
+// Snapshot serves request with handler and compares the response body with the
+// golden file SnapshotDirectory/name.html, reporting a test error that shows
+// the first differing line if they do not match.
+func Snapshot(t testing.TB, name string, handler http.Handler, request *http.Request) {
+
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	compareSnapshot(t, name, response.Body.String())
+}
+
+// SnapshotErrorHandler compares the 500 page of the ErrorHandler, showing
+// handlers.PreviewErrorMessage, with the golden file for name.
+func SnapshotErrorHandler(t testing.TB, name string, h *handlers.ErrorHandler) {
+
+	t.Helper()
+
+	response := httptest.NewRecorder()
+	h.AlwaysServeError(response, handlers.PreviewErrorMessage)
+	compareSnapshot(t, name, response.Body.String())
+}
+
+// SnapshotNotFoundHandler compares the 404 page of the NotFoundHandler for a
+// request for handlers.PreviewNotFoundPath with the golden file for name.
+func SnapshotNotFoundHandler(t testing.TB, name string, h *handlers.NotFoundHandler) {
+
+	t.Helper()
+
+	request := httptest.NewRequest("GET", handlers.PreviewNotFoundPath, nil)
+	Snapshot(t, name, h, request)
+}
+
+// compareSnapshot compares the output with the named golden file, or writes
+// the output to the file if the update flag is set.
+func compareSnapshot(t testing.TB, name string, output string) {
+
+	t.Helper()
+
+	goldenPath := filepath.Join(SnapshotDirectory, name+".html")
+
+	if *update {
+
+		if err := os.MkdirAll(SnapshotDirectory, 0755); err != nil {
+			t.Fatalf("Failed to create %s: %s", SnapshotDirectory, err)
+		}
+
+		if err := os.WriteFile(goldenPath, []byte(output), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %s", goldenPath, err)
+		}
+
+		return
+	}
+
+	contents, err := os.ReadFile(goldenPath)
+
+	if err != nil {
+		t.Errorf("Failed to read snapshot %s: %s (run with -handlerstest.update to create it)",
+			goldenPath, err)
+		return
+	}
+
+	if line, expected, got, differ := firstDifference(string(contents), output); differ {
+		t.Errorf("Snapshot %s differs at line %d.\nExpected: %s\nGot:      %s",
+			goldenPath, line, expected, got)
+	}
+}
+
+// firstDifference returns the number and contents of the first line at which
+// expected and got differ, and whether they differ at all.
+func firstDifference(expected string, got string) (int, string, string, bool) {
+
+	if expected == got {
+		return 0, "", "", false
+	}
+
+	expectedLines := strings.Split(expected, "\n")
+	gotLines := strings.Split(got, "\n")
+
+	for i := 0; ; i++ {
+
+		var expectedLine, gotLine string
+
+		if i < len(expectedLines) {
+			expectedLine = expectedLines[i]
+		}
+
+		if i < len(gotLines) {
+			gotLine = gotLines[i]
+		}
+
+		if expectedLine != gotLine || i >= len(expectedLines) || i >= len(gotLines) {
+			return i + 1, expectedLine, gotLine, true
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlerstest
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olihawkins/handlers"
+)
+
+// Test the snapshot helpers with the built-in templates
+func TestSnapshots(t *testing.T) {
+
+	SnapshotErrorHandler(t, "default-error", handlers.NewDefaultErrorHandler())
+	SnapshotNotFoundHandler(t, "default-notfound", handlers.NewDefaultNotFoundHandler())
+
+	// Check a changed page is reported
+	changed := handlers.NewNotFoundHandler(template.Must(template.New("changed").Parse(
+		`Changed page for {{.Path}}`)))
+
+	recorder := &recordingTB{TB: t}
+	SnapshotNotFoundHandler(recorder, "default-notfound", changed)
+
+	if !*update && !recorder.failed {
+		t.Errorf("Expected a changed page to fail its snapshot")
+	}
+
+	// Test Snapshot with any handler and request
+	Snapshot(t, "default-notfound", handlers.NewDefaultNotFoundHandler(),
+		httptest.NewRequest("GET", handlers.PreviewNotFoundPath, nil))
+}
+
+// recordingTB records test errors rather than reporting them.
+type recordingTB struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+
+	r.failed = true
+}
+
+// Test firstDifference
+func TestFirstDifference(t *testing.T) {
+
+	tests := []struct {
+		expected string
+		got      string
+		line     int
+		differ   bool
+	}{
+		{"a\nb\nc", "a\nb\nc", 0, false},
+		{"a\nb\nc", "a\nx\nc", 2, true},
+		{"a\nb", "a\nb\nc", 3, true},
+		{"a\nb\n", "a\nb", 3, true},
+	}
+
+	for _, test := range tests {
+
+		// Check the first differing line is found
+		if line, _, _, differ := firstDifference(test.expected, test.got); line != test.line || differ != test.differ {
+			t.Errorf("Expected line %d (%t) for %q and %q. Got: %d (%t)",
+				test.line, test.differ, test.expected, test.got, line, differ)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package handlerstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olihawkins/handlers"
+)
+
+// Replayed is a recording replayed against a handler, with the response the
+// handler gave.
+type Replayed struct {
+	Recording handlers.Recording
+	Response  *httptest.ResponseRecorder
+}
+
+// Reproduced reports whether the replayed response has the recorded status,
+// which means the failure the recording captured still happens.
+func (r Replayed) Reproduced() bool {
+
+	return r.Response.Code == r.Recording.Status
+}
+
+// Replay loads the recordings written by a handlers.RecordingHandler to
+// directory and serves each of them with handler, oldest first, so that
+// failures reported from production can be reproduced against a local
+// handler tree. A test can check each result with Reproduced, or check that
+// a fix now gives the expected response. The test fails if the recordings
+// cannot be loaded.
+func Replay(t testing.TB, handler http.Handler, directory string) []Replayed {
+
+	t.Helper()
+
+	recordings, err := handlers.LoadRecordings(directory)
+
+	if err != nil {
+		t.Fatalf("Expected to load the recordings in %s. Got: %s", directory, err)
+	}
+
+	replayed := make([]Replayed, 0, len(recordings))
+
+	for _, recording := range recordings {
+
+		response := httptest.NewRecorder()
+		handler.ServeHTTP(response, ReplayRequest(recording))
+		replayed = append(replayed, Replayed{Recording: recording, Response: response})
+	}
+
+	return replayed
+}
+
+// ReplayRequest returns a request with the method, url, host and headers of
+// the recording.
+func ReplayRequest(recording handlers.Recording) *http.Request {
+
+	request := httptest.NewRequest(recording.Method, recording.URL, nil)
+	request.Host = recording.Host
+
+	for name, values := range recording.Header {
+		request.Header[name] = append([]string(nil), values...)
+	}
+
+	return request
+}
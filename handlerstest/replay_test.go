@@ -0,0 +1,57 @@
+package handlerstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/olihawkins/handlers"
+)
+
+// Test Replay
+func TestReplay(t *testing.T) {
+
+	directory := t.TempDir()
+
+	broken := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Header.Get("Accept-Language") == "fr" {
+			http.Error(w, "Erreur", http.StatusInternalServerError)
+			return
+		}
+
+		w.Write([]byte("OK"))
+	})
+
+	recorder, err := handlers.NewRecordingHandler(broken, directory)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewRecordingHandler. Got: %s", err)
+	}
+
+	// Record a failure that only happens for some clients
+	request := httptest.NewRequest("GET", "/page?lang=fr", nil)
+	request.Header.Set("Accept-Language", "fr")
+	recorder.ServeHTTP(httptest.NewRecorder(), request)
+
+	// Check replaying against the same handler reproduces the failure
+	replayed := Replay(t, broken, directory)
+
+	if len(replayed) != 1 || !replayed[0].Reproduced() {
+		t.Fatalf("Expected the failure to be reproduced. Got: %+v", replayed)
+	}
+
+	if path := replayed[0].Recording.URL; path != "/page?lang=" {
+		t.Errorf("Expected the anonymized url. Got: %s", path)
+	}
+
+	// Check replaying against a fixed handler does not
+	fixed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		w.Write([]byte("OK"))
+	})
+
+	if replayed := Replay(t, fixed, directory); replayed[0].Reproduced() {
+		t.Errorf("Expected the fixed handler not to reproduce the failure")
+	}
+}
@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// imageFormat is an image format that can be served in place of an image
+// when a sidecar file in that format exists and the client accepts it.
+type imageFormat struct {
+	extension string
+	mediaType string
+}
+
+// imageFormats are the negotiated image formats in order of preference.
+var imageFormats = []imageFormat{
+	{".avif", "image/avif"},
+	{".webp", "image/webp"},
+}
+
+// negotiableImageExtensions are the extensions of images for which sidecar
+// files are looked for.
+var negotiableImageExtensions = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+}
+
+// SetImageNegotiation controls whether the handler serves images in the best
+// format the client accepts. When it is on, a request for a JPEG, PNG or GIF
+// image is answered with a sidecar file holding the image in AVIF or WebP,
+// named by appending the format's extension, as in photo.jpg.avif, if the
+// sidecar exists and the client's Accept header names its format. AVIF is
+// preferred to WebP. Otherwise the original image is served. Responses for
+// images with sidecars carry Vary: Accept, so caches keep the formats apart.
+// Sidecars are served as they are stored, so turning negotiation on while the
+// handler strips image metadata panics. By default negotiation is off.
+func (h *FileHandler) SetImageNegotiation(negotiate bool) {
+
+	refuseStrippedNegotiation(negotiate && h.strippedImages != nil)
+	h.negotiateImages = negotiate
+}
+
+// imageVariant returns the path of the sidecar file to serve for the request
+// in place of the image at filePath, and whether there is one. It sets the
+// Content-Type of the response to the sidecar's format, and marks the
+// response as varying if the image has any sidecars.
+func (h *FileHandler) imageVariant(w http.ResponseWriter, r *http.Request, filePath string) (string, bool) {
+
+	if !h.negotiateImages || !negotiableImageExtensions[strings.ToLower(path.Ext(filePath))] {
+		return filePath, false
+	}
+
+	for _, format := range imageFormats {
+
+		sidecarPath := filePath + format.extension
+
+		if !h.isRegularFile(sidecarPath) {
+			continue
+		}
+
+		addVary(w, "Accept")
+
+		if acceptsMediaType(r, format.mediaType) {
+			w.Header().Set("Content-Type", format.mediaType)
+			return sidecarPath, true
+		}
+	}
+
+	return filePath, false
+}
+
+// isRegularFile reports whether filePath names a regular file in the
+// handler's file system.
+func (h *FileHandler) isRegularFile(filePath string) bool {
+
+	file, err := h.fileSystem.Open(filePath)
+
+	if err != nil {
+		return false
+	}
+
+	defer file.Close()
+	finfo, err := file.Stat()
+
+	return err == nil && finfo.Mode().IsRegular()
+}
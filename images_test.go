@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler image format negotiation
+func TestImageNegotiation(t *testing.T) {
+
+	const (
+		chrome  string = "image/avif,image/webp,image/apng,image/*,*/*;q=0.8"
+		webp    string = "image/webp,*/*"
+		noAVIF  string = "image/avif;q=0, image/webp"
+		generic string = "image/*"
+	)
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"photo.jpg":      {Data: []byte("JPEG")},
+		"photo.jpg.avif": {Data: []byte("AVIF")},
+		"photo.jpg.webp": {Data: []byte("WebP")},
+		"logo.png":       {Data: []byte("PNG")},
+		"logo.png.webp":  {Data: []byte("WebP logo")},
+		"plain.gif":      {Data: []byte("GIF")},
+	}), nfh)
+	h.SetImageNegotiation(true)
+
+	tests := []struct {
+		path        string
+		accept      string
+		body        string
+		contentType string
+		vary        string
+	}{
+		{"/photo.jpg", chrome, "AVIF", "image/avif", "Accept"},
+		{"/photo.jpg", webp, "WebP", "image/webp", "Accept"},
+		{"/photo.jpg", noAVIF, "WebP", "image/webp", "Accept"},
+		{"/photo.jpg", generic, "JPEG", "image/jpeg", "Accept"},
+		{"/logo.png", chrome, "WebP logo", "image/webp", "Accept"},
+		{"/plain.gif", chrome, "GIF", "image/gif", ""},
+		{"/photo.jpg.avif", webp, "AVIF", "image/avif", ""},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the Accept header
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		request.Header.Set("Accept", test.accept)
+		h.ServeHTTP(response, request)
+
+		// Check the best accepted format is served with its content type
+		if body := response.Body.String(); body != test.body {
+			t.Errorf("Expected %q for %s with %q. Got: %q", test.body, test.path, test.accept, body)
+		}
+
+		if contentType := response.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("Expected Content-Type %q for %s with %q. Got: %q",
+				test.contentType, test.path, test.accept, contentType)
+		}
+
+		if vary := response.Header().Get("Vary"); vary != test.vary {
+			t.Errorf("Expected Vary %q for %s. Got: %q", test.vary, test.path, vary)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+)
+
+// Compile-time checks that the package's types implement the interfaces they
+// are documented to implement, so that a change to a method signature fails
+// the build rather than a caller's.
+var (
+	_ http.Handler    = (*ErrorHandler)(nil)
+	_ http.Handler    = (*NotFoundHandler)(nil)
+	_ http.Handler    = (*FileHandler)(nil)
+	_ http.Handler    = (*AnalyticsHandler)(nil)
+	_ http.Handler    = (*TokenAuthHandler)(nil)
+	_ http.Handler    = (*OIDCHandler)(nil)
+	_ http.Handler    = (*JWTHandler)(nil)
+	_ http.Handler    = (*ClientCertHandler)(nil)
+	_ http.Handler    = (*UserDirHandler)(nil)
+	_ http.Handler    = (*Syncer)(nil)
+	_ http.Handler    = (*ErrorPreviewHandler)(nil)
+	_ http.Handler    = (*DebugHandler)(nil)
+	_ http.Handler    = (*RecordingHandler)(nil)
+	_ http.Handler    = (*FaultHandler)(nil)
+	_ http.FileSystem = (*OriginFileSystem)(nil)
+	_ http.FileSystem = (*FailoverFileSystem)(nil)
+	_ http.FileSystem = (*ArchiveFileSystem)(nil)
+	_ http.FileSystem = (*EncryptedFileSystem)(nil)
+	_ http.FileSystem = (*GitFileSystem)(nil)
+	_ http.FileSystem = (*SwapFileSystem)(nil)
+	_ io.Closer       = (*ArchiveFileSystem)(nil)
+	_ io.Closer       = (*AnalyticsHandler)(nil)
+	_ io.Closer       = (*Syncer)(nil)
+	_ Clock           = ClockFunc(nil)
+	_ Clock           = (*FixedClock)(nil)
+	_ http.File       = (*encryptedFile)(nil)
+	_ http.File       = (*archiveFile)(nil)
+	_ http.File       = (*failoverFile)(nil)
+	_ http.File       = (*gitFile)(nil)
+)
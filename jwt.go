@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxFetchedJSON is the maximum size in bytes of a discovery document, key set
+// or token response read from a provider.
+const maxFetchedJSON int64 = 1 << 20
+
+// jwksRefreshInterval is the minimum time between fetches of a key set, so
+// that tokens with unknown key ids cannot be used to flood the provider.
+const jwksRefreshInterval time.Duration = time.Minute
+
+// jwksMaxAge is how long a fetched key set is used before it is fetched
+// again, so that keys the provider has withdrawn stop being accepted.
+const jwksMaxAge time.Duration = time.Hour
+
+// jwksMaxStale is how long a key set is used after it was last fetched while
+// the provider cannot be reached. After that every token is refused, as a key
+// the provider has withdrawn would otherwise be accepted for as long as the
+// provider is down.
+const jwksMaxStale time.Duration = 2 * jwksMaxAge
+
+// claimsContextKey is the context key for the claims of the token or login
+// that authenticated a request.
+type claimsContextKey struct{}
+
+// JWTConfig configures a JWTHandler. JWKSURL is the url of the JSON Web Key
+// Set holding the keys that sign accepted tokens. Issuer and Audience are the
+// values the tokens' iss and aud claims must have. All three are required.
+type JWTConfig struct {
+	JWKSURL  string
+	Issuer   string
+	Audience string
+}
+
+// JWTHandler protects another handler with JSON Web Tokens, the usual way of
+// authenticating API requests that accompany a site. Requests must send an
+// Authorization header of the form "Bearer <token>", where the token is
+// signed with RS256 or ES256 by a key in the configured key set and has the
+// configured issuer and audience, and has not expired. Keys are fetched when
+// they are first needed and cached. The key set is fetched again when a token
+// names a key that is not in the cache, which happens when the issuer rotates
+// its keys, and at least every hour. If the key set cannot be fetched the
+// cached keys are used for up to two hours after the last successful fetch,
+// and then no token is accepted until it can be. Requests without a valid
+// token get a 401. The token's claims are placed in the request context, where they can be
+// read with RequestClaims, and the package's templated handlers pass them to
+// their templates.
+type JWTHandler struct {
+	config JWTConfig
+	next   http.Handler
+	keys   *jwksCache
+}
+
+// NewJWTHandler returns a new JWTHandler protecting next. It returns an error
+// if the key set url, issuer or audience is empty, rather than accepting
+// tokens without checking them.
+func NewJWTHandler(config JWTConfig, next http.Handler) (*JWTHandler, error) {
+
+	if config.JWKSURL == "" || config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("handlers: JWT key set url, issuer and audience are required")
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+
+	return &JWTHandler{
+		config: config,
+		next:   next,
+		keys:   newJWKSCache(config.JWKSURL, client, SystemClock),
+	}, nil
+}
+
+// SetClock sets the clock used to check the validity of tokens and the age of
+// the cached keys. By default the handler uses SystemClock.
+func (h *JWTHandler) SetClock(clock Clock) {
+
+	h.keys.mutex.Lock()
+	defer h.keys.mutex.Unlock()
+
+	h.keys.clock = clock
+}
+
+// ServeHTTP calls the protected handler if the request has a valid token, and
+// responds with a 401 otherwise.
+func (h *JWTHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	token := bearerToken(r)
+
+	if token == "" {
+		serveUnauthorized(w)
+		return
+	}
+
+	claims, err := parseJWT(token, h.keys.key)
+
+	if err == nil {
+		err = checkJWTClaims(claims, h.config.Issuer, h.config.Audience, h.keys.now())
+	}
+
+	if err != nil {
+		serveUnauthorized(w)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), claimsContextKey{}, claims)
+	h.next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequestClaims returns the claims of the token or login that authenticated
+// the request with a JWTHandler or an OIDCHandler, or nil if the request has
+// not passed through either.
+func RequestClaims(r *http.Request) map[string]interface{} {
+
+	if r == nil {
+		return nil
+	}
+
+	claims, _ := r.Context().Value(claimsContextKey{}).(map[string]interface{})
+	return claims
+}
+
+// jwksCache fetches and caches the public keys in a JSON Web Key Set. Keys
+// are fetched when the cache is first used and again when a token is signed
+// with a key id that is not in the cache, which is how providers rotate keys.
+// The mutex guards the cache's fields and is never held during a fetch, so
+// that requests signed with cached keys are not delayed by a slow provider.
+// The fetch mutex makes concurrent requests for unknown keys share one fetch.
+type jwksCache struct {
+	url        string
+	client     *http.Client
+	clock      Clock
+	mutex      sync.Mutex
+	fetchMutex sync.Mutex
+	keys       map[string]crypto.PublicKey
+	fetched    time.Time
+	loaded     time.Time
+}
+
+// newJWKSCache returns a new jwksCache for the key set at url.
+func newJWKSCache(url string, client *http.Client, clock Clock) *jwksCache {
+
+	return &jwksCache{
+		url:    url,
+		client: client,
+		clock:  clock,
+		keys:   make(map[string]crypto.PublicKey),
+	}
+}
+
+// now returns the current time from the cache's clock.
+func (c *jwksCache) now() time.Time {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.clock.Now()
+}
+
+// key returns the public key with the given key id.
+func (c *jwksCache) key(kid string) (crypto.PublicKey, error) {
+
+	if key, ok, stale := c.cached(kid); ok && !stale {
+		return key, nil
+	}
+
+	// Fetch the key set again, unless it was fetched very recently, keeping
+	// the cached keys if the fetch fails
+	if err := c.refresh(); err != nil {
+		return nil, err
+	}
+
+	if key, ok, _ := c.cached(kid); ok {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("handlers: unknown signing key %q", kid)
+}
+
+// cached returns the cached key with the given key id, and whether the key
+// set was last fetched, or a fetch was last tried, more than jwksMaxAge ago.
+// No key is returned once the key set is older than jwksMaxStale.
+func (c *jwksCache) cached(kid string) (crypto.PublicKey, bool, bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.clock.Now()
+
+	if now.Sub(c.loaded) >= jwksMaxStale {
+		return nil, false, true
+	}
+
+	key, ok := c.keys[kid]
+	return key, ok, now.Sub(c.fetched) >= jwksMaxAge
+}
+
+// refresh fetches the key set unless it was fetched in the last
+// jwksRefreshInterval. It returns an error only if the fetch fails and there
+// are no cached keys to fall back on, which cached refuses to use once they
+// are older than jwksMaxStale.
+func (c *jwksCache) refresh() error {
+
+	c.fetchMutex.Lock()
+	defer c.fetchMutex.Unlock()
+
+	// Another request may have fetched the key set while this one waited
+	c.mutex.Lock()
+	now := c.clock.Now()
+
+	if !c.fetched.IsZero() && now.Sub(c.fetched) < jwksRefreshInterval {
+		c.mutex.Unlock()
+		return nil
+	}
+
+	c.fetched = now
+	c.mutex.Unlock()
+
+	keys, err := fetchJWKS(c.client, c.url)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err == nil {
+		c.keys = keys
+		c.loaded = now
+		return nil
+	}
+
+	if len(c.keys) == 0 {
+		return err
+	}
+
+	return nil
+}
+
+// jsonWebKey holds the fields of a JSON Web Key used for RSA and EC keys.
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// fetchJWKS fetches the key set at url and returns its signing keys by key
+// id. Keys of unsupported types are skipped.
+func fetchJWKS(client *http.Client, url string) (map[string]crypto.PublicKey, error) {
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+
+	if err := fetchJSON(client, url, &set); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+
+	for _, jwk := range set.Keys {
+
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	return keys, nil
+}
+
+// publicKey returns the public key described by the JSON Web Key.
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+
+	switch jwk.Kty {
+
+	case "RSA":
+
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("handlers: invalid RSA key")
+		}
+
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+
+		x, errX := base64.RawURLEncoding.DecodeString(jwk.X)
+		y, errY := base64.RawURLEncoding.DecodeString(jwk.Y)
+
+		if jwk.Crv != "P-256" || errX != nil || errY != nil {
+			return nil, errors.New("handlers: invalid EC key")
+		}
+
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("handlers: invalid EC key")
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("handlers: unsupported key type %q", jwk.Kty)
+}
+
+// parseJWT checks the signature of a compact JSON Web Token with the key
+// returned by key and returns its claims. RS256 and ES256 signatures are
+// supported. The claims themselves are not checked.
+func parseJWT(token string, key func(kid string) (crypto.PublicKey, error)) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+
+	if len(parts) != 3 {
+		return nil, errors.New("handlers: malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+
+	if err != nil {
+		return nil, errors.New("handlers: malformed token signature")
+	}
+
+	publicKey, err := key(header.Kid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+
+	switch publicKey := publicKey.(type) {
+
+	case *rsa.PublicKey:
+
+		if header.Alg != "RS256" {
+			return nil, fmt.Errorf("handlers: unexpected token algorithm %q", header.Alg)
+		}
+
+		if err := rsa.VerifyPKCS1v15(publicKey, crypto.SHA256, digest[:], signature); err != nil {
+			return nil, errors.New("handlers: invalid token signature")
+		}
+
+	case *ecdsa.PublicKey:
+
+		if header.Alg != "ES256" || len(signature) != 64 {
+			return nil, fmt.Errorf("handlers: unexpected token algorithm %q", header.Alg)
+		}
+
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+
+		if !ecdsa.Verify(publicKey, digest[:], r, s) {
+			return nil, errors.New("handlers: invalid token signature")
+		}
+
+	default:
+
+		return nil, errors.New("handlers: unsupported signing key")
+	}
+
+	var claims map[string]interface{}
+
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	return claims, nil
+}
+
+// decodeJWTPart decodes a base64url encoded JSON part of a token into value.
+func decodeJWTPart(part string, value interface{}) error {
+
+	contents, err := base64.RawURLEncoding.DecodeString(part)
+
+	if err != nil {
+		return errors.New("handlers: malformed token")
+	}
+
+	if err := json.Unmarshal(contents, value); err != nil {
+		return errors.New("handlers: malformed token")
+	}
+
+	return nil
+}
+
+// checkJWTClaims checks the issuer, audience and validity period of a
+// token's claims at the given time. A small leeway allows for clock skew
+// between the server and the token's issuer.
+func checkJWTClaims(claims map[string]interface{}, issuer string, audience string, now time.Time) error {
+
+	const leeway = time.Minute
+
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("handlers: unexpected token issuer %q", iss)
+	}
+
+	if !jwtHasAudience(claims["aud"], audience) {
+		return errors.New("handlers: token is not for this audience")
+	}
+
+	exp, ok := claims["exp"].(float64)
+
+	if !ok || now.Add(-leeway).Unix() >= int64(exp) {
+		return errors.New("handlers: token has expired")
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Unix() < int64(nbf) {
+		return errors.New("handlers: token is not yet valid")
+	}
+
+	return nil
+}
+
+// jwtHasAudience reports whether the aud claim, a string or an array of
+// strings, contains the audience.
+func jwtHasAudience(aud interface{}, audience string) bool {
+
+	switch aud := aud.(type) {
+
+	case string:
+
+		return aud == audience
+
+	case []interface{}:
+
+		for _, value := range aud {
+
+			if value == audience {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// fetchJSON fetches the JSON document at url into value.
+func fetchJSON(client *http.Client, url string, value interface{}) error {
+
+	response, err := client.Get(url)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("handlers: fetching %s returned %s", url, response.Status)
+	}
+
+	return json.NewDecoder(io.LimitReader(response.Body, maxFetchedJSON)).Decode(value)
+}
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// signTestJWT returns a token with the given claims signed by key, which is
+// an *rsa.PrivateKey or an *ecdsa.PrivateKey.
+func signTestJWT(key crypto.Signer, kid string, claims map[string]interface{}) string {
+
+	alg := "RS256"
+
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		alg = "ES256"
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid})
+	payload, _ := json.Marshal(claims)
+
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	var signature []byte
+
+	switch key := key.(type) {
+
+	case *rsa.PrivateKey:
+
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+
+	case *ecdsa.PrivateKey:
+
+		r, s, _ := ecdsa.Sign(rand.Reader, key, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// testJWK returns the JSON Web Key for the public part of key.
+func testJWK(key crypto.Signer, kid string) map[string]string {
+
+	switch key := key.(type) {
+
+	case *rsa.PrivateKey:
+
+		return map[string]string{
+			"kid": kid,
+			"kty": "RSA",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}
+
+	case *ecdsa.PrivateKey:
+
+		return map[string]string{
+			"kid": kid,
+			"kty": "EC",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+
+	return nil
+}
+
+// Test the JWTHandler
+func TestJWTHandler(t *testing.T) {
+
+	var (
+		h        *JWTHandler
+		clock    *FixedClock
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		fetches  int
+		err      error
+	)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	rotatedKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	// Serve a key set that gains a rotated key after the first fetch
+	keySet := []map[string]string{testJWK(rsaKey, "rsa"), testJWK(ecKey, "ec")}
+
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		fetches++
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keySet})
+	}))
+
+	defer jwks.Close()
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = NewFixedClock(now)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, RequestClaims(r)["sub"])
+	})
+
+	h, err = NewJWTHandler(JWTConfig{
+		JWKSURL:  jwks.URL,
+		Issuer:   "https://issuer.example.com/",
+		Audience: "api",
+	}, next)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewJWTHandler. Got: %s", err)
+	}
+
+	h.SetClock(clock)
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+
+		c := map[string]interface{}{
+			"iss": "https://issuer.example.com/",
+			"aud": "api",
+			"sub": "alice",
+			"exp": now.Add(time.Hour).Unix(),
+		}
+
+		for name, value := range changes {
+			c[name] = value
+		}
+
+		return c
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		status int
+	}{
+		{"RS256", signTestJWT(rsaKey, "rsa", claims(nil)), http.StatusOK},
+		{"ES256", signTestJWT(ecKey, "ec", claims(nil)), http.StatusOK},
+		{"audience array", signTestJWT(ecKey, "ec", claims(map[string]interface{}{
+			"aud": []string{"other", "api"}})), http.StatusOK},
+		{"wrong issuer", signTestJWT(rsaKey, "rsa", claims(map[string]interface{}{
+			"iss": "https://evil.example.com/"})), http.StatusUnauthorized},
+		{"wrong audience", signTestJWT(rsaKey, "rsa", claims(map[string]interface{}{
+			"aud": "other"})), http.StatusUnauthorized},
+		{"expired", signTestJWT(rsaKey, "rsa", claims(map[string]interface{}{
+			"exp": now.Add(-time.Hour).Unix()})), http.StatusUnauthorized},
+		{"not yet valid", signTestJWT(rsaKey, "rsa", claims(map[string]interface{}{
+			"nbf": now.Add(time.Hour).Unix()})), http.StatusUnauthorized},
+		{"wrong key", signTestJWT(ecKey, "rsa", claims(nil)), http.StatusUnauthorized},
+		{"malformed", "not.a.token", http.StatusUnauthorized},
+		{"missing", "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the token
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/api/", nil)
+
+		if test.token != "" {
+			request.Header.Set("Authorization", "Bearer "+test.token)
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the status and that the claims reached the handler
+		if response.Code != test.status {
+			t.Errorf("Expected %d for the %s token. Got: %d", test.status, test.name, response.Code)
+		}
+
+		if test.status == http.StatusOK && response.Body.String() != "alice" {
+			t.Errorf("Expected the subject for the %s token. Got: %s",
+				test.name, response.Body.String())
+		}
+	}
+
+	if fetches != 1 {
+		t.Errorf("Expected the key set to be fetched once. Got: %d", fetches)
+	}
+
+	// Check a rotated key is found by fetching the key set again
+	keySet = append(keySet, testJWK(rotatedKey, "rotated"))
+	clock.Advance(2 * time.Minute)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/api/", nil)
+	request.Header.Set("Authorization", "Bearer "+signTestJWT(rotatedKey, "rotated",
+		claims(map[string]interface{}{"exp": clock.Now().Add(time.Hour).Unix()})))
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK || fetches != 2 {
+		t.Errorf("Expected the rotated key to be fetched. Got: %d after %d fetches",
+			response.Code, fetches)
+	}
+
+	// Check the claims reach a NotFoundHandler template
+	nfh := NewNotFoundHandler(template.Must(template.New("notfound").Parse(
+		"{{.Claims.sub}} not found")))
+	h, err = NewJWTHandler(JWTConfig{
+		JWKSURL:  jwks.URL,
+		Issuer:   "https://issuer.example.com/",
+		Audience: "api",
+	}, nfh)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewJWTHandler. Got: %s", err)
+	}
+	h.SetClock(clock)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/api/missing", nil)
+	request.Header.Set("Authorization", "Bearer "+signTestJWT(rsaKey, "rsa",
+		claims(map[string]interface{}{"exp": clock.Now().Add(time.Hour).Unix()})))
+	h.ServeHTTP(response, request)
+
+	if response.Body.String() != "alice not found" {
+		t.Errorf("Expected the claims in the template. Got: %s", response.Body.String())
+	}
+
+	// Check a slow fetch for an unknown key does not delay cached keys
+	fetching := make(chan struct{})
+	release := make(chan struct{})
+	blocking := false
+
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if blocking {
+			close(fetching)
+			<-release
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keySet})
+	}))
+
+	defer slow.Close()
+
+	h, err = NewJWTHandler(JWTConfig{
+		JWKSURL:  slow.URL,
+		Issuer:   "https://issuer.example.com/",
+		Audience: "api",
+	}, next)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewJWTHandler. Got: %s", err)
+	}
+	h.SetClock(clock)
+
+	valid := signTestJWT(rsaKey, "rsa",
+		claims(map[string]interface{}{"exp": clock.Now().Add(time.Hour).Unix()}))
+	request, _ = http.NewRequest("GET", "/api/", nil)
+	request.Header.Set("Authorization", "Bearer "+valid)
+	h.ServeHTTP(httptest.NewRecorder(), request)
+
+	blocking = true
+	clock.Advance(2 * time.Minute)
+
+	go func() {
+
+		request, _ := http.NewRequest("GET", "/api/", nil)
+		request.Header.Set("Authorization", "Bearer "+signTestJWT(ecKey, "unknown",
+			claims(map[string]interface{}{"exp": clock.Now().Add(time.Hour).Unix()})))
+		h.ServeHTTP(httptest.NewRecorder(), request)
+	}()
+
+	<-fetching
+	done := make(chan int)
+
+	go func() {
+
+		response := httptest.NewRecorder()
+		request, _ := http.NewRequest("GET", "/api/", nil)
+		request.Header.Set("Authorization", "Bearer "+valid)
+		h.ServeHTTP(response, request)
+		done <- response.Code
+	}()
+
+	select {
+
+	case code := <-done:
+
+		if code != http.StatusOK {
+			t.Errorf("Expected StatusOK for a cached key during a fetch. Got: %d", code)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Errorf("Expected a cached key to be used without waiting for a fetch")
+	}
+
+	close(release)
+
+	// Check cached keys are used while the key set cannot be fetched, but
+	// only for a limited time
+	down := false
+
+	unreliable := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if down {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keySet})
+	}))
+
+	defer unreliable.Close()
+
+	h, err = NewJWTHandler(JWTConfig{
+		JWKSURL:  unreliable.URL,
+		Issuer:   "https://issuer.example.com/",
+		Audience: "api",
+	}, next)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewJWTHandler. Got: %s", err)
+	}
+
+	h.SetClock(clock)
+
+	for _, test := range []struct {
+		advance time.Duration
+		status  int
+	}{
+		{0, http.StatusOK},
+		{90 * time.Minute, http.StatusOK},
+		{45 * time.Minute, http.StatusUnauthorized},
+	} {
+
+		down = test.advance > 0
+		clock.Advance(test.advance)
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/api/", nil)
+		request.Header.Set("Authorization", "Bearer "+signTestJWT(rsaKey, "rsa",
+			claims(map[string]interface{}{"exp": clock.Now().Add(time.Hour).Unix()})))
+		h.ServeHTTP(response, request)
+
+		if response.Code != test.status {
+			t.Errorf("Expected %d with the key set down at %s. Got: %d",
+				test.status, clock.Now(), response.Code)
+		}
+	}
+
+	// Check the key set url, issuer and audience are required
+	for _, config := range []JWTConfig{
+		{Issuer: "https://issuer.example.com/", Audience: "api"},
+		{JWKSURL: unreliable.URL, Audience: "api"},
+		{JWKSURL: unreliable.URL, Issuer: "https://issuer.example.com/"},
+	} {
+
+		if _, err := NewJWTHandler(config, next); err == nil {
+			t.Errorf("Expected an error from NewJWTHandler for %+v", config)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"time"
+)
+
+// templateLimits holds the limits on executing a handler's template. A zero
+// value means no limit.
+type templateLimits struct {
+	maxOutput int64
+	timeout   time.Duration
+}
+
+// SetExecutionLimits limits the execution of the handler's template, so that
+// a pathological template cannot produce an unbounded response or hold a
+// request's goroutine indefinitely. If the template writes more than
+// maxOutput bytes, or takes longer than timeout to execute, execution is
+// stopped and the error is reported with the built-in http error. A zero
+// value means no limit, which is the default for both.
+func (h *ErrorHandler) SetExecutionLimits(maxOutput int64, timeout time.Duration) {
+
+	h.limits = templateLimits{maxOutput: maxOutput, timeout: timeout}
+}
+
+// SetExecutionLimits limits the execution of the handler's template. See
+// ErrorHandler.SetExecutionLimits for details.
+func (h *NotFoundHandler) SetExecutionLimits(maxOutput int64, timeout time.Duration) {
+
+	h.limits = templateLimits{maxOutput: maxOutput, timeout: timeout}
+}
+
+// executeTemplate executes the template with data into buffer within the
+// given limits. Execution also stops if ctx is cancelled, for example when
+// the client disconnects. As html/template cannot be interrupted, a template
+// that runs out of time is left to finish in the background, but every write
+// it makes after that fails, so it stops at its next output.
+func executeTemplate(ctx context.Context, t *template.Template, data interface{},
+	buffer *bytes.Buffer, limits templateLimits) error {
+
+	if limits.maxOutput <= 0 && limits.timeout <= 0 {
+		return t.Execute(buffer, data)
+	}
+
+	if limits.timeout > 0 {
+
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.timeout)
+		defer cancel()
+	}
+
+	// Execute into a separate buffer, so that a template still running in
+	// the background never writes to the caller's buffer
+	writer := &limitedWriter{ctx: ctx, limit: limits.maxOutput}
+	done := make(chan error, 1)
+
+	go func() {
+		done <- t.Execute(writer, data)
+	}()
+
+	select {
+
+	case err := <-done:
+
+		if writer.exceeded {
+			return fmt.Errorf("handlers: template %q exceeded its output limit of %d bytes",
+				t.Name(), limits.maxOutput)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		buffer.Write(writer.buffer.Bytes())
+		return nil
+
+	case <-ctx.Done():
+
+		return fmt.Errorf("handlers: template %q was stopped: %s", t.Name(), ctx.Err())
+	}
+}
+
+// limitedWriter buffers a template's output, failing once the output exceeds
+// limit bytes, if limit is greater than zero, or ctx is done.
+type limitedWriter struct {
+	ctx      context.Context
+	limit    int64
+	buffer   bytes.Buffer
+	exceeded bool
+}
+
+// Write adds p to the buffer, or fails without writing any of it if the
+// template has been stopped or p would take the output over the limit.
+func (w *limitedWriter) Write(p []byte) (int, error) {
+
+	if err := w.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	if w.limit > 0 && int64(w.buffer.Len()+len(p)) > w.limit {
+		w.exceeded = true
+		return 0, fmt.Errorf("handlers: output limit of %d bytes exceeded", w.limit)
+	}
+
+	return w.buffer.Write(p)
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test template execution limits
+func TestExecutionLimits(t *testing.T) {
+
+	var (
+		nfh      *NotFoundHandler
+		eh       *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	funcs := template.FuncMap{
+		"repeat": func(count int) []int { return make([]int, count) },
+		"wait":   func() string { <-release; return "" },
+	}
+
+	large := template.Must(template.New("large").Funcs(funcs).Parse(
+		`{{range repeat 10000}}Not found: {{$.Path}}{{end}}`))
+
+	slow := template.Must(template.New("slow").Funcs(funcs).Parse(
+		`Not found{{wait}}{{.Path}}`))
+
+	// Check a template within the limits is served
+	nfh = NewNotFoundHandler(large)
+	nfh.SetExecutionLimits(1<<20, time.Second)
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/missing", nil)
+	nfh.ServeHTTP(response, request)
+
+	if response.Code != http.StatusNotFound || !strings.HasPrefix(response.Body.String(), "Not found: /missing") {
+		t.Errorf("Expected the 404 page within the limits. Got: %d %.40q",
+			response.Code, response.Body.String())
+	}
+
+	// Check a template exceeding the output limit gets a 500
+	nfh.SetExecutionLimits(1024, 0)
+	response = httptest.NewRecorder()
+	nfh.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError || response.Body.Len() > 1024 {
+		t.Errorf("Expected a short 500 for too much output. Got: %d with %d bytes",
+			response.Code, response.Body.Len())
+	}
+
+	// Check a template exceeding the time limit gets a 500
+	nfh = NewNotFoundHandler(slow)
+	nfh.SetExecutionLimits(0, 10*time.Millisecond)
+	response = httptest.NewRecorder()
+	nfh.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected a 500 for a slow template. Got: %d", response.Code)
+	}
+
+	// Check the limits apply to the error handler
+	eh = NewErrorHandler(slow, WithDefaultMessage("Error"))
+	eh.SetExecutionLimits(0, 10*time.Millisecond)
+	response = httptest.NewRecorder()
+	eh.ServeError(response, "Error")
+
+	if response.Code != http.StatusInternalServerError || strings.Contains(response.Body.String(), "Not found") {
+		t.Errorf("Expected the built-in error for a slow template. Got: %q", response.Body.String())
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
+// SetLogger sets a structured logger to which the handler writes a record for
+// every error it serves, so that errors shown to users can also be found in
+// the server's logs without logging them at each call. Each record has the
+// error's message, even when a default message is shown instead, its status
+// and its error id, and the path, method and remote address of the request
+// when there is one. Errors with a 5xx status are logged at the error level
+// and others at the warning level. By default errors are not logged.
+func (h *ErrorHandler) SetLogger(logger *slog.Logger) {
+
+	h.logger = logger
+}
+
+// OnError registers a callback that is called whenever the handler serves an
+// error, for example to count errors, raise alerts or tag traces. Callbacks
+// are called in the order they were registered, before the response is
+// written, with the error's message, even when a default message is shown
+// instead, its status and its error id, so that a report can be matched to
+// the id shown to the user. The request is nil when the error is served by
+// ServeError, ServeErrorWithStatus or AlwaysServeError, which are not given
+// one, so handlers should use ServeErrorRequest where a request is available.
+// Callbacks must be safe to call from many goroutines at once, and should not
+// be registered while the handler is serving requests.
+func (h *ErrorHandler) OnError(callback func(r *http.Request, message string, status int, errorID string)) {
+
+	h.onError = append(h.onError, callback)
+}
+
+// reportError calls the handler's error callbacks and writes a record of a
+// served error to its logger, if it has one. The request may be nil, in which
+// case the record has no method, path or remote address.
+func (h *ErrorHandler) reportError(r *http.Request, message string, status int, errorID string) {
+
+	for _, callback := range h.onError {
+		callback(r, message, status, errorID)
+	}
+
+	if h.logger == nil {
+		return
+	}
+
+	ctx := context.Background()
+	level := slog.LevelWarn
+
+	if status >= 500 {
+		level = slog.LevelError
+	}
+
+	attrs := []slog.Attr{
+		slog.String("message", message),
+		slog.Int("status", status),
+		slog.String("error_id", errorID),
+	}
+
+	if r != nil {
+
+		ctx = r.Context()
+		attrs = append(attrs,
+			slog.String("path", r.URL.Path),
+			slog.String("method", r.Method),
+			slog.String("remote_addr", r.RemoteAddr))
+	}
+
+	h.logger.LogAttrs(ctx, level, "handlers: served error", attrs...)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test ErrorHandler logging
+func TestErrorHandlerLogger(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		output   bytes.Buffer
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(`{{.ErrorMessage}}`))
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Default error"))
+	h.SetLogger(slog.New(slog.NewJSONHandler(&output, nil)))
+	h.SetRequestIDHeader("X-Request-ID")
+
+	// Check a hidden message is logged but not shown
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, "Database unavailable", http.StatusServiceUnavailable)
+
+	if body := response.Body.String(); body != "Default error" {
+		t.Errorf("Expected the default message to be shown. Got: %q", body)
+	}
+
+	var record map[string]interface{}
+
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON log record. Got: %q", output.String())
+	}
+
+	if record["level"] != "ERROR" || record["message"] != "Database unavailable" ||
+		record["status"] != float64(503) || record["error_id"] == "" {
+
+		t.Errorf("Expected a record of the hidden error. Got: %v", record)
+	}
+
+	if _, ok := record["path"]; ok {
+		t.Errorf("Expected no request fields without a request. Got: %v", record)
+	}
+
+	// Check a request's fields are logged
+	output.Reset()
+	h.SetDefaultStatus(http.StatusTooManyRequests)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("POST", "/api/items", nil)
+	request.RemoteAddr = "192.0.2.1:1234"
+	request.Header.Set("X-Request-ID", "req-123")
+	h.ServeHTTP(response, request)
+
+	record = nil
+
+	if err := json.Unmarshal(output.Bytes(), &record); err != nil {
+		t.Fatalf("Expected a JSON log record. Got: %q", output.String())
+	}
+
+	expected := map[string]interface{}{
+		"level":       "WARN",
+		"message":     "Default error",
+		"status":      float64(429),
+		"error_id":    "req-123",
+		"path":        "/api/items",
+		"method":      "POST",
+		"remote_addr": "192.0.2.1:1234",
+	}
+
+	for key, value := range expected {
+
+		if record[key] != value {
+			t.Errorf("Expected %s %v in the record. Got: %v", key, value, record[key])
+		}
+	}
+}
+
+// Test ErrorHandler error callbacks
+func TestErrorHandlerOnError(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		calls    []string
+		ids      []string
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(`{{.ErrorID}}`))
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Default error"))
+	h.SetRequestIDHeader("X-Request-ID")
+
+	h.OnError(func(r *http.Request, message string, status int, errorID string) {
+
+		path := ""
+
+		if r != nil {
+			path = r.URL.Path
+		}
+
+		calls = append(calls, fmt.Sprintf("first %s %q %d", path, message, status))
+		ids = append(ids, errorID)
+	})
+
+	h.OnError(func(r *http.Request, message string, status int, errorID string) {
+
+		calls = append(calls, "second")
+	})
+
+	// Check the callbacks are called in order for each error
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, "Upstream timed out", http.StatusGatewayTimeout)
+	generated := response.Body.String()
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/broken", nil)
+	request.Header.Set("X-Request-ID", "req-1")
+	h.ServeHTTP(response, request)
+
+	expected := []string{
+		`first  "Upstream timed out" 504`,
+		"second",
+		`first /broken "Default error" 500`,
+		"second",
+	}
+
+	if strings.Join(calls, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Expected the callbacks:\n%s\nGot:\n%s",
+			strings.Join(expected, "\n"), strings.Join(calls, "\n"))
+	}
+
+	// Check the callbacks get the error id shown to the user, whether it was
+	// generated or taken from the request
+	if len(ids) != 2 || ids[0] != generated || ids[0] == "" || ids[1] != "req-1" {
+		t.Errorf("Expected the error ids %q and \"req-1\". Got: %q", generated, ids)
+	}
+}
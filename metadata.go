@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxStrippedImageCache is the maximum number of bytes of stripped images each
+// cache of a FileHandler keeps in memory.
+const maxStrippedImageCache int = 32 << 20
+
+// errMalformedImage is returned when an image cannot be parsed well enough to
+// remove its metadata.
+var errMalformedImage = errors.New("handlers: malformed image")
+
+// pngSignature is the signature at the start of every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the types of the PNG chunks removed by
+// stripPNGMetadata.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true,
+}
+
+// strippedImage is a cached image with its metadata removed.
+type strippedImage struct {
+	modTime  time.Time
+	size     int64
+	contents []byte
+}
+
+// strippedImageCache holds stripped images by path. Images are stripped by
+// the cache's strip function when they are first requested or have changed.
+type strippedImageCache struct {
+	strip  func(filePath string, contents []byte) ([]byte, error)
+	mutex  sync.Mutex
+	images map[string]strippedImage
+	bytes  int
+}
+
+// newStrippedImageCache returns a new cache of images stripped by strip.
+func newStrippedImageCache(strip func(filePath string, contents []byte) ([]byte, error)) *strippedImageCache {
+
+	return &strippedImageCache{strip: strip, images: make(map[string]strippedImage)}
+}
+
+// SetStripImageMetadata controls whether the handler removes metadata from
+// JPEG and PNG images as it serves them, so that photos uploaded by users do
+// not leak the location, camera and other details recorded in their EXIF,
+// XMP and IPTC data. Colour profiles are kept. As the orientation recorded in
+// EXIF data is removed too, images should be stored upright. Stripped images
+// are cached in memory until the file changes. Images the handler cannot parse
+// are answered with a 500 rather than served with their metadata. The AVIF
+// and WebP sidecars served by image negotiation cannot be stripped, so
+// turning stripping on while negotiation is on panics, rather than leaving
+// the images most browsers receive unstripped. By default images are served
+// unchanged.
+func (h *FileHandler) SetStripImageMetadata(strip bool) {
+
+	if !strip {
+		h.strippedImages = nil
+		return
+	}
+
+	refuseStrippedNegotiation(h.negotiateImages)
+	h.strippedImages = newStrippedImageCache(stripImageMetadata)
+}
+
+// refuseStrippedNegotiation panics if image negotiation and metadata
+// stripping would both be on.
+func refuseStrippedNegotiation(both bool) {
+
+	if both {
+		panic("handlers: image negotiation cannot be used with metadata stripping")
+	}
+}
+
+// serveStripped serves the image from the cache with its metadata or other
+// unwanted content removed.
+func (h *FileHandler) serveStripped(w http.ResponseWriter, r *http.Request, cache *strippedImageCache,
+	file io.Reader, filePath string, finfo os.FileInfo) {
+
+	contents, hit, err := cache.get(filePath, file, finfo)
+
+	if err != nil {
+		h.serveError(w, r, err)
+		return
+	}
+
+	if hit {
+		setDebugHeader(w, r, "X-Debug-Cache", "hit")
+	} else {
+		setDebugHeader(w, r, "X-Debug-Cache", "miss")
+	}
+
+	http.ServeContent(w, r, finfo.Name(), finfo.ModTime(), bytes.NewReader(contents))
+}
+
+// get returns the stripped contents of the image, from the cache if the file
+// has not changed since it was stripped, and whether they came from the cache.
+func (c *strippedImageCache) get(filePath string, file io.Reader, finfo os.FileInfo) ([]byte, bool, error) {
+
+	c.mutex.Lock()
+	cached, ok := c.images[filePath]
+	c.mutex.Unlock()
+
+	if ok && cached.size == finfo.Size() && cached.modTime.Equal(finfo.ModTime()) {
+		return cached.contents, true, nil
+	}
+
+	contents, err := io.ReadAll(file)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	contents, err = c.strip(filePath, contents)
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Start again when the cache is full, rather than tracking use
+	if c.bytes+len(contents) > maxStrippedImageCache {
+		c.images = make(map[string]strippedImage)
+		c.bytes = 0
+	}
+
+	if previous, ok := c.images[filePath]; ok {
+		c.bytes -= len(previous.contents)
+	}
+
+	c.images[filePath] = strippedImage{modTime: finfo.ModTime(), size: finfo.Size(), contents: contents}
+	c.bytes += len(contents)
+	return contents, false, nil
+}
+
+// stripImageMetadata returns the JPEG or PNG image at filePath without its
+// metadata.
+func stripImageMetadata(filePath string, contents []byte) ([]byte, error) {
+
+	if isPNG(filePath) {
+		return stripPNGMetadata(contents)
+	}
+
+	return stripJPEGMetadata(contents)
+}
+
+// isStrippableImage reports whether the named file is a JPEG or PNG image.
+func isStrippableImage(name string) bool {
+
+	extension := strings.ToLower(path.Ext(name))
+	return extension == ".jpg" || extension == ".jpeg" || isPNG(name)
+}
+
+// isPNG reports whether the named file is a PNG image.
+func isPNG(name string) bool {
+
+	return strings.ToLower(path.Ext(name)) == ".png"
+}
+
+// stripJPEGMetadata returns the JPEG image without its APP1 (EXIF and XMP),
+// APP13 (IPTC) and comment segments, and the other application segments that
+// are not needed to display it. The JFIF, ICC profile and Adobe segments are
+// kept. Everything from the start of the scan is copied unchanged.
+func stripJPEGMetadata(image []byte) ([]byte, error) {
+
+	if len(image) < 4 || image[0] != 0xFF || image[1] != 0xD8 {
+		return nil, errMalformedImage
+	}
+
+	stripped := make([]byte, 0, len(image))
+	stripped = append(stripped, image[:2]...)
+	position := 2
+
+	for {
+
+		if position+2 > len(image) || image[position] != 0xFF {
+			return nil, errMalformedImage
+		}
+
+		// Markers may be preceded by any number of fill bytes
+		start := position
+
+		for position < len(image) && image[position] == 0xFF {
+			position++
+		}
+
+		if position >= len(image) {
+			return nil, errMalformedImage
+		}
+
+		marker := image[position]
+		position++
+
+		switch {
+
+		// The start of scan is followed by the compressed image data
+		case marker == 0xDA:
+			return append(stripped, image[start:]...), nil
+
+		// End of image
+		case marker == 0xD9:
+			return append(stripped, image[start:position]...), nil
+
+		// Markers without a segment
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7):
+			stripped = append(stripped, image[start:position]...)
+			continue
+		}
+
+		if position+2 > len(image) {
+			return nil, errMalformedImage
+		}
+
+		length := int(binary.BigEndian.Uint16(image[position:]))
+
+		if length < 2 || position+length > len(image) {
+			return nil, errMalformedImage
+		}
+
+		end := position + length
+		position = end
+
+		// Keep APP0 (JFIF), APP2 (ICC profile) and APP14 (Adobe) segments and
+		// every non-application segment, but not comments
+		application := marker >= 0xE0 && marker <= 0xEF
+
+		if marker == 0xFE || (application && marker != 0xE0 && marker != 0xE2 && marker != 0xEE) {
+			continue
+		}
+
+		stripped = append(stripped, image[start:end]...)
+	}
+}
+
+// stripPNGMetadata returns the PNG image without its EXIF, text and time
+// chunks.
+func stripPNGMetadata(image []byte) ([]byte, error) {
+
+	if !bytes.HasPrefix(image, pngSignature) {
+		return nil, errMalformedImage
+	}
+
+	stripped := make([]byte, 0, len(image))
+	stripped = append(stripped, pngSignature...)
+	position := len(pngSignature)
+
+	for position < len(image) {
+
+		// Each chunk is a length, a type, the data and a checksum
+		if position+8 > len(image) {
+			return nil, errMalformedImage
+		}
+
+		length := int64(binary.BigEndian.Uint32(image[position:]))
+		chunkType := string(image[position+4 : position+8])
+		end := int64(position) + 12 + length
+
+		if end > int64(len(image)) {
+			return nil, errMalformedImage
+		}
+
+		if !pngMetadataChunks[chunkType] {
+			stripped = append(stripped, image[position:end]...)
+		}
+
+		position = int(end)
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	return stripped, nil
+}
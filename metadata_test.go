@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler image metadata stripping
+func TestStripImageMetadata(t *testing.T) {
+
+	const location string = "GPSLatitude=51.5007"
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		encoded  bytes.Buffer
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	picture := image.NewRGBA(image.Rect(0, 0, 8, 8))
+
+	// Build a JPEG with EXIF, XMP and comment segments after the SOI marker
+	jpeg.Encode(&encoded, picture, nil)
+	plainJPEG := encoded.Bytes()
+
+	segment := func(marker byte, data string) []byte {
+
+		header := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(len(data)+2))
+		return append(header, data...)
+	}
+
+	photoJPEG := append([]byte{}, plainJPEG[:2]...)
+	photoJPEG = append(photoJPEG, segment(0xE1, "Exif\x00\x00"+location)...)
+	photoJPEG = append(photoJPEG, segment(0xE1, "http://ns.adobe.com/xap/1.0/\x00"+location)...)
+	photoJPEG = append(photoJPEG, segment(0xFE, location)...)
+	photoJPEG = append(photoJPEG, plainJPEG[2:]...)
+
+	// Build a PNG with a text chunk after the IHDR chunk
+	encoded = bytes.Buffer{}
+	png.Encode(&encoded, picture)
+	plainPNG := encoded.Bytes()
+
+	chunk := []byte{0, 0, 0, 0}
+	binary.BigEndian.PutUint32(chunk, uint32(len("Comment\x00"+location)))
+	chunk = append(chunk, "tEXtComment\x00"+location...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+
+	ihdrEnd := len(pngSignature) + 25
+	photoPNG := append([]byte{}, plainPNG[:ihdrEnd]...)
+	photoPNG = append(photoPNG, chunk...)
+	photoPNG = append(photoPNG, plainPNG[ihdrEnd:]...)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"photo.jpg":  {Data: photoJPEG},
+		"photo.png":  {Data: photoPNG},
+		"broken.jpg": {Data: []byte("not a jpeg " + location)},
+		"notes.txt":  {Data: []byte(location)},
+	}), nfh)
+	h.SetStripImageMetadata(true)
+
+	tests := []struct {
+		path     string
+		status   int
+		stripped bool
+	}{
+		{"/photo.jpg", http.StatusOK, true},
+		{"/photo.jpg", http.StatusOK, true},
+		{"/photo.png", http.StatusOK, true},
+		{"/broken.jpg", http.StatusInternalServerError, true},
+		{"/notes.txt", http.StatusOK, false},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the image
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the metadata is removed
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+
+		if contains := bytes.Contains(response.Body.Bytes(), []byte(location)); contains == test.stripped {
+			t.Errorf("Expected the location to be removed from %s: %t", test.path, test.stripped)
+		}
+	}
+
+	// Check the stripped images are still valid
+	if body := serveBody(h, "/photo.jpg"); !bytes.Equal(body, plainJPEG) {
+		t.Errorf("Expected the stripped JPEG to match the original encoding")
+	}
+
+	if _, err := png.Decode(bytes.NewReader(serveBody(h, "/photo.png"))); err != nil {
+		t.Errorf("Expected the stripped PNG to decode. Got: %s", err)
+	}
+
+	// Check unstripped sidecars cannot be negotiated in either order
+	for name, configure := range map[string]func(h *FileHandler){
+		"SetImageNegotiation": func(h *FileHandler) {
+			h.SetStripImageMetadata(true)
+			h.SetImageNegotiation(true)
+		},
+		"SetStripImageMetadata": func(h *FileHandler) {
+			h.SetImageNegotiation(true)
+			h.SetStripImageMetadata(true)
+		},
+	} {
+
+		func() {
+
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Expected %s to panic with both options on", name)
+				}
+			}()
+
+			configure(NewFileSystemHandler("/", http.FS(fstest.MapFS{}), nfh))
+		}()
+	}
+}
+
+// serveBody returns the body of the handler's response to a GET request for
+// the path.
+func serveBody(h http.Handler, path string) []byte {
+
+	response := httptest.NewRecorder()
+	request, _ := http.NewRequest("GET", path, nil)
+	h.ServeHTTP(response, request)
+	return response.Body.Bytes()
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// mountAttrPattern matches an HTML attribute holding a url and captures the
+// text before the url and the url itself.
+var mountAttrPattern = regexp.MustCompile(
+	`(?i)(\s(?:href|src|action|formaction|poster|data|xlink:href)\s*=\s*["']?)(/[^"'\s>]*)`)
+
+// mountSrcsetPattern matches an HTML srcset attribute and captures the text
+// before its value and the value itself.
+var mountSrcsetPattern = regexp.MustCompile(`(?i)(\ssrcset\s*=\s*["'])([^"']*)`)
+
+// mountCSSPattern matches a CSS url() reference or @import and captures the
+// text before the url and the url itself.
+var mountCSSPattern = regexp.MustCompile(`(?i)((?:url\(\s*["']?)|(?:@import\s+["']))(/[^"')\s]*)`)
+
+// SetMountPath sets the path, such as "/app/", at which clients see a site
+// that was built to be served from "/", so that the site can be mounted under
+// a subpath without being rebuilt. Root-relative urls in the href, src,
+// srcset, action, formaction, poster and data attributes of HTML pages, and
+// in url() references and @import rules in pages and CSS files, are given
+// the mount path as a prefix. Protocol-relative urls such as
+// "//example.com/" and relative urls are left unchanged, as they already
+// resolve correctly. The mount path is usually the handler's own path, unless
+// a prefix is stripped before the request reaches the handler. An empty path
+// or "/" turns rewriting off, which is the default.
+func (h *FileHandler) SetMountPath(mountPath string) {
+
+	prefix := strings.TrimSuffix(mountPath, "/")
+
+	if prefix == "" {
+		h.mountPrefix = ""
+		return
+	}
+
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+
+	if h.mountPrefix == "" {
+
+		h.addTransform(func(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+
+			if h.mountPrefix == "" {
+				return page
+			}
+
+			return mountHTML(page, h.mountPrefix)
+		})
+	}
+
+	h.mountPrefix = prefix
+}
+
+// serveMountedCSS serves the CSS file with its root-relative urls rewritten
+// for the handler's mount path. The result only depends on the file, so it is
+// served with the file's modification time.
+func (h *FileHandler) serveMountedCSS(w http.ResponseWriter, r *http.Request, file io.Reader,
+	finfo os.FileInfo) {
+
+	contents, err := io.ReadAll(file)
+
+	if err != nil {
+		h.serveError(w, r, err)
+		return
+	}
+
+	contents = mountCSS(contents, h.mountPrefix)
+	http.ServeContent(w, r, finfo.Name(), finfo.ModTime(), bytes.NewReader(contents))
+}
+
+// mountHTML returns the page with its root-relative urls given the prefix.
+func mountHTML(page []byte, prefix string) []byte {
+
+	page = mountAttrPattern.ReplaceAllFunc(page, func(match []byte) []byte {
+
+		parts := mountAttrPattern.FindSubmatch(match)
+		return append(append([]byte{}, parts[1]...), mountURL(string(parts[2]), prefix)...)
+	})
+
+	page = mountSrcsetPattern.ReplaceAllFunc(page, func(match []byte) []byte {
+
+		parts := mountSrcsetPattern.FindSubmatch(match)
+		candidates := strings.Split(string(parts[2]), ",")
+
+		for i, candidate := range candidates {
+
+			trimmed := strings.TrimLeft(candidate, " \t\n\r")
+			leading := candidate[:len(candidate)-len(trimmed)]
+			candidates[i] = leading + mountURL(trimmed, prefix)
+		}
+
+		return append(append([]byte{}, parts[1]...), strings.Join(candidates, ",")...)
+	})
+
+	return mountCSS(page, prefix)
+}
+
+// mountCSS returns the style sheet with its root-relative urls given the
+// prefix.
+func mountCSS(css []byte, prefix string) []byte {
+
+	return mountCSSPattern.ReplaceAllFunc(css, func(match []byte) []byte {
+
+		parts := mountCSSPattern.FindSubmatch(match)
+		return append(append([]byte{}, parts[1]...), mountURL(string(parts[2]), prefix)...)
+	})
+}
+
+// mountURL returns the url with the prefix if it is root-relative, and
+// unchanged otherwise.
+func mountURL(url string, prefix string) string {
+
+	if !strings.HasPrefix(url, "/") || strings.HasPrefix(url, "//") {
+		return url
+	}
+
+	return prefix + url
+}
+
+// isCSS reports whether the named file is a CSS style sheet.
+func isCSS(name string) bool {
+
+	return strings.ToLower(path.Ext(name)) == ".css"
+}
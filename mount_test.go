@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler mount paths
+func TestMountPath(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	page := `<html><head><link rel="stylesheet" href="/css/site.css">` +
+		`<script src='/js/app.js'></script><style>@import "/css/print.css";</style></head>` +
+		`<body style="background: url(/img/bg.png)"><a href=/about/>About</a>` +
+		`<a href="//cdn.example.com/x">CDN</a><a href="https://example.com/">Out</a>` +
+		`<a href="docs/">Docs</a><img srcset="/img/a.png 1x, /img/b.png 2x" src="/img/a.png">` +
+		`<form action="/search"></form></body></html>`
+
+	css := `@import '/css/base.css'; body { background: url("/img/bg.png") } .a { background: url(img/a.png) }`
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/app/", http.FS(fstest.MapFS{
+		"index.html":   {Data: []byte(page)},
+		"css/site.css": {Data: []byte(css)},
+	}), nfh)
+
+	// Check urls are unchanged by default
+	if body := string(serveBody(h, "/app/")); body != page {
+		t.Errorf("Expected the page unchanged by default. Got: %s", body)
+	}
+
+	h.SetMountPath("/app/")
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{
+			"/app/",
+			`<html><head><link rel="stylesheet" href="/app/css/site.css">` +
+				`<script src='/app/js/app.js'></script><style>@import "/app/css/print.css";</style></head>` +
+				`<body style="background: url(/app/img/bg.png)"><a href=/app/about/>About</a>` +
+				`<a href="//cdn.example.com/x">CDN</a><a href="https://example.com/">Out</a>` +
+				`<a href="docs/">Docs</a><img srcset="/app/img/a.png 1x, /app/img/b.png 2x" src="/app/img/a.png">` +
+				`<form action="/app/search"></form></body></html>`,
+		},
+		{
+			"/app/css/site.css",
+			`@import '/app/css/base.css'; body { background: url("/app/img/bg.png") } .a { background: url(img/a.png) }`,
+		},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the file
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check root-relative urls are given the mount path
+		if response.Body.String() != test.body {
+			t.Errorf("Expected for %s:\n%s\nGot:\n%s", test.path, test.body, response.Body.String())
+		}
+	}
+
+	// Check an empty mount path turns rewriting off
+	h.SetMountPath("")
+
+	if body := string(serveBody(h, "/app/css/site.css")); body != css {
+		t.Errorf("Expected the style sheet unchanged with rewriting off. Got: %s", body)
+	}
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// acceptQuality returns the quality the request's Accept header gives the
+// media type, or -1 if the header does not cover it, and how specifically it
+// is named, from 2 for an exact entry down to 0 for */*. If wildcards is true,
+// ranges such as text/* and */* cover the types they match, with an exact
+// entry taking precedence over a range and a narrower range over a wider one.
+func acceptQuality(r *http.Request, mediaType string, wildcards bool) (float64, int) {
+
+	var (
+		quality     float64 = -1
+		specificity int     = -1
+	)
+
+	mainType, _, _ := strings.Cut(mediaType, "/")
+
+	for _, value := range r.Header.Values("Accept") {
+
+		for _, entry := range strings.Split(value, ",") {
+
+			parameters := strings.Split(entry, ";")
+			accepted := strings.ToLower(strings.TrimSpace(parameters[0]))
+			matched := -1
+
+			switch {
+
+			case accepted == strings.ToLower(mediaType):
+				matched = 2
+
+			case wildcards && accepted == mainType+"/*":
+				matched = 1
+
+			case wildcards && accepted == "*/*":
+				matched = 0
+			}
+
+			if matched <= specificity {
+				continue
+			}
+
+			specificity = matched
+			quality = 1
+
+			for _, parameter := range parameters[1:] {
+
+				name, value, _ := strings.Cut(strings.TrimSpace(parameter), "=")
+
+				if strings.EqualFold(name, "q") {
+					quality, _ = strconv.ParseFloat(value, 64)
+				}
+			}
+		}
+	}
+
+	return quality, specificity
+}
+
+// acceptsMediaType reports whether the request's Accept header names the
+// media type with a quality above zero. Wildcards are not counted, as clients
+// send image/* whatever formats they support.
+func acceptsMediaType(r *http.Request, mediaType string) bool {
+
+	quality, _ := acceptQuality(r, mediaType, false)
+	return quality > 0
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// DefaultNoncePolicy is the Content-Security-Policy used by SetNonces when it
+// is given an empty policy. It is a strict policy that only runs scripts with
+// the page's nonce, and the scripts they load, and forbids plugins and base
+// elements.
+const DefaultNoncePolicy string = "script-src 'nonce-{nonce}' 'strict-dynamic'; " +
+	"object-src 'none'; base-uri 'none'"
+
+// nonceElementPattern matches the opening tag of a script or style element
+// and captures its attributes.
+var nonceElementPattern = regexp.MustCompile(`(?is)<(?:script|style)\b([^>]*)>`)
+
+// nonceAttrPattern matches a nonce attribute.
+var nonceAttrPattern = regexp.MustCompile(`(?i)(?:^|\s)nonce\s*=`)
+
+// SetNonces makes the handler add a nonce to the script and style elements of
+// the HTML pages it serves, and send a Content-Security-Policy that allows
+// them, so that a strict policy can be deployed on a site whose pages have
+// some inline code. A new nonce is generated for every response, and each
+// "{nonce}" in the policy is replaced with it. If the policy is empty
+// DefaultNoncePolicy is used. Elements that already have a nonce keep it.
+// The policy is sent in addition to any other policy, such as the sandbox
+// policy of untrusted content. Pages served this way are not cached by shared
+// caches, as their nonces must not be reused.
+func (h *FileHandler) SetNonces(policy string) {
+
+	if policy == "" {
+		policy = DefaultNoncePolicy
+	}
+
+	h.addTransform(func(w http.ResponseWriter, r *http.Request, page []byte) []byte {
+
+		nonce, err := newNonce()
+
+		// Without a nonce the page's inline code cannot be allowed, so leave
+		// the page to be served without a policy rather than broken
+		if err != nil {
+			return page
+		}
+
+		// Add the policy alongside any other, such as the sandbox policy of
+		// untrusted content, as browsers enforce every policy they are sent
+		w.Header().Add("Content-Security-Policy", strings.ReplaceAll(policy, "{nonce}", nonce))
+
+		return nonceElementPattern.ReplaceAllFunc(page, func(element []byte) []byte {
+
+			attrs := nonceElementPattern.FindSubmatchIndex(element)[2]
+
+			if nonceAttrPattern.Match(element[attrs:]) {
+				return element
+			}
+
+			result := make([]byte, 0, len(element)+len(nonce)+9)
+			result = append(result, element[:attrs]...)
+			result = append(result, ` nonce="`+nonce+`"`...)
+			return append(result, element[attrs:]...)
+		})
+	})
+}
+
+// newNonce returns a new random nonce for a Content-Security-Policy.
+func newNonce() (string, error) {
+
+	nonce := make([]byte, 16)
+
+	if _, err := io.ReadFull(cryptoRandom, nonce); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(nonce), nil
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler CSP nonces
+func TestNonces(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	page := `<html><head><style>p { color: red }</style>` +
+		`<script>init()</script><SCRIPT src="/app.js"></SCRIPT>` +
+		`<script nonce="fixed">kept()</script></head><body><p>Page</p></body></html>`
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.html": {Data: []byte(page)},
+	}), nfh)
+
+	h.SetNonces("")
+
+	noncePattern := regexp.MustCompile(`'nonce-([^']+)'`)
+	var previous string
+
+	for i := 0; i < 2; i++ {
+
+		// Test ServeHTTP on the page
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+		h.ServeHTTP(response, request)
+
+		// Check the policy carries a nonce
+		policy := response.Header().Get("Content-Security-Policy")
+		match := noncePattern.FindStringSubmatch(policy)
+
+		if match == nil || !strings.HasSuffix(policy, "object-src 'none'; base-uri 'none'") {
+			t.Fatalf("Expected the default policy with a nonce. Got: %q", policy)
+		}
+
+		nonce := match[1]
+
+		// Check the elements without a nonce are given the policy's nonce
+		expected := `<html><head><style nonce="` + nonce + `">p { color: red }</style>` +
+			`<script nonce="` + nonce + `">init()</script><SCRIPT nonce="` + nonce + `" src="/app.js"></SCRIPT>` +
+			`<script nonce="fixed">kept()</script></head><body><p>Page</p></body></html>`
+
+		if body := response.Body.String(); body != expected {
+			t.Errorf("Expected:\n%s\nGot:\n%s", expected, body)
+		}
+
+		// Check each response has a new nonce
+		if nonce == previous {
+			t.Errorf("Expected a new nonce for each response. Got: %s twice", nonce)
+		}
+
+		previous = nonce
+	}
+
+	// Check a given policy is used
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.html": {Data: []byte(page)},
+	}), nfh)
+
+	h.SetNonces("default-src 'self'; script-src 'nonce-{nonce}'")
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	if policy := response.Header().Get("Content-Security-Policy"); !strings.HasPrefix(policy,
+		"default-src 'self'; script-src 'nonce-") || strings.Contains(policy, "{nonce}") {
+
+		t.Errorf("Expected the given policy with a nonce. Got: %q", policy)
+	}
+
+	// Check the nonce policy does not replace the sandbox of untrusted content
+	h = NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"index.html": {Data: []byte(page)},
+	}), nfh)
+
+	h.SetUntrustedContent(true, true)
+	h.SetNonces("")
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/", nil)
+	h.ServeHTTP(response, request)
+
+	policies := response.Header().Values("Content-Security-Policy")
+
+	if len(policies) != 2 || policies[0] != SandboxPolicy ||
+		!strings.HasPrefix(policies[1], "script-src 'nonce-") {
+
+		t.Errorf("Expected the sandbox and nonce policies. Got: %q", policies)
+	}
+}
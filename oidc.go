@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// The names of the cookies used by OIDCHandler.
+const (
+	OIDCSessionCookieName string = "handlers_oidc_session"
+	oidcStateCookieName   string = "handlers_oidc_state"
+)
+
+// oidcLoginTimeout is how long a user has to complete a login at the provider.
+const oidcLoginTimeout time.Duration = 10 * time.Minute
+
+// OIDCConfig configures an OIDCHandler. Issuer is the url of the OpenID
+// provider, from which its endpoints are discovered. ClientID and
+// ClientSecret identify the application to the provider, and RedirectURL is
+// the callback url registered with it, which must be handled by the
+// OIDCHandler. Scopes are requested in addition to "openid". SessionKey signs
+// the session cookie and should be at least 32 random bytes. Prefixes are the
+// url paths that require a login, matched as SetPathMatching describes; if
+// none are given every path does. SessionDuration is how long a login lasts, an hour if it is zero.
+type OIDCConfig struct {
+	Issuer          string
+	ClientID        string
+	ClientSecret    string
+	RedirectURL     string
+	Scopes          []string
+	SessionKey      []byte
+	Prefixes        []string
+	SessionDuration time.Duration
+}
+
+// OIDCHandler gates another handler, typically a FileHandler, behind single
+// sign-on with an OpenID Connect provider. Unauthenticated requests for a
+// protected path are redirected to the provider with the authorization code
+// flow and PKCE. When the provider redirects back, the handler exchanges the
+// code for an ID token, verifies it against the provider's published keys,
+// and keeps the token's claims in a signed session cookie. The claims are
+// placed in the request context, where they can be read with OIDCClaims.
+type OIDCHandler struct {
+	config        OIDCConfig
+	next          http.Handler
+	client        *http.Client
+	clock         Clock
+	random        io.Reader
+	authEndpoint  string
+	tokenEndpoint string
+	keys          *jwksCache
+	callbackPath  string
+	stateKey      []byte
+	sessionKey    []byte
+	files         *FileHandler
+}
+
+// NewOIDCHandler returns a new OIDCHandler protecting next. It fetches the
+// provider's discovery document, so it returns an error if the provider
+// cannot be reached or the configuration is incomplete.
+func NewOIDCHandler(config OIDCConfig, next http.Handler) (*OIDCHandler, error) {
+
+	if config.Issuer == "" || config.ClientID == "" || config.RedirectURL == "" {
+		return nil, errors.New("handlers: OIDC issuer, client id and redirect url are required")
+	}
+
+	if len(config.SessionKey) < 32 {
+		return nil, errors.New("handlers: OIDC session key must be at least 32 bytes")
+	}
+
+	redirectURL, err := url.Parse(config.RedirectURL)
+
+	if err != nil {
+		return nil, fmt.Errorf("handlers: invalid OIDC redirect url: %s", err)
+	}
+
+	if config.SessionDuration <= 0 {
+		config.SessionDuration = time.Hour
+	}
+
+	h := &OIDCHandler{
+		config:       config,
+		next:         next,
+		client:       &http.Client{Timeout: 30 * time.Second},
+		clock:        SystemClock,
+		random:       cryptoRandom,
+		callbackPath: redirectURL.Path,
+		stateKey:     deriveKey(config.SessionKey, "state"),
+		sessionKey:   deriveKey(config.SessionKey, "session"),
+	}
+
+	h.files, _ = next.(*FileHandler)
+
+	var discovery struct {
+		Issuer                string `json:"issuer"`
+		AuthorizationEndpoint string `json:"authorization_endpoint"`
+		TokenEndpoint         string `json:"token_endpoint"`
+		JWKSURI               string `json:"jwks_uri"`
+	}
+
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+
+	if err := fetchJSON(h.client, discoveryURL, &discovery); err != nil {
+		return nil, err
+	}
+
+	if discovery.Issuer != config.Issuer {
+		return nil, fmt.Errorf("handlers: OIDC provider reports issuer %q", discovery.Issuer)
+	}
+
+	h.authEndpoint = discovery.AuthorizationEndpoint
+	h.tokenEndpoint = discovery.TokenEndpoint
+	h.keys = newJWKSCache(discovery.JWKSURI, h.client, h.clock)
+
+	return h, nil
+}
+
+// SetClock sets the clock used to check tokens and sessions. By default the
+// handler uses SystemClock.
+func (h *OIDCHandler) SetClock(clock Clock) {
+
+	h.clock = clock
+
+	h.keys.mutex.Lock()
+	h.keys.clock = clock
+	h.keys.mutex.Unlock()
+}
+
+// SetPathMatching sets the FileHandler whose case sensitivity and path
+// normalization are used to match request paths against the protected
+// prefixes, so that a path the FileHandler would serve as a protected file
+// always requires a login, however it is spelt. It is needed when other
+// handlers sit between the OIDCHandler and the FileHandler; when next is a
+// FileHandler it is used by default. Without one, case is ignored but paths
+// are not normalized.
+func (h *OIDCHandler) SetPathMatching(files *FileHandler) {
+
+	h.files = files
+}
+
+// ServeHTTP handles the provider's callback, and calls the protected handler
+// for signed-in users and for paths that are not protected.
+func (h *OIDCHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.URL.Path == h.callbackPath {
+		h.serveCallback(w, r)
+		return
+	}
+
+	if !h.protected(r.URL.Path) {
+		h.next.ServeHTTP(w, r)
+		return
+	}
+
+	if claims, ok := h.session(r); ok {
+		ctx := context.WithValue(r.Context(), claimsContextKey{}, claims)
+		h.next.ServeHTTP(w, r.WithContext(ctx))
+		return
+	}
+
+	h.startLogin(w, r)
+}
+
+// OIDCClaims returns the claims of the ID token of the user signed in with an
+// OIDCHandler, or nil if the request has not passed through one. It is
+// equivalent to RequestClaims.
+func OIDCClaims(r *http.Request) map[string]interface{} {
+
+	return RequestClaims(r)
+}
+
+// oidcState is the state of a login in progress, kept in a signed cookie
+// while the user is at the provider.
+type oidcState struct {
+	State    string `json:"s"`
+	Nonce    string `json:"n"`
+	Verifier string `json:"v"`
+	Return   string `json:"r"`
+}
+
+// startLogin redirects the user to the provider to sign in.
+func (h *OIDCHandler) startLogin(w http.ResponseWriter, r *http.Request) {
+
+	values := make([]string, 4)
+
+	for i := range values {
+
+		value, err := h.randomString()
+
+		// Predictable state or a predictable verifier would let an attacker
+		// complete the login, so refuse to start one
+		if err != nil {
+			setServerHeader(w)
+			serveInternalError(w, err)
+			return
+		}
+
+		values[i] = value
+	}
+
+	state := oidcState{
+		State:    values[0],
+		Nonce:    values[1],
+		Verifier: values[2] + values[3],
+		Return:   r.URL.RequestURI(),
+	}
+
+	encoded, _ := json.Marshal(state)
+	expires := h.clock.Now().Add(oidcLoginTimeout)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    IssueToken(h.stateKey, string(encoded), expires),
+		Path:     h.callbackPath,
+		MaxAge:   int(oidcLoginTimeout / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {h.config.ClientID},
+		"redirect_uri":          {h.config.RedirectURL},
+		"scope":                 {strings.Join(append([]string{"openid"}, h.config.Scopes...), " ")},
+		"state":                 {state.State},
+		"nonce":                 {state.Nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+
+	if strings.Contains(h.authEndpoint, "?") {
+		separator = "&"
+	}
+
+	setServerHeader(w)
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, h.authEndpoint+separator+query.Encode(), http.StatusFound)
+}
+
+// serveCallback completes a login when the provider redirects back.
+func (h *OIDCHandler) serveCallback(w http.ResponseWriter, r *http.Request) {
+
+	setServerHeader(w)
+
+	var state oidcState
+	cookie, err := r.Cookie(oidcStateCookieName)
+
+	if err == nil {
+
+		encoded, ok := verifyToken(h.stateKey, cookie.Value, h.clock.Now())
+
+		if !ok || json.Unmarshal([]byte(encoded), &state) != nil {
+			err = errors.New("invalid state")
+		}
+	}
+
+	query := r.URL.Query()
+
+	if err != nil || query.Get("state") != state.State || query.Get("code") == "" {
+		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		return
+	}
+
+	claims, err := h.exchange(query.Get("code"), state)
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	encoded, _ := json.Marshal(claims)
+	expires := h.clock.Now().Add(h.config.SessionDuration)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcStateCookieName,
+		Path:     h.callbackPath,
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDCSessionCookieName,
+		Value:    IssueToken(h.sessionKey, string(encoded), expires),
+		Path:     "/",
+		MaxAge:   int(h.config.SessionDuration / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	// Only return to a path on this site
+	location := state.Return
+
+	if !strings.HasPrefix(location, "/") || strings.HasPrefix(location, "//") {
+		location = "/"
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	http.Redirect(w, r, location, http.StatusFound)
+}
+
+// exchange exchanges an authorization code for an ID token and returns the
+// token's verified claims.
+func (h *OIDCHandler) exchange(code string, state oidcState) (map[string]interface{}, error) {
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {h.config.RedirectURL},
+		"client_id":     {h.config.ClientID},
+		"code_verifier": {state.Verifier},
+	}
+
+	request, err := http.NewRequest("POST", h.tokenEndpoint, strings.NewReader(form.Encode()))
+
+	if err != nil {
+		return nil, err
+	}
+
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if h.config.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(h.config.ClientID), url.QueryEscape(h.config.ClientSecret))
+	}
+
+	response, err := h.client.Do(request)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer response.Body.Close()
+
+	var tokens struct {
+		IDToken string `json:"id_token"`
+	}
+
+	err = json.NewDecoder(io.LimitReader(response.Body, maxFetchedJSON)).Decode(&tokens)
+
+	if err != nil || response.StatusCode != http.StatusOK || tokens.IDToken == "" {
+		return nil, fmt.Errorf("handlers: OIDC token exchange failed: %s", response.Status)
+	}
+
+	claims, err := parseJWT(tokens.IDToken, h.keys.key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkJWTClaims(claims, h.config.Issuer, h.config.ClientID, h.clock.Now())
+
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce, _ := claims["nonce"].(string); nonce != state.Nonce {
+		return nil, errors.New("handlers: OIDC nonce does not match")
+	}
+
+	return claims, nil
+}
+
+// session returns the claims in the request's session cookie, if it has a
+// valid one. The claims must name the provider and the user, as the claims of
+// every verified ID token do.
+func (h *OIDCHandler) session(r *http.Request) (map[string]interface{}, bool) {
+
+	cookie, err := r.Cookie(OIDCSessionCookieName)
+
+	if err != nil {
+		return nil, false
+	}
+
+	encoded, ok := verifyToken(h.sessionKey, cookie.Value, h.clock.Now())
+
+	if !ok {
+		return nil, false
+	}
+
+	var claims map[string]interface{}
+
+	if err := json.Unmarshal([]byte(encoded), &claims); err != nil {
+		return nil, false
+	}
+
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+
+	if issuer != h.config.Issuer || subject == "" {
+		return nil, false
+	}
+
+	return claims, true
+}
+
+// protected reports whether the path requires a login.
+func (h *OIDCHandler) protected(urlPath string) bool {
+
+	if len(h.config.Prefixes) == 0 {
+		return true
+	}
+
+	for _, prefix := range h.config.Prefixes {
+
+		if hasPathPrefix(urlPath, prefix, h.files) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// deriveKey returns a key for one purpose derived from the given key, so
+// that values signed for one purpose cannot be used for another.
+func deriveKey(key []byte, purpose string) []byte {
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("handlers: " + purpose))
+	return mac.Sum(nil)
+}
+
+// randomString returns a random url-safe string with 128 bits of entropy.
+func (h *OIDCHandler) randomString() (string, error) {
+
+	value := make([]byte, 16)
+
+	if _, err := io.ReadFull(h.random, value); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
@@ -0,0 +1,319 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"testing/iotest"
+	"time"
+)
+
+// testProvider is a minimal OpenID provider for tests, which issues RS256
+// tokens for any authorization code it has been given a challenge for.
+type testProvider struct {
+	server     *httptest.Server
+	key        *rsa.PrivateKey
+	challenges map[string]string
+	nonces     map[string]string
+	clock      Clock
+}
+
+// newTestProvider starts a new testProvider.
+func newTestProvider(t *testing.T, clock Clock) *testProvider {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+
+	if err != nil {
+		t.Fatalf("Failed to generate a key: %s", err)
+	}
+
+	p := &testProvider{
+		key:        key,
+		challenges: make(map[string]string),
+		nonces:     make(map[string]string),
+		clock:      clock,
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.server.URL,
+			"authorization_endpoint": p.server.URL + "/authorize",
+			"token_endpoint":         p.server.URL + "/token",
+			"jwks_uri":               p.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{testJWK(key, "test")},
+		})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+
+		code := r.PostFormValue("code")
+		verifier := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+
+		if p.challenges[code] != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+			http.Error(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token": signTestJWT(p.key, "test", map[string]interface{}{
+				"iss":   p.server.URL,
+				"aud":   "client",
+				"sub":   "alice",
+				"exp":   p.clock.Now().Add(time.Hour).Unix(),
+				"nonce": p.nonces[code],
+			}),
+		})
+	})
+
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+
+	return p
+}
+
+// authorize records the challenge and nonce of an authorization request and
+// returns a code for them, as the provider would after the user signs in.
+func (p *testProvider) authorize(query url.Values) string {
+
+	code := fmt.Sprintf("code%d", len(p.challenges))
+	p.challenges[code] = query.Get("code_challenge")
+	p.nonces[code] = query.Get("nonce")
+	return code
+}
+
+// Test the OIDCHandler login flow
+func TestOIDCHandler(t *testing.T) {
+
+	var (
+		h        *OIDCHandler
+		clock    *FixedClock
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	clock = NewFixedClock(time.Now())
+	provider := newTestProvider(t, clock)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		subject, _ := OIDCClaims(r)["sub"].(string)
+		fmt.Fprintf(w, "hello %s", subject)
+	})
+
+	h, err := NewOIDCHandler(OIDCConfig{
+		Issuer:      provider.server.URL,
+		ClientID:    "client",
+		RedirectURL: "https://example.com/auth/callback",
+		SessionKey:  []byte("0123456789abcdef0123456789abcdef"),
+		Prefixes:    []string{"/private/"},
+	}, next)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewOIDCHandler. Got: %s", err)
+	}
+
+	h.SetClock(clock)
+
+	// Check unprotected paths are served without a login
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/public/", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK || response.Body.String() != "hello " {
+		t.Errorf("Expected an anonymous response for a public path. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Test a protected path redirects to the provider
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	h.ServeHTTP(response, request)
+
+	location, _ := url.Parse(response.Header().Get("Location"))
+
+	if response.Code != http.StatusFound || location.Path != "/authorize" ||
+		location.Query().Get("code_challenge_method") != "S256" {
+
+		t.Fatalf("Expected a redirect to the provider with PKCE. Got: %d %s",
+			response.Code, location)
+	}
+
+	stateCookie := response.Result().Cookies()[0]
+
+	// Test the callback with the code the provider issued
+	code := provider.authorize(location.Query())
+	callback := "/auth/callback?code=" + code + "&state=" + location.Query().Get("state")
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", callback, nil)
+	request.AddCookie(stateCookie)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusFound || response.Header().Get("Location") != "/private/report.html" {
+		t.Fatalf("Expected a redirect back to the page. Got: %d %s",
+			response.Code, response.Header().Get("Location"))
+	}
+
+	var sessionCookie *http.Cookie
+
+	for _, cookie := range response.Result().Cookies() {
+
+		if cookie.Name == OIDCSessionCookieName {
+			sessionCookie = cookie
+		}
+	}
+
+	if sessionCookie == nil {
+		t.Fatalf("Expected a session cookie from the callback")
+	}
+
+	// Check the session gives access with the user's claims
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	request.AddCookie(sessionCookie)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK || response.Body.String() != "hello alice" {
+		t.Errorf("Expected \"hello alice\" with a session. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Check the state cookie cannot be replayed as a session
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	request.AddCookie(&http.Cookie{Name: OIDCSessionCookieName, Value: stateCookie.Value})
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusFound {
+		t.Errorf("Expected a login for a state cookie used as a session. Got: %d", response.Code)
+	}
+
+	// Check a signed session without the expected claims is rejected
+	forged := IssueToken(h.sessionKey, `{"sub":"mallory"}`, clock.Now().Add(time.Hour))
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	request.AddCookie(&http.Cookie{Name: OIDCSessionCookieName, Value: forged})
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusFound {
+		t.Errorf("Expected a login for a session without an issuer. Got: %d", response.Code)
+	}
+
+	// Check other spellings of protected paths require a login
+	for _, path := range []string{"//private/report.html", "/./private/report.html",
+		"/private/../private/report.html", "/private"} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+		request.URL.Path = path
+		h.ServeHTTP(response, request)
+
+		if response.Code != http.StatusFound {
+			t.Errorf("Expected a login for %s. Got: %d", path, response.Code)
+		}
+	}
+
+	// Check a callback with the wrong state is rejected
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/auth/callback?code="+code+"&state=forged", nil)
+	request.AddCookie(stateCookie)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusBadRequest {
+		t.Errorf("Expected StatusBadRequest for a forged state. Got: %d", response.Code)
+	}
+
+	// Check the session expires
+	clock.Advance(2 * time.Hour)
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	request.AddCookie(sessionCookie)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusFound {
+		t.Errorf("Expected a new login after the session expired. Got: %d", response.Code)
+	}
+
+	// Check no login is started without randomness
+	h.random = iotest.ErrReader(errors.New("no entropy"))
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/private/report.html", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError || response.Header().Get("Location") != "" {
+		t.Errorf("Expected StatusInternalServerError without randomness. Got: %d", response.Code)
+	}
+
+	// Check spellings a FileHandler would serve as a protected file require a
+	// login, whether it is wrapped directly or set with SetPathMatching
+	files := NewFileSystemHandler("/", http.FS(fstest.MapFS{
+		"private/report.html":   {Data: []byte("Report")},
+		"caf\u00e9/report.html": {Data: []byte("Menu")},
+	}), MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html")))
+	files.SetCaseSensitivity(CaseInsensitive)
+	files.SetPathNormalizer(func(name string) string {
+		return strings.ReplaceAll(name, "e\u0301", "\u00e9")
+	})
+
+	wrapped := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files.ServeHTTP(w, r)
+	})
+
+	for _, gated := range []struct {
+		next    http.Handler
+		matched bool
+	}{
+		{files, false},
+		{wrapped, true},
+	} {
+
+		h, err = NewOIDCHandler(OIDCConfig{
+			Issuer:      provider.server.URL,
+			ClientID:    "client",
+			RedirectURL: "https://example.com/auth/callback",
+			SessionKey:  []byte("0123456789abcdef0123456789abcdef"),
+			Prefixes:    []string{"/private/", "/caf\u00e9/"},
+		}, gated.next)
+
+		if err != nil {
+			t.Fatalf("Expected no error from NewOIDCHandler. Got: %s", err)
+		}
+
+		if gated.matched {
+			h.SetPathMatching(files)
+		}
+
+		for _, path := range []string{"/Private/report.html", "/PRIVATE/REPORT.HTML",
+			"/cafe\u0301/report.html", "/Cafe\u0301/Report.html"} {
+
+			response = httptest.NewRecorder()
+			request, _ = http.NewRequest("GET", "/", nil)
+			request.URL.Path = path
+			h.ServeHTTP(response, request)
+
+			if response.Code != http.StatusFound {
+				t.Errorf("Expected a login for %s. Got: %d %s", path, response.Code, response.Body.String())
+			}
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"log/slog"
+)
+
+// ErrorOption configures an ErrorHandler when it is created with
+// NewErrorHandler, LoadErrorHandler or MustLoadErrorHandler.
+type ErrorOption func(h *ErrorHandler)
+
+// WithDefaultMessage sets the message shown in place of the messages passed
+// to ServeError when errors are not displayed, and by ServeHTTP. Without this
+// option the default message is DefaultErrorMessage.
+func WithDefaultMessage(message string) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.defaultMessage = message
+	}
+}
+
+// WithDisplayErrors sets whether the messages passed to ServeError are shown
+// to the user, or whether the default message is shown instead. This allows
+// detailed error messages to be shown during development and hidden in
+// production. Without this option messages are hidden.
+func WithDisplayErrors(display bool) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.displayErrors = display
+	}
+}
+
+// WithStatus sets the status code of the responses written by ServeError,
+// AlwaysServeError and ServeHTTP, as SetDefaultStatus does.
+func WithStatus(status int) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.SetDefaultStatus(status)
+	}
+}
+
+// WithLogger sets a structured logger to which every served error is written,
+// as SetLogger does.
+func WithLogger(logger *slog.Logger) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.SetLogger(logger)
+	}
+}
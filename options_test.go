@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the ErrorHandler constructor options
+func TestErrorOptions(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		output   bytes.Buffer
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(`{{.ErrorMessage}}`))
+
+	tests := []struct {
+		options []ErrorOption
+		status  int
+		body    string
+	}{
+		{nil, http.StatusInternalServerError, DefaultErrorMessage},
+		{[]ErrorOption{WithDefaultMessage("Oops")}, http.StatusInternalServerError, "Oops"},
+		{[]ErrorOption{WithDisplayErrors(true)}, http.StatusInternalServerError, "Detailed error"},
+		{[]ErrorOption{WithStatus(http.StatusServiceUnavailable)}, http.StatusServiceUnavailable,
+			DefaultErrorMessage},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeError on a handler created with the options
+		h = NewErrorHandler(errorTemplate, test.options...)
+		response = httptest.NewRecorder()
+		h.ServeError(response, "Detailed error")
+
+		// Check the options are applied
+		if response.Code != test.status || response.Body.String() != test.body {
+			t.Errorf("Expected %d %q. Got: %d %q", test.status, test.body,
+				response.Code, response.Body.String())
+		}
+	}
+
+	// Check the logger option is applied
+	h = NewErrorHandler(errorTemplate, WithLogger(slog.New(slog.NewTextHandler(&output, nil))))
+	h.ServeError(httptest.NewRecorder(), "Detailed error")
+
+	if !strings.Contains(output.String(), `message="Detailed error"`) {
+		t.Errorf("Expected the error to be logged. Got: %q", output.String())
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxOriginEntries is the maximum number of files an OriginFileSystem keeps
+// fetch records for. The least recently used records are dropped first, and
+// their files are revalidated with the origin when they are next requested.
+const maxOriginEntries int = 10000
+
+// originMissingTTL is how long an OriginFileSystem remembers that the origin
+// has no file at a path, so that repeated requests for a missing file do not
+// each reach the origin.
+const originMissingTTL time.Duration = 10 * time.Second
+
+// OriginFileSystem is an http.FileSystem that fetches files from an upstream
+// HTTP origin and caches them in a local directory. Used with
+// NewFileSystemHandler it turns a FileHandler into a small pull-through cache
+// for another static host. Cached files are served without contacting the
+// origin until they are older than the file system's ttl, after which they are
+// revalidated with a conditional request. If the origin cannot be reached a
+// stale cached copy is served rather than failing the request.
+type OriginFileSystem struct {
+	origin         string
+	cacheDirectory string
+	ttl            time.Duration
+	client         *http.Client
+	mutex          sync.Mutex
+	entries        map[string]*list.Element
+	recent         *list.List
+	maxEntries     int
+	clock          Clock
+}
+
+// originEntry records when a cached file was last fetched or revalidated and
+// the validator the origin sent with it, or that the origin has no such file.
+type originEntry struct {
+	mutex   sync.Mutex
+	name    string
+	fetched time.Time
+	etag    string
+	missing bool
+}
+
+// originFile is a file opened by an OriginFileSystem, which records where its
+// contents came from.
+type originFile struct {
+	http.File
+	source string
+}
+
+// backendName returns where the file's contents came from.
+func (f *originFile) backendName() string {
+
+	return f.source
+}
+
+// NewOriginFileSystem returns a new OriginFileSystem which fetches files from
+// the origin url and caches them under cacheDirectory for the given ttl.
+func NewOriginFileSystem(origin string, cacheDirectory string, ttl time.Duration) *OriginFileSystem {
+
+	client := &http.Client{
+		Timeout: 30 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &OriginFileSystem{
+		origin:         strings.TrimSuffix(origin, "/"),
+		cacheDirectory: cacheDirectory,
+		ttl:            ttl,
+		client:         client,
+		entries:        make(map[string]*list.Element),
+		recent:         list.New(),
+		maxEntries:     maxOriginEntries,
+		clock:          SystemClock,
+	}
+}
+
+// SetClock sets the clock used to decide when cached files are older than the
+// ttl. By default the file system uses SystemClock.
+func (fs *OriginFileSystem) SetClock(clock Clock) {
+
+	fs.clock = clock
+}
+
+// Open opens the named file from the cache, fetching or revalidating it from
+// the origin first if necessary.
+func (fs *OriginFileSystem) Open(name string) (http.File, error) {
+
+	name = path.Clean("/" + name)
+	cachePath := filepath.Join(fs.cacheDirectory, filepath.FromSlash(name))
+
+	entry := fs.entry(name)
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	// Directories are created when the origin redirects to them and are
+	// always served from the cache
+	finfo, statErr := os.Stat(cachePath)
+
+	if statErr == nil && finfo.IsDir() {
+		return openCached(cachePath, "cache")
+	}
+
+	// Serve fresh files from the cache
+	age := fs.clock.Now().Sub(entry.fetched)
+
+	if statErr == nil && age < fs.ttl {
+		return openCached(cachePath, "cache")
+	}
+
+	// Answer recent misses without asking the origin again
+	if statErr != nil && entry.missing && age < originMissingTTL && age < fs.ttl {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+
+	err := fs.fetch(name, cachePath, entry, statErr == nil)
+
+	// If the origin fails, serve a stale copy if there is one
+	if err != nil && !errors.Is(err, os.ErrNotExist) && statErr == nil {
+		return openCached(cachePath, "stale cache")
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return openCached(cachePath, "origin")
+}
+
+// openCached opens a file in the cache, recording whether its contents were
+// served from the cache, fetched or revalidated from the origin, or served
+// stale because the origin failed.
+func openCached(cachePath string, source string) (http.File, error) {
+
+	file, err := os.Open(cachePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &originFile{File: file, source: source}, nil
+}
+
+// entry returns the cache entry for the named file, creating it if needed
+// and dropping the least recently used entry if there are too many.
+func (fs *OriginFileSystem) entry(name string) *originEntry {
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if element, ok := fs.entries[name]; ok {
+		fs.recent.MoveToFront(element)
+		return element.Value.(*originEntry)
+	}
+
+	entry := &originEntry{name: name}
+	fs.entries[name] = fs.recent.PushFront(entry)
+
+	for fs.recent.Len() > fs.maxEntries {
+		oldest := fs.recent.Back()
+		fs.recent.Remove(oldest)
+		delete(fs.entries, oldest.Value.(*originEntry).name)
+	}
+
+	return entry
+}
+
+// fetch requests the named file from the origin and updates the cache. If
+// cached is true the request is made conditional on the cached copy.
+func (fs *OriginFileSystem) fetch(name string, cachePath string, entry *originEntry, cached bool) error {
+
+	originURL := fs.origin + (&url.URL{Path: name}).EscapedPath()
+	request, err := http.NewRequest("GET", originURL, nil)
+
+	if err != nil {
+		return err
+	}
+
+	if cached {
+
+		if entry.etag != "" {
+			request.Header.Set("If-None-Match", entry.etag)
+		}
+
+		if finfo, err := os.Stat(cachePath); err == nil {
+			request.Header.Set("If-Modified-Since", finfo.ModTime().UTC().Format(http.TimeFormat))
+		}
+	}
+
+	response, err := fs.client.Do(request)
+
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+
+	// The cached copy is still valid
+	case http.StatusNotModified:
+
+		entry.fetched = fs.clock.Now()
+		entry.missing = false
+		return nil
+
+	// Store the new copy
+	case http.StatusOK:
+
+		err = fs.store(cachePath, response)
+
+		if err != nil {
+			return err
+		}
+
+		entry.fetched = fs.clock.Now()
+		entry.etag = response.Header.Get("ETag")
+		entry.missing = false
+		return nil
+
+	// The file has been removed from the origin
+	case http.StatusNotFound, http.StatusGone:
+
+		os.Remove(cachePath)
+		entry.fetched = fs.clock.Now()
+		entry.missing = true
+		return os.ErrNotExist
+
+	// A redirect to the same path with a slash means this is a directory
+	case http.StatusMovedPermanently, http.StatusFound,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+
+		location, err := response.Location()
+
+		if err == nil && location.Path == name+"/" {
+			return os.MkdirAll(cachePath, 0755)
+		}
+
+		return os.ErrNotExist
+	}
+
+	return fmt.Errorf("handlers: origin responded to %s with %s", originURL, response.Status)
+}
+
+// store writes the body of the response to the cache. The file is written to
+// a temporary file first and renamed, so that readers never see a partial
+// copy. Its modification time is set from the Last-Modified header if present.
+func (fs *OriginFileSystem) store(cachePath string, response *http.Response) error {
+
+	err := os.MkdirAll(filepath.Dir(cachePath), 0755)
+
+	if err != nil {
+		return err
+	}
+
+	tempFile, err := os.CreateTemp(filepath.Dir(cachePath), ".origin-*")
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tempFile.Name())
+
+	_, err = io.Copy(tempFile, response.Body)
+
+	if closeErr := tempFile.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if modified, err := http.ParseTime(response.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(tempFile.Name(), modified, modified)
+	}
+
+	return os.Rename(tempFile.Name(), cachePath)
+}
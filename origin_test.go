@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test OriginFileSystem functions and methods
+func TestOriginFileSystem(t *testing.T) {
+
+	var (
+		fs       *OriginFileSystem
+		origin   *httptest.Server
+		requests int
+		file     http.File
+		contents []byte
+		err      error
+	)
+
+	// Start an origin that serves a single page with an ETag
+	origin = httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+
+			requests++
+
+			switch r.URL.Path {
+
+			case "/page.html":
+
+				if r.Header.Get("If-None-Match") == "\"v1\"" {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+
+				w.Header().Set("ETag", "\"v1\"")
+				io.WriteString(w, "Origin")
+
+			case "/sub":
+
+				http.Redirect(w, r, "/sub/", http.StatusMovedPermanently)
+
+			default:
+
+				http.NotFound(w, r)
+			}
+		}))
+
+	clock := NewFixedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+	fs = NewOriginFileSystem(origin.URL, t.TempDir(), time.Hour)
+	fs.SetClock(clock)
+
+	// Test Open fetches the page from the origin
+	file, err = fs.Open("/page.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error from OriginFileSystem. Got: %s", err)
+	}
+
+	contents, _ = io.ReadAll(file)
+	file.Close()
+
+	if string(contents) != "Origin" || requests != 1 {
+		t.Errorf("Expected \"Origin\" after 1 request from OriginFileSystem. "+
+			"Got: %s after %d requests", contents, requests)
+	}
+
+	// Test Open serves a fresh page from the cache
+	file, err = fs.Open("/page.html")
+
+	if err == nil {
+		file.Close()
+	}
+
+	if requests != 1 {
+		t.Errorf("Expected a cached page from OriginFileSystem. Got: %d requests",
+			requests)
+	}
+
+	// Test Open revalidates a stale page
+	clock.Advance(2 * time.Hour)
+	file, err = fs.Open("/page.html")
+
+	if err != nil {
+		t.Fatalf("Expected no error from OriginFileSystem. Got: %s", err)
+	}
+
+	contents, _ = io.ReadAll(file)
+	file.Close()
+
+	if string(contents) != "Origin" || requests != 2 {
+		t.Errorf("Expected \"Origin\" after 2 requests from OriginFileSystem. "+
+			"Got: %s after %d requests", contents, requests)
+	}
+
+	// Test Open recognises a directory from a trailing slash redirect
+	file, err = fs.Open("/sub")
+
+	if err != nil {
+		t.Fatalf("Expected no error from OriginFileSystem. Got: %s", err)
+	}
+
+	if finfo, _ := file.Stat(); finfo == nil || !finfo.IsDir() {
+		t.Errorf("Expected a directory from OriginFileSystem")
+	}
+
+	file.Close()
+
+	// Test Open reports a missing file
+	if _, err = fs.Open("/missing.html"); err == nil {
+		t.Errorf("Expected an error from OriginFileSystem for a missing file")
+	}
+
+	// Check a recent miss is answered without asking the origin
+	before := requests
+
+	if _, err = fs.Open("/missing.html"); err == nil || requests != before {
+		t.Errorf("Expected a remembered miss from OriginFileSystem. Got: %d requests",
+			requests-before)
+	}
+
+	clock.Advance(originMissingTTL)
+
+	if _, err = fs.Open("/missing.html"); err == nil || requests != before+1 {
+		t.Errorf("Expected the origin to be asked again after the miss expired. Got: %d requests",
+			requests-before)
+	}
+
+	// Check the number of entries is bounded
+	fs.maxEntries = 3
+
+	for _, name := range []string{"/a", "/b", "/c", "/d", "/e"} {
+		fs.Open(name)
+	}
+
+	if len(fs.entries) != 3 || fs.recent.Len() != 3 {
+		t.Errorf("Expected 3 entries from OriginFileSystem. Got: %d", len(fs.entries))
+	}
+
+	if _, ok := fs.entries["/e"]; !ok {
+		t.Errorf("Expected the most recent entry to be kept")
+	}
+
+	// Test Open serves a stale page when the origin is down
+	origin.Close()
+	clock.Advance(2 * time.Hour)
+	file, err = fs.Open("/page.html")
+
+	if err != nil {
+		t.Fatalf("Expected a stale page from OriginFileSystem. Got: %s", err)
+	}
+
+	file.Close()
+}
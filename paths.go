@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// windowsReservedNames are the device names that Windows reserves in every
+// directory, whatever the extension of the file name.
+var windowsReservedNames = map[string]bool{
+	"con": true, "prn": true, "aux": true, "nul": true,
+	"com1": true, "com2": true, "com3": true, "com4": true, "com5": true,
+	"com6": true, "com7": true, "com8": true, "com9": true,
+	"com¹": true, "com²": true, "com³": true,
+	"lpt1": true, "lpt2": true, "lpt3": true, "lpt4": true, "lpt5": true,
+	"lpt6": true, "lpt7": true, "lpt8": true, "lpt9": true,
+	"lpt¹": true, "lpt²": true, "lpt³": true,
+	"conin$": true, "conout$": true,
+}
+
+// safeRequestPath reports whether a slash-separated request path can be
+// looked up in a file system on any platform. Paths are rejected if they
+// contain parent directory segments, backslashes or colons, which Windows
+// treats as separators and drive or stream markers, segments ending in a dot
+// or space, which Windows silently strips, or segments naming a Windows device
+// such as CON or NUL. Rejecting these everywhere means a site behaves the same
+// in development and production, whatever the platform of each machine.
+func safeRequestPath(requestPath string) bool {
+
+	if strings.ContainsAny(requestPath, "\\:\x00") {
+		return false
+	}
+
+	for _, segment := range strings.Split(requestPath, "/") {
+
+		if segment == ".." {
+			return false
+		}
+
+		if segment == "" || segment == "." {
+			continue
+		}
+
+		if strings.HasSuffix(segment, ".") || strings.HasSuffix(segment, " ") {
+			return false
+		}
+
+		name := strings.ToLower(segment)
+
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[:i]
+		}
+
+		if windowsReservedNames[strings.TrimRight(name, " ")] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// safeEscapedPath reports whether the escaped form of a request url is free of
+// percent-encoded separators. The handler looks files up by the decoded path,
+// which net/url decodes exactly once, so an encoded slash or backslash would
+// otherwise be indistinguishable from a real one and could be used to reach
+// files through paths that no link on the site uses.
+func safeEscapedPath(u *url.URL) bool {
+
+	escaped := strings.ToLower(u.EscapedPath())
+	return !strings.Contains(escaped, "%2f") && !strings.Contains(escaped, "%5c")
+}
+
+// hasPathPrefix reports whether the request path, once cleaned and folded
+// with foldPath, is the prefix or lies beneath it. Matching is by whole
+// segments, so that "/admin" matches "/admin/users" but not "/administrator",
+// and cleaning and folding first means that spellings such as "//admin",
+// "/./admin" and "/Admin" cannot be used to avoid a rule that the handler
+// serving the path would not notice.
+func hasPathPrefix(urlPath string, prefix string, files *FileHandler) bool {
+
+	folded := foldPath(urlPath, files)
+	trimmed := strings.TrimSuffix(foldPath(prefix, files), "/")
+	return folded == trimmed || strings.HasPrefix(folded, trimmed+"/")
+}
+
+// foldPath cleans a path and folds it the way the FileHandler matches request
+// paths against file names, for handlers that guard the paths it serves. The
+// FileHandler's normalization function is applied, and case is folded unless
+// it is CaseSensitive, because with the default policy the file system may
+// ignore case. Without a FileHandler case is always folded, so that a guard
+// errs on the side of covering too many paths rather than too few.
+func foldPath(urlPath string, files *FileHandler) string {
+
+	folded := path.Clean("/" + urlPath)
+
+	if files != nil && files.normalize != nil {
+		folded = files.normalize(folded)
+	}
+
+	if files == nil || files.caseSensitivity != CaseSensitive {
+		folded = strings.ToLower(folded)
+	}
+
+	return folded
+}
+
+// CaseSensitivity is a policy controlling how a FileHandler matches the case
+// of request paths against the names of files.
+type CaseSensitivity int
+
+const (
+	// CaseDefault leaves matching to the file system, so lookups are case
+	// sensitive on Linux but usually case insensitive on macOS and Windows.
+	CaseDefault CaseSensitivity = iota
+
+	// CaseSensitive requires every segment of the path to match the case of
+	// the file name exactly, whatever the file system.
+	CaseSensitive
+
+	// CaseInsensitive matches segments of the path to file names ignoring
+	// case, whatever the file system. An exact match is preferred if a
+	// directory holds names that differ only in case.
+	CaseInsensitive
+)
+
+// resolvePath applies the handler's case sensitivity and normalization
+// policies to the given file path. It returns the path to open, with each
+// segment spelled as it is in the file system, and false if no file matches
+// under the policies.
+func (h *FileHandler) resolvePath(filePath string) (string, bool) {
+
+	if h.caseSensitivity == CaseDefault && h.normalize == nil {
+		return filePath, true
+	}
+
+	resolved := ""
+
+	for _, segment := range strings.Split(filePath, "/") {
+
+		if segment == "" {
+			continue
+		}
+
+		directory := resolved
+
+		if directory == "" {
+			directory = "/"
+		}
+
+		name, ok := h.matchSegment(directory, segment)
+
+		if !ok {
+			return "", false
+		}
+
+		resolved += "/" + name
+	}
+
+	if resolved == "" {
+		resolved = "/"
+	}
+
+	return resolved, true
+}
+
+// matchSegment returns the name of the entry in the directory that matches
+// the segment under the handler's case sensitivity and normalization policies.
+// An exact match is always preferred.
+func (h *FileHandler) matchSegment(directory string, segment string) (string, bool) {
+
+	dir, err := h.fileSystem.Open(directory)
+
+	if err != nil {
+		return "", false
+	}
+
+	defer dir.Close()
+
+	entries, err := dir.Readdir(-1)
+
+	if err != nil {
+		return "", false
+	}
+
+	match := ""
+	normalized := h.normalizeName(segment)
+
+	for _, entry := range entries {
+
+		if entry.Name() == segment {
+			return segment, true
+		}
+
+		if match != "" {
+			continue
+		}
+
+		candidate := h.normalizeName(entry.Name())
+
+		if candidate == normalized ||
+			(h.caseSensitivity == CaseInsensitive && strings.EqualFold(candidate, normalized)) {
+			match = entry.Name()
+		}
+	}
+
+	return match, match != ""
+}
+
+// normalizeName applies the handler's normalization function to a name.
+func (h *FileHandler) normalizeName(name string) string {
+
+	if h.normalize == nil {
+		return name
+	}
+
+	return h.normalize(name)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+)
+
+// PreviewErrorMessage is the sample message shown in previews of the error
+// page.
+const PreviewErrorMessage string = "This is a preview of the error page."
+
+// PreviewNotFoundPath is the sample path shown in previews of the 404 page.
+const PreviewNotFoundPath string = "/preview/missing-page.html"
+
+// ErrorPreviewHandler serves previews of a site's status pages with sample
+// data, so that they can be designed and styled without triggering real
+// failures. It is intended for development and should not be exposed in
+// production. Mount it on a path such as "/_dev/errors/" and request the
+// status code under it, for example "/_dev/errors/404". A request for the
+// mounted path itself lists the available previews. Previews are served with
+// their real status code, so browser tools show the page as it will be sent.
+type ErrorPreviewHandler struct {
+	pages map[int]http.Handler
+}
+
+// NewErrorPreviewHandler returns a new ErrorPreviewHandler previewing the 500
+// page of errorHandler and the 404 page of notFoundHandler. Either may be nil.
+// Pages for other status codes can be added with Add.
+func NewErrorPreviewHandler(errorHandler *ErrorHandler, notFoundHandler *NotFoundHandler) *ErrorPreviewHandler {
+
+	h := &ErrorPreviewHandler{pages: make(map[int]http.Handler)}
+
+	if errorHandler != nil {
+
+		h.pages[http.StatusInternalServerError] = http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				errorHandler.AlwaysServeError(w, PreviewErrorMessage)
+			})
+	}
+
+	if notFoundHandler != nil {
+
+		h.pages[http.StatusNotFound] = http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+
+				// Serve the page as if the sample path had been requested,
+				// keeping the query so query parameters can be previewed
+				preview := r.Clone(r.Context())
+				preview.URL.Path = PreviewNotFoundPath
+				preview.URL.RawPath = ""
+				notFoundHandler.ServeHTTP(w, preview)
+			})
+	}
+
+	return h
+}
+
+// Add adds a preview of the page served by handler for the given status
+// code, such as a 403 page, replacing any existing preview for that code.
+func (h *ErrorPreviewHandler) Add(status int, handler http.Handler) {
+
+	h.pages[status] = handler
+}
+
+// previewIndexTemplate lists the available previews.
+var previewIndexTemplate = template.Must(template.New("previews").Parse(
+	`<!DOCTYPE html><html lang="en"><head><title>Status page previews</title></head>` +
+		`<body><main><h1>Status page previews</h1><ul>` +
+		`{{range .}}<li><a href="{{.Link}}">{{.Status}} {{.Text}}</a></li>{{end}}` +
+		`</ul></main></body></html>`))
+
+// previewLink describes a preview in the index.
+type previewLink struct {
+	Link   string
+	Status int
+	Text   string
+}
+
+// ServeHTTP serves the preview named by the last segment of the request path,
+// or the index of previews if the last segment is not a status code.
+func (h *ErrorPreviewHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	setServerHeader(w)
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Robots-Tag", "noindex")
+
+	status, err := strconv.Atoi(path.Base(r.URL.Path))
+
+	if err != nil {
+		h.serveIndex(w, r)
+		return
+	}
+
+	page, ok := h.pages[status]
+
+	if !ok {
+		http.Error(w, fmt.Sprintf("No preview for status %d", status), http.StatusNotFound)
+		return
+	}
+
+	page.ServeHTTP(w, r)
+}
+
+// serveIndex serves a list of links to the available previews.
+func (h *ErrorPreviewHandler) serveIndex(w http.ResponseWriter, r *http.Request) {
+
+	statuses := make([]int, 0, len(h.pages))
+
+	for status := range h.pages {
+		statuses = append(statuses, status)
+	}
+
+	sort.Ints(statuses)
+	links := make([]previewLink, len(statuses))
+	directory := r.URL.Path
+
+	if directory == "" || directory[len(directory)-1] != '/' {
+		directory += "/"
+	}
+
+	for i, status := range statuses {
+
+		links[i] = previewLink{
+			Link:   directory + strconv.Itoa(status),
+			Status: status,
+			Text:   http.StatusText(status),
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	previewIndexTemplate.Execute(w, links)
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the ErrorPreviewHandler
+func TestErrorPreviewHandler(t *testing.T) {
+
+	var (
+		h        *ErrorPreviewHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh := NewNotFoundHandler(template.Must(template.New("notfound").Parse(
+		`Not found: {{.Path}} {{.Query.q}}`)))
+	nfh.SetQueryParams("q")
+
+	forbidden := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Forbidden page", http.StatusForbidden)
+	})
+
+	h = NewErrorPreviewHandler(NewDefaultErrorHandler(), nfh)
+	h.Add(http.StatusForbidden, forbidden)
+
+	tests := []struct {
+		path     string
+		status   int
+		contains string
+	}{
+		{"/_dev/errors/500", http.StatusInternalServerError, PreviewErrorMessage},
+		{"/_dev/errors/404?q=sample", http.StatusNotFound, "Not found: " + PreviewNotFoundPath + " sample"},
+		{"/_dev/errors/403", http.StatusForbidden, "Forbidden page"},
+		{"/_dev/errors/418", http.StatusNotFound, "No preview for status 418"},
+		{"/_dev/errors/", http.StatusOK, `<a href="/_dev/errors/403">403 Forbidden</a>`},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the preview path
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the preview is served with its status and sample data
+		if response.Code != test.status || !strings.Contains(response.Body.String(), test.contains) {
+			t.Errorf("Expected %d containing %q for %s. Got: %d %s",
+				test.status, test.contains, test.path, response.Code, response.Body.String())
+		}
+
+		// Check previews are not cached
+		if cacheControl := response.Header().Get("Cache-Control"); cacheControl != "no-store" {
+			t.Errorf("Expected Cache-Control \"no-store\" for %s. Got: %s", test.path, cacheControl)
+		}
+	}
+}
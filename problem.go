@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// ErrorFormat is the format in which an ErrorHandler serves errors.
+type ErrorFormat int
+
+// The formats in which an ErrorHandler can serve errors. ErrorFormatNegotiated
+// chooses one of the others for each request.
+const (
+	ErrorFormatHTML ErrorFormat = iota
+	ErrorFormatProblemJSON
+	ErrorFormatText
+	ErrorFormatNegotiated
+)
+
+// ProblemDetails is the application/problem+json document described by
+// RFC 7807, as served by an ErrorHandler in ErrorFormatProblemJSON. ErrorID is
+// an extension member holding the id of the error.
+type ProblemDetails struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	ErrorID  string `json:"errorId,omitempty"`
+}
+
+// SetFormat sets the format in which the handler serves errors. By default
+// errors are served in the handler's HTML template. In ErrorFormatProblemJSON
+// they are served as application/problem+json documents, for handlers used
+// behind API routes. The document's detail is the message the template would
+// have shown, so the handler's display setting still applies, its title is
+// the status text and its instance is the request path, when there is a
+// request. In ErrorFormatText the message is served as plain text. In
+// ErrorFormatNegotiated the format is chosen from the request's Accept
+// header. The HTML page is served only to clients that name text/html or
+// application/xhtml+xml, as browsers do, and clients asking for plain text
+// get text. Other clients, including curl and fetch, which send */* or no
+// header at all, get a problem document. HTML is used when the error is
+// served without a request.
+func (h *ErrorHandler) SetFormat(format ErrorFormat) {
+
+	h.format = format
+}
+
+// SetProblemType sets the type uri of the problem documents served by the
+// handler, which identifies the kind of problem. By default it is
+// "about:blank", which means the problem is described by its status code.
+func (h *ErrorHandler) SetProblemType(uri string) {
+
+	h.problemType = uri
+}
+
+// negotiateErrorFormat returns the format the request's Accept header prefers.
+// Formats are ranked by quality, then by how specifically they are named, so
+// "application/json, */*" chooses JSON, then in the order HTML, JSON, text.
+// HTML must be named exactly rather than through a wildcard, and JSON is
+// served when the header prefers none of the formats.
+func negotiateErrorFormat(r *http.Request) ErrorFormat {
+
+	if r == nil {
+		return ErrorFormatHTML
+	}
+
+	candidates := []struct {
+		format     ErrorFormat
+		mediaTypes []string
+		wildcards  bool
+	}{
+		{ErrorFormatHTML, []string{"text/html", "application/xhtml+xml"}, false},
+		{ErrorFormatProblemJSON, []string{"application/problem+json", "application/json"}, true},
+		{ErrorFormatText, []string{"text/plain"}, true},
+	}
+
+	var (
+		best            ErrorFormat = ErrorFormatProblemJSON
+		bestQuality     float64
+		bestSpecificity int = -1
+	)
+
+	for _, candidate := range candidates {
+
+		for _, mediaType := range candidate.mediaTypes {
+
+			quality, specificity := acceptQuality(r, mediaType, candidate.wildcards)
+
+			if quality > bestQuality || (quality == bestQuality && quality > 0 &&
+				specificity > bestSpecificity) {
+
+				best, bestQuality, bestSpecificity = candidate.format, quality, specificity
+			}
+		}
+	}
+
+	return best
+}
+
+// serveText serves the message and error id as plain text with the given
+// status code.
+func (h *ErrorHandler) serveText(w http.ResponseWriter, message string, errorID string, status int) {
+
+	if h.contentLanguage != "" {
+		w.Header().Set("Content-Language", h.contentLanguage)
+	}
+
+	body := message + "\nError ID: " + errorID + "\n"
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write([]byte(body))
+}
+
+// serveProblem serves the message and error id as a problem document with
+// the given status code. The problem's instance is the request's path, and is
+// left out when there is no request.
+func (h *ErrorHandler) serveProblem(w http.ResponseWriter, r *http.Request, message string,
+	errorID string, status int) {
+
+	problem := ProblemDetails{
+		Type:    h.problemType,
+		Title:   http.StatusText(status),
+		Status:  status,
+		Detail:  message,
+		ErrorID: errorID,
+	}
+
+	if problem.Type == "" {
+		problem.Type = "about:blank"
+	}
+
+	if r != nil {
+		problem.Instance = r.URL.Path
+	}
+
+	body, err := json.Marshal(problem)
+
+	if err != nil {
+		serveInternalError(w, err)
+		return
+	}
+
+	if h.contentLanguage != "" {
+		w.Header().Set("Content-Language", h.contentLanguage)
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	w.Write(body)
+}
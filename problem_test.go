@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test ErrorHandler problem+json output
+func TestErrorHandlerProblemJSON(t *testing.T) {
+
+	const (
+		defaultMessage string = "Default error message"
+		customMessage  string = "Upstream timed out"
+	)
+
+	var (
+		h        *ErrorHandler
+		problem  ProblemDetails
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	h = NewErrorHandler(DefaultErrorTemplate(), WithDefaultMessage(defaultMessage))
+	h.SetFormat(ErrorFormatProblemJSON)
+
+	// Test ServeErrorWithStatus with display errors off
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, customMessage, http.StatusGatewayTimeout)
+
+	// Check the problem document hides the message
+	if contentType := response.Header().Get("Content-Type"); contentType != "application/problem+json" {
+		t.Errorf("Expected Content-Type \"application/problem+json\". Got: %s", contentType)
+	}
+
+	if err := json.Unmarshal(response.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Expected a JSON document. Got: %s", response.Body.String())
+	}
+
+	if len(problem.ErrorID) != 16 {
+		t.Errorf("Expected a generated error id. Got: %q", problem.ErrorID)
+	}
+
+	expected := ProblemDetails{
+		Type:    "about:blank",
+		Title:   "Gateway Timeout",
+		Status:  http.StatusGatewayTimeout,
+		Detail:  defaultMessage,
+		ErrorID: problem.ErrorID,
+	}
+
+	if response.Code != http.StatusGatewayTimeout || problem != expected {
+		t.Errorf("Expected %+v. Got: %d %+v", expected, response.Code, problem)
+	}
+
+	// Test ServeHTTP with a problem type and a request id
+	h.SetProblemType("https://example.com/problems/unavailable")
+	h.SetRequestIDHeader("X-Request-ID")
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/api/items", nil)
+	request.Header.Set("X-Request-ID", "req-123")
+	h.ServeHTTP(response, request)
+
+	// Check the document has the type, the request path and the request id
+	problem = ProblemDetails{}
+	json.Unmarshal(response.Body.Bytes(), &problem)
+
+	expected = ProblemDetails{
+		Type:     "https://example.com/problems/unavailable",
+		Title:    "Internal Server Error",
+		Status:   http.StatusInternalServerError,
+		Detail:   defaultMessage,
+		Instance: "/api/items",
+		ErrorID:  "req-123",
+	}
+
+	if problem != expected {
+		t.Errorf("Expected %+v. Got: %+v", expected, problem)
+	}
+}
+
+// Test ErrorHandler content negotiation
+func TestErrorHandlerNegotiation(t *testing.T) {
+
+	const message string = "Something failed"
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	h = NewErrorHandler(DefaultErrorTemplate(), WithDefaultMessage(message))
+	h.SetFormat(ErrorFormatNegotiated)
+	h.SetCharset("utf-8")
+
+	tests := []struct {
+		accept      string
+		contentType string
+	}{
+		{"", "application/problem+json"},
+		{"*/*", "application/problem+json"},
+		{"text/*", "text/plain; charset=utf-8"},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", "text/html; charset=utf-8"},
+		{"application/json", "application/problem+json"},
+		{"application/json, */*", "application/problem+json"},
+		{"application/problem+json", "application/problem+json"},
+		{"text/plain", "text/plain; charset=utf-8"},
+		{"text/html;q=0.5, text/plain", "text/plain; charset=utf-8"},
+		{"text/html;q=0, */*", "application/problem+json"},
+		{"image/png", "application/problem+json"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the Accept header
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+
+		if test.accept != "" {
+			request.Header.Set("Accept", test.accept)
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the preferred format is served and the response varies
+		if contentType := response.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("Expected %q for Accept %q. Got: %q", test.contentType, test.accept, contentType)
+		}
+
+		if vary := response.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("Expected Vary \"Accept\" for Accept %q. Got: %q", test.accept, vary)
+		}
+	}
+
+	// Test the text format without negotiation
+	h.SetFormat(ErrorFormatText)
+	response = httptest.NewRecorder()
+	h.ServeErrorWithStatus(response, "Hidden", http.StatusServiceUnavailable)
+
+	// Check the default message is served as text with the status and id
+	if response.Code != http.StatusServiceUnavailable ||
+		!strings.HasPrefix(response.Body.String(), message+"\nError ID: ") {
+
+		t.Errorf("Expected StatusServiceUnavailable with %q. Got: %d %q",
+			message, response.Code, response.Body.String())
+	}
+}
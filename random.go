@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	cryptorand "crypto/rand"
+	"io"
+	"math/rand"
+	"sync"
+)
+
+// cryptoRandom is the random source used by default. It reads from
+// crypto/rand, so values generated from it, such as client ids, cannot be
+// predicted. It is unexported so that no importer can replace it with a weak
+// source; handlers that need a reproducible source take one with SetRandom.
+var cryptoRandom io.Reader = cryptorand.Reader
+
+// SeededRandom returns a deterministic random source which produces the same
+// bytes for the same seed, so that tests of behaviour that depends on random
+// values are reproducible. It must not be used where values need to be
+// unpredictable. The source is safe for concurrent use.
+func SeededRandom(seed int64) io.Reader {
+
+	return &seededRandom{random: rand.New(rand.NewSource(seed))}
+}
+
+// seededRandom is a math/rand source guarded by a mutex.
+type seededRandom struct {
+	mutex  sync.Mutex
+	random *rand.Rand
+}
+
+// Read fills p with the next bytes from the seeded source. It never fails.
+func (r *seededRandom) Read(p []byte) (int, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.random.Read(p)
+}
@@ -0,0 +1,340 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultMaxRecordings is the number of recordings a RecordingHandler keeps
+// unless it is given another limit.
+const defaultMaxRecordings int = 100
+
+// maxRecordedBody is the maximum number of bytes of a response body kept in
+// a recording.
+const maxRecordedBody int = 64 << 10
+
+// recordedHeaders are the request headers kept in a recording. Other headers,
+// such as Authorization and Cookie, may identify the user and are dropped.
+var recordedHeaders = []string{
+	"Accept", "Accept-Encoding", "Accept-Language", "Content-Type", "If-Match",
+	"If-Modified-Since", "If-None-Match", "If-Unmodified-Since", "Range", "User-Agent",
+}
+
+// redactedHeaders are the response headers dropped from every recording, as
+// they can carry credentials or echo them back. More can be added with
+// RecordingHandler.RedactHeaders.
+var redactedHeaders = []string{
+	"Authorization", "Cookie", "Proxy-Authorization", "Set-Cookie", "X-Api-Key",
+	"X-Auth-Token", "X-Csrf-Token",
+}
+
+// Recording is a request and its response captured by a RecordingHandler.
+// The request is anonymized: URL keeps the names of query parameters but not
+// their values, Header keeps only the headers that describe what the client
+// accepts, and the client's address is not recorded. ResponseBody holds up to
+// 64KB of the body, and Truncated says whether there was more. Headers that
+// can carry credentials, such as Set-Cookie and Authorization, are dropped
+// from ResponseHeader.
+type Recording struct {
+	Time           time.Time   `json:"time"`
+	Method         string      `json:"method"`
+	URL            string      `json:"url"`
+	Host           string      `json:"host"`
+	Header         http.Header `json:"header"`
+	Status         int         `json:"status"`
+	ResponseHeader http.Header `json:"responseHeader"`
+	ResponseBody   string      `json:"responseBody"`
+	Truncated      bool        `json:"truncated"`
+}
+
+// RecordingHandler records anonymized requests whose responses have failing
+// statuses, and the responses themselves, to JSON files in a directory, so
+// that reports of 404s and 500s seen by users can be reproduced. The
+// recordings can be loaded with LoadRecordings and replayed against a local
+// handler tree with handlerstest.Replay. By default responses with a status of
+// 400 or above are recorded, and only the 100 most recent recordings are
+// kept, older ones being deleted as new ones are written.
+type RecordingHandler struct {
+	next      http.Handler
+	directory string
+	statuses  map[int]bool
+	max       int
+	clock     Clock
+	mutex     sync.Mutex
+	files     []string
+	sequence  int
+	redacted  []string
+}
+
+// NewRecordingHandler returns a new RecordingHandler in front of next, which
+// writes recordings to directory, creating it if necessary. Recordings already
+// in the directory count towards the handler's limit.
+func NewRecordingHandler(next http.Handler, directory string) (*RecordingHandler, error) {
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return &RecordingHandler{
+		next:      next,
+		directory: directory,
+		max:       defaultMaxRecordings,
+		clock:     SystemClock,
+		files:     files,
+		redacted:  redactedHeaders,
+	}, nil
+}
+
+// SetStatuses sets the statuses of the responses that are recorded. Calling
+// it with no statuses records every response with a status of 400 or above,
+// which is the default.
+func (h *RecordingHandler) SetStatuses(statuses ...int) {
+
+	h.statuses = nil
+
+	if len(statuses) == 0 {
+		return
+	}
+
+	h.statuses = make(map[int]bool, len(statuses))
+
+	for _, status := range statuses {
+		h.statuses[status] = true
+	}
+}
+
+// RedactHeaders adds the named response headers to those dropped from
+// recordings, for headers an application uses for tokens, session ids or
+// other secrets. Headers that commonly carry credentials, such as Set-Cookie,
+// Authorization and X-Api-Key, are always dropped.
+func (h *RecordingHandler) RedactHeaders(names ...string) {
+
+	h.redacted = append(append([]string{}, h.redacted...), names...)
+}
+
+// SetMaxRecordings sets the number of recordings kept in the directory. The
+// oldest recordings are deleted when it is exceeded.
+func (h *RecordingHandler) SetMaxRecordings(max int) {
+
+	h.max = max
+}
+
+// SetClock sets the clock used to timestamp recordings. By default the
+// handler uses SystemClock.
+func (h *RecordingHandler) SetClock(clock Clock) {
+
+	h.clock = clock
+}
+
+// ServeHTTP serves the request with the next handler, and records it if its
+// response has one of the recorded statuses. The response body is only kept
+// in memory when the response is to be recorded.
+func (h *RecordingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	recorder := &recordingWriter{ResponseWriter: w, records: h.records}
+	h.next.ServeHTTP(recorder, r)
+
+	status := recorder.status
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	if !h.records(status) {
+		return
+	}
+
+	recording := Recording{
+		Time:           h.clock.Now(),
+		Method:         r.Method,
+		URL:            anonymizedURL(r.URL),
+		Host:           r.Host,
+		Header:         make(http.Header),
+		Status:         status,
+		ResponseHeader: w.Header().Clone(),
+		ResponseBody:   recorder.body.String(),
+		Truncated:      recorder.truncated,
+	}
+
+	for _, name := range h.redacted {
+		recording.ResponseHeader.Del(name)
+	}
+
+	for _, name := range recordedHeaders {
+
+		if values := r.Header.Values(name); len(values) > 0 {
+			recording.Header[name] = values
+		}
+	}
+
+	if err := h.write(recording); err != nil {
+		log.Printf("handlers: recording %s failed: %s", recording.URL, err)
+	}
+}
+
+// records reports whether responses with the status are recorded.
+func (h *RecordingHandler) records(status int) bool {
+
+	if h.statuses == nil {
+		return status >= 400
+	}
+
+	return h.statuses[status]
+}
+
+// write writes the recording to a new file and deletes the oldest recordings
+// beyond the handler's limit.
+func (h *RecordingHandler) write(recording Recording) error {
+
+	contents, err := json.MarshalIndent(recording, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.sequence++
+	name := fmt.Sprintf("%s-%06d.json", recording.Time.UTC().Format("20060102T150405Z"), h.sequence)
+	file := filepath.Join(h.directory, name)
+
+	if err := os.WriteFile(file, contents, 0644); err != nil {
+		return err
+	}
+
+	h.files = append(h.files, file)
+
+	for len(h.files) > h.max && len(h.files) > 0 {
+		os.Remove(h.files[0])
+		h.files = h.files[1:]
+	}
+
+	return nil
+}
+
+// LoadRecordings returns the recordings written by a RecordingHandler to
+// directory, oldest first.
+func LoadRecordings(directory string) ([]Recording, error) {
+
+	files, err := filepath.Glob(filepath.Join(directory, "*.json"))
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+	recordings := make([]Recording, 0, len(files))
+
+	for _, file := range files {
+
+		contents, err := os.ReadFile(file)
+
+		if err != nil {
+			return nil, err
+		}
+
+		var recording Recording
+
+		if err := json.Unmarshal(contents, &recording); err != nil {
+			return nil, fmt.Errorf("handlers: invalid recording %s: %s", file, err)
+		}
+
+		recordings = append(recordings, recording)
+	}
+
+	return recordings, nil
+}
+
+// anonymizedURL returns the path and query of the url with the values of the
+// query parameters removed.
+func anonymizedURL(u *url.URL) string {
+
+	query := u.Query()
+
+	if len(query) == 0 {
+		return u.EscapedPath()
+	}
+
+	names := make([]string, 0, len(query))
+
+	for name := range query {
+		names = append(names, url.QueryEscape(name)+"=")
+	}
+
+	sort.Strings(names)
+	return u.EscapedPath() + "?" + strings.Join(names, "&")
+}
+
+// recordingWriter passes a response through to the client, keeping its
+// status and, if records reports that the status is recorded, the start of
+// its body.
+type recordingWriter struct {
+	http.ResponseWriter
+	records   func(status int) bool
+	status    int
+	recording bool
+	body      strings.Builder
+	truncated bool
+}
+
+// WriteHeader notes the first status written, and whether it is recorded,
+// before passing it on.
+func (w *recordingWriter) WriteHeader(status int) {
+
+	if w.status == 0 {
+		w.status = status
+		w.recording = w.records(status)
+	}
+
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write passes p through, keeping it if the response is recorded until
+// maxRecordedBody bytes have been kept. A body written without a status is
+// a 200.
+func (w *recordingWriter) Write(p []byte) (int, error) {
+
+	if w.status == 0 {
+		w.status = http.StatusOK
+		w.recording = w.records(w.status)
+	}
+
+	if !w.recording {
+		return w.ResponseWriter.Write(p)
+	}
+
+	if remaining := maxRecordedBody - w.body.Len(); len(p) > remaining {
+		w.body.Write(p[:remaining])
+		w.truncated = true
+	} else {
+		w.body.Write(p)
+	}
+
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the response writer being recorded, so that wrapped
+// handlers can still reach its optional methods with
+// http.ResponseController.
+func (w *recordingWriter) Unwrap() http.ResponseWriter {
+
+	return w.ResponseWriter
+}
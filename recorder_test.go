@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the RecordingHandler
+func TestRecordingHandler(t *testing.T) {
+
+	var (
+		h        *RecordingHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		switch r.URL.Path {
+
+		case "/ok":
+			w.Write([]byte("OK"))
+
+		case "/large":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(strings.Repeat("x", maxRecordedBody+1)))
+
+		default:
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+			w.Header().Set("Authorization", "Bearer echoed")
+			w.Header().Set("X-Session-Token", "secret")
+			http.Error(w, "Not found", http.StatusNotFound)
+		}
+	})
+
+	directory := filepath.Join(t.TempDir(), "recordings")
+	h, err := NewRecordingHandler(next, directory)
+
+	if err != nil {
+		t.Fatalf("Expected no error from NewRecordingHandler. Got: %s", err)
+	}
+
+	h.SetClock(NewFixedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	h.RedactHeaders("X-Session-Token")
+
+	serve := func(path string) {
+
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", path, nil)
+		request.Header.Set("Accept", "text/html")
+		request.Header.Set("Cookie", "session=secret")
+		request.Header.Set("Authorization", "Bearer secret")
+		h.ServeHTTP(response, request)
+	}
+
+	// Check successful responses are not recorded, or held in memory
+	serve("/ok")
+
+	recorder := &recordingWriter{ResponseWriter: httptest.NewRecorder(), records: h.records}
+	recorder.Write([]byte("OK"))
+
+	if recorder.body.Len() != 0 {
+		t.Errorf("Expected the body of an unrecorded response not to be kept. Got: %q",
+			recorder.body.String())
+	}
+
+	if recordings, _ := LoadRecordings(directory); len(recordings) != 0 {
+		t.Errorf("Expected no recordings for a 200. Got: %d", len(recordings))
+	}
+
+	// Check a failing response is recorded anonymously
+	serve("/missing?q=private&page=2")
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected the response to be passed through. Got: %d", response.Code)
+	}
+
+	recordings, err := LoadRecordings(directory)
+
+	if err != nil || len(recordings) != 1 {
+		t.Fatalf("Expected one recording. Got: %d %v", len(recordings), err)
+	}
+
+	recording := recordings[0]
+
+	if recording.URL != "/missing?page=&q=" || recording.Status != http.StatusNotFound ||
+		recording.ResponseBody != "Not found\n" || recording.Header.Get("Accept") != "text/html" {
+
+		t.Errorf("Expected the request and response in the recording. Got: %+v", recording)
+	}
+
+	if recording.Header.Get("Cookie") != "" || recording.Header.Get("Authorization") != "" ||
+		recording.ResponseHeader.Get("Set-Cookie") != "" ||
+		recording.ResponseHeader.Get("Authorization") != "" ||
+		recording.ResponseHeader.Get("X-Session-Token") != "" {
+
+		t.Errorf("Expected credentials to be dropped from the recording. Got: %+v", recording)
+	}
+
+	// Check large bodies are truncated
+	serve("/large")
+	recordings, _ = LoadRecordings(directory)
+
+	if last := recordings[len(recordings)-1]; !last.Truncated || len(last.ResponseBody) != maxRecordedBody {
+		t.Errorf("Expected a truncated body. Got: %d bytes", len(last.ResponseBody))
+	}
+
+	// Check only the most recent recordings are kept
+	h.SetMaxRecordings(2)
+	serve("/missing-1")
+	serve("/missing-2")
+
+	recordings, _ = LoadRecordings(directory)
+
+	if len(recordings) != 2 || recordings[0].URL != "/missing-1" || recordings[1].URL != "/missing-2" {
+		t.Errorf("Expected the two most recent recordings. Got: %+v", recordings)
+	}
+
+	// Check the chosen statuses are recorded
+	h.SetStatuses(http.StatusInternalServerError)
+	serve("/missing-3")
+
+	if entries, _ := os.ReadDir(directory); len(entries) != 2 {
+		t.Errorf("Expected a 404 not to be recorded. Got: %d recordings", len(entries))
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover returns a handler that calls next and recovers any panic in it,
+// serving the handler's error page with a 500 in place of the dropped
+// connection that net/http would otherwise leave the client with. Each panic
+// is logged with its value and stack trace, to the logger set with SetLogger
+// if the handler has one, or to the standard logger if not. The panic's value
+// is the error message, so it is only shown when errors are displayed, and the
+// stack trace is passed to the template when stack traces are captured. If the
+// response had already begun when the panic happened, it cannot be replaced
+// and is aborted instead. Panics with http.ErrAbortHandler, which are used to
+// abort a response deliberately, are not recovered.
+func (h *ErrorHandler) Recover(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		watcher := &headerWatcher{ResponseWriter: w}
+
+		defer func() {
+
+			v := recover()
+
+			if v == nil {
+				return
+			}
+
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+
+			stack := string(debug.Stack())
+			h.logPanic(r, v, stack)
+
+			if watcher.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+
+			trace := ""
+
+			if h.stackTraces && h.displayErrors {
+				trace = stack
+			}
+
+			message := fmt.Sprintf("panic: %v", v)
+			h.serveError(w, r, message, http.StatusInternalServerError, !h.displayErrors, trace)
+		}()
+
+		next.ServeHTTP(watcher, r)
+	})
+}
+
+// logPanic writes a recovered panic and its stack trace to the handler's
+// logger, or to the standard logger if it has none.
+func (h *ErrorHandler) logPanic(r *http.Request, v interface{}, stack string) {
+
+	if h.logger == nil {
+		log.Printf("handlers: panic serving %s %s: %v\n%s", r.Method, r.URL.Path, v, stack)
+		return
+	}
+
+	h.logger.LogAttrs(r.Context(), slog.LevelError, "handlers: recovered panic",
+		slog.String("panic", fmt.Sprint(v)),
+		slog.String("stack", stack),
+		slog.String("path", r.URL.Path),
+		slog.String("method", r.Method),
+		slog.String("remote_addr", r.RemoteAddr))
+}
+
+// headerWatcher passes a response through to the client, noting whether its
+// header has been written.
+type headerWatcher struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+// WriteHeader notes that the header has been sent before passing the status
+// on.
+func (w *headerWatcher) WriteHeader(status int) {
+
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Write notes that the header has been sent, as the first Write sends it,
+// before passing p on.
+func (w *headerWatcher) Write(p []byte) (int, error) {
+
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap returns the watched response writer, so that handlers below the
+// recovery can flush or hijack it with http.ResponseController.
+func (w *headerWatcher) Unwrap() http.ResponseWriter {
+
+	return w.ResponseWriter
+}
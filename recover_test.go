@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test ErrorHandler.Recover
+func TestErrorHandlerRecover(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+		logs     bytes.Buffer
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(
+		`{{.ErrorMessage}}|{{if .StackTrace}}trace{{end}}`))
+
+	panicking := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		panic("database unavailable")
+	})
+
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Error"),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	// Check the panic is served with the default message and logged
+	response = httptest.NewRecorder()
+	h.Recover(panicking).ServeHTTP(response, httptest.NewRequest("GET", "/page", nil))
+
+	if response.Code != http.StatusInternalServerError || response.Body.String() != "Error|" {
+		t.Errorf("Expected a 500 with the default message. Got: %d %q",
+			response.Code, response.Body.String())
+	}
+
+	if !strings.Contains(logs.String(), "recovered panic") ||
+		!strings.Contains(logs.String(), "database unavailable") ||
+		!strings.Contains(logs.String(), "goroutine") {
+		t.Errorf("Expected the panic and its stack to be logged. Got: %s", logs.String())
+	}
+
+	// Check the panic is shown with its stack trace when errors are displayed
+	h = NewErrorHandler(errorTemplate, WithDisplayErrors(true))
+	h.SetStackTraces(true)
+	response = httptest.NewRecorder()
+	h.Recover(panicking).ServeHTTP(response, httptest.NewRequest("GET", "/page", nil))
+
+	if body := response.Body.String(); body != "panic: database unavailable|trace" {
+		t.Errorf("Expected the panic and a stack trace. Got: %q", body)
+	}
+
+	// Check requests that do not panic are untouched
+	response = httptest.NewRecorder()
+	h.Recover(http.NotFoundHandler()).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	if response.Code != http.StatusNotFound {
+		t.Errorf("Expected the next handler's response. Got: %d", response.Code)
+	}
+
+	// Check started responses and deliberate aborts are aborted
+	tests := []http.HandlerFunc{
+		func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("partial"))
+			panic("failed")
+		},
+		func(w http.ResponseWriter, r *http.Request) {
+			panic(http.ErrAbortHandler)
+		},
+	}
+
+	for i, test := range tests {
+
+		func() {
+
+			defer func() {
+				if v := recover(); v != http.ErrAbortHandler {
+					t.Errorf("Expected test %d to abort the response. Got: %v", i, v)
+				}
+			}()
+
+			h.Recover(test).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+		}()
+	}
+}
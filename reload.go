@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"html/template"
+)
+
+// WithTemplateReloading sets whether the handler parses its template file
+// again for every error it serves, so that an error page can be redesigned
+// without restarting the server. Reloading only applies to handlers created
+// with LoadErrorHandler or MustLoadErrorHandler, which know the template's
+// file, and the functions given with WithFuncs remain available to the parsed
+// template. If the file cannot be parsed the error is answered with a 500 naming
+// the problem. As parsing on every request is slow, reloading is intended for
+// development only, and is off by default.
+func WithTemplateReloading(reload bool) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.reloadTemplate = reload
+	}
+}
+
+// currentTemplate returns the template to execute for an error with the given
+// status, parsing the template file again if the handler reloads its template.
+func (h *ErrorHandler) currentTemplate(status int) (*template.Template, error) {
+
+	if t, ok := h.statusTemplates[status]; ok {
+		return t, nil
+	}
+
+	if !h.reloadTemplate || h.templatePath == "" {
+		return h.template, nil
+	}
+
+	return parseTemplateFile(h.templatePath, h.funcs)
+}
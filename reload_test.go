@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Test ErrorHandler template reloading
+func TestTemplateReloading(t *testing.T) {
+
+	var (
+		response *httptest.ResponseRecorder
+	)
+
+	templatePath := filepath.Join(t.TempDir(), "error.html")
+	os.WriteFile(templatePath, []byte(`Version 1: {{.ErrorMessage}}`), 0644)
+
+	reloading := MustLoadErrorHandler(templatePath, WithDefaultMessage("Error"), WithTemplateReloading(true))
+	fixed := MustLoadErrorHandler(templatePath, WithDefaultMessage("Error"))
+
+	// Change the template after the handlers are created
+	os.WriteFile(templatePath, []byte(`Version 2: {{.ErrorMessage}}`), 0644)
+
+	tests := []struct {
+		h    *ErrorHandler
+		body string
+	}{
+		{reloading, "Version 2: Error"},
+		{fixed, "Version 1: Error"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the handler
+		response = httptest.NewRecorder()
+		test.h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+		// Check only the reloading handler uses the changed template
+		if response.Body.String() != test.body {
+			t.Errorf("Expected %q. Got: %q", test.body, response.Body.String())
+		}
+	}
+
+	// Check a template that cannot be parsed gives a 500 naming the problem
+	os.WriteFile(templatePath, []byte(`{{.ErrorMessage`), 0644)
+	response = httptest.NewRecorder()
+	reloading.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	if response.Code != http.StatusInternalServerError || response.Body.String() == "Version 2: Error" {
+		t.Errorf("Expected a 500 for a broken template. Got: %d %q", response.Code, response.Body.String())
+	}
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// rewritePlaceholderPattern matches the placeholders in a rule's target.
+var rewritePlaceholderPattern = regexp.MustCompile(`\$(\$|\{[^}]*\}|[a-zA-Z0-9_]+)`)
+
+// RuleCondition is a condition a request must meet, besides matching the
+// pattern, for a rewrite or redirect rule to apply to it. Conditions are only
+// evaluated for requests whose path matches the rule's pattern, so they add
+// nothing to the cost of other requests. Each condition names the request
+// headers it reads, and every response to a request whose path matches a
+// rule with conditions varies by them, so that a shared cache does not serve
+// the response chosen for one client to another.
+type RuleCondition struct {
+	match   func(r *http.Request) bool
+	headers []string
+}
+
+// NewRuleCondition returns a condition met by requests for which match
+// returns true. The headers are the names of the request headers that match
+// reads, such as "Accept-Language", which responses must vary by.
+func NewRuleCondition(match func(r *http.Request) bool, headers ...string) RuleCondition {
+
+	return RuleCondition{match: match, headers: headers}
+}
+
+// HeaderMatches returns a condition met by requests with a value of the named
+// header matching pattern, for example a User-Agent pattern identifying a
+// legacy app that needs its deep links redirected.
+func HeaderMatches(name string, pattern *regexp.Regexp) RuleCondition {
+
+	return NewRuleCondition(func(r *http.Request) bool {
+
+		for _, value := range r.Header.Values(name) {
+
+			if pattern.MatchString(value) {
+				return true
+			}
+		}
+
+		return false
+	}, name)
+}
+
+// HasCookie returns a condition met by requests carrying the named cookie.
+func HasCookie(name string) RuleCondition {
+
+	return NewRuleCondition(func(r *http.Request) bool {
+
+		_, err := r.Cookie(name)
+		return err == nil
+	}, "Cookie")
+}
+
+// MethodIs returns a condition met by requests with one of the given methods.
+// Caches already key responses by method, so it adds nothing to Vary.
+func MethodIs(methods ...string) RuleCondition {
+
+	return NewRuleCondition(func(r *http.Request) bool {
+
+		for _, method := range methods {
+
+			if r.Method == method {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+// rewriteRule maps request paths matching a pattern onto another path, either
+// internally or, if status is set, with a redirect. The rule only applies to
+// requests meeting all of its conditions.
+type rewriteRule struct {
+	pattern    string
+	target     string
+	prefix     bool
+	regexp     *regexp.Regexp
+	status     int
+	conditions []RuleCondition
+}
+
+// AddRewrite adds an internal rewrite rule, which serves requests for
+// pattern from target without redirecting the client, so legacy urls can be
+// mapped onto a new tree invisibly. Both are full url paths. If pattern ends
+// in "/" it matches every path under it, and the rest of the path is appended
+// to target, which should then also end in "/". Otherwise it matches only the
+// exact path. Rules are tried in the order they were added and the first
+// match is applied once, so rewrites never chain or loop. The target is then
+// served as if it had been requested, with the same checks, so a target that
+// is a directory without a trailing slash is still redirected. If conditions
+// are given the rule only applies to requests meeting all of them.
+func (h *FileHandler) AddRewrite(pattern string, target string, conditions ...RuleCondition) {
+
+	h.rewrites = append(h.rewrites, rewriteRule{
+		pattern:    pattern,
+		target:     target,
+		prefix:     strings.HasSuffix(pattern, "/"),
+		conditions: conditions,
+	})
+}
+
+// AddRewritePattern adds an internal rewrite rule whose pattern is a regular
+// expression, which must match the whole url path. The target can refer to
+// the pattern's capturing groups with placeholders like $1 or ${name}, so
+// "/blog/([0-9]+)/(.*)" with the target "/posts/$2" serves /blog/12/hello.html
+// from /posts/hello.html. Use ${1} rather than $1 when a placeholder is
+// followed by a letter, digit or underscore. An error is returned if the
+// pattern does not compile, the target does not start with "/", or the target
+// refers to a group the pattern does not have. Rules are otherwise applied as
+// described for AddRewrite, including any conditions.
+func (h *FileHandler) AddRewritePattern(pattern string, target string, conditions ...RuleCondition) error {
+
+	if !strings.HasPrefix(target, "/") {
+		return fmt.Errorf("handlers: rewrite rule %q: target %q is not a path", pattern, target)
+	}
+
+	return h.addPatternRule(pattern, target, 0, conditions)
+}
+
+// AddRedirectPattern adds a rule which redirects requests whose url path
+// matches pattern, a regular expression, to target with the given status,
+// which must be 301, 302, 303, 307 or 308. The target is a path or an
+// absolute url, and can use placeholders as described for AddRewritePattern.
+// The query string of the request is kept. Redirect and rewrite rules are
+// tried together in the order they were added. If conditions are given the
+// rule only applies to requests meeting all of them.
+func (h *FileHandler) AddRedirectPattern(pattern string, target string, status int,
+	conditions ...RuleCondition) error {
+
+	switch status {
+
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+
+	default:
+		return fmt.Errorf("handlers: redirect rule %q: %d is not a redirect status", pattern, status)
+	}
+
+	return h.addPatternRule(pattern, target, status, conditions)
+}
+
+// addPatternRule compiles and validates a rule with a regular expression.
+func (h *FileHandler) addPatternRule(pattern string, target string, status int,
+	conditions []RuleCondition) error {
+
+	compiled, err := regexp.Compile("^(?:" + pattern + ")$")
+
+	if err != nil {
+		return fmt.Errorf("handlers: rule %q: %s", pattern, err)
+	}
+
+	if err := checkPlaceholders(compiled, target); err != nil {
+		return fmt.Errorf("handlers: rule %q: %s", pattern, err)
+	}
+
+	h.rewrites = append(h.rewrites, rewriteRule{
+		pattern:    pattern,
+		target:     target,
+		regexp:     compiled,
+		status:     status,
+		conditions: conditions,
+	})
+
+	return nil
+}
+
+// checkPlaceholders checks that every placeholder in target names a group of
+// the compiled pattern.
+func checkPlaceholders(compiled *regexp.Regexp, target string) error {
+
+	names := make(map[string]bool)
+
+	for index, name := range compiled.SubexpNames() {
+
+		names[strconv.Itoa(index)] = true
+
+		if name != "" {
+			names[name] = true
+		}
+	}
+
+	for _, match := range rewritePlaceholderPattern.FindAllStringSubmatch(target, -1) {
+
+		name := strings.TrimSuffix(strings.TrimPrefix(match[1], "{"), "}")
+
+		if name != "$" && !names[name] {
+			return fmt.Errorf("target refers to unknown group %q", name)
+		}
+	}
+
+	return nil
+}
+
+// rewrite applies the first rule matching the request. It returns the
+// request with its path rewritten, or the request unchanged if no rule
+// matches, and true if it has redirected the request instead.
+func (h *FileHandler) rewrite(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+
+	for _, rule := range h.rewrites {
+
+		target, ok := rule.match(r.URL.Path)
+
+		if !ok || !rule.meetsConditions(w, r) {
+			continue
+		}
+
+		if rule.status != 0 {
+			setDebugHeader(w, r, "X-Debug-Rewrite",
+				fmt.Sprintf("%s -> %s (%d)", rule.pattern, target, rule.status))
+			h.redirectTo(w, r, target, rule.status)
+			return r, true
+		}
+
+		setDebugHeader(w, r, "X-Debug-Rewrite", rule.pattern+" -> "+target)
+
+		rewritten := r.Clone(r.Context())
+		rewritten.URL.Path = target
+		rewritten.URL.RawPath = ""
+		return rewritten, false
+	}
+
+	return r, false
+}
+
+// redirectTo redirects the request to target, a path or an absolute url.
+// Targets expanded from the request that the client could read as a url on
+// another host are answered with a 404, and paths are escaped so that their
+// characters cannot change the query.
+func (h *FileHandler) redirectTo(w http.ResponseWriter, r *http.Request, target string, status int) {
+
+	if !safeRedirectTarget(target) {
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if strings.HasPrefix(target, "/") {
+		escaped := (&url.URL{Path: target}).EscapedPath()
+		h.redirect(w, r, escaped, escaped, status)
+		return
+	}
+
+	if query := r.URL.RawQuery; query != "" {
+		target += "?" + query
+	}
+
+	w.Header().Set("Location", target)
+	w.WriteHeader(status)
+}
+
+// safeRedirectTarget reports whether a redirect target can be sent to the
+// client as it is. Paths must not start with two slashes or contain
+// backslashes, which browsers read as the start of another host, and no
+// target may contain control characters.
+func safeRedirectTarget(target string) bool {
+
+	for _, c := range target {
+
+		if c < 0x20 || c == 0x7f {
+			return false
+		}
+	}
+
+	if strings.HasPrefix(target, "/") {
+		return !strings.HasPrefix(target, "//") && !strings.Contains(target, "\\")
+	}
+
+	return true
+}
+
+// meetsConditions reports whether the request meets all of the rule's
+// conditions, first adding the headers the conditions read to the response's
+// Vary header, since the response depends on them whether or not they are met.
+func (rule rewriteRule) meetsConditions(w http.ResponseWriter, r *http.Request) bool {
+
+	for _, condition := range rule.conditions {
+
+		for _, header := range condition.headers {
+			addVary(w, http.CanonicalHeaderKey(header))
+		}
+	}
+
+	for _, condition := range rule.conditions {
+
+		if !condition.match(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// match returns the path the rule rewrites requestPath to, and whether the
+// rule matches it.
+func (rule rewriteRule) match(requestPath string) (string, bool) {
+
+	if rule.regexp != nil {
+
+		submatches := rule.regexp.FindStringSubmatchIndex(requestPath)
+
+		if submatches == nil {
+			return "", false
+		}
+
+		expanded := rule.regexp.ExpandString(nil, rule.target, requestPath, submatches)
+		return string(expanded), true
+	}
+
+	if rule.prefix {
+
+		if !strings.HasPrefix(requestPath, rule.pattern) {
+			return "", false
+		}
+
+		return rule.target + strings.TrimPrefix(requestPath, rule.pattern), true
+	}
+
+	if requestPath != rule.pattern {
+		return "", false
+	}
+
+	return rule.target, true
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler internal rewrites
+func TestRewrites(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	fileSystem := fstest.MapFS{
+		"posts/first.html": {Data: []byte("First post")},
+		"posts/index.html": {Data: []byte("Posts")},
+		"about/index.html": {Data: []byte("About")},
+	}
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fileSystem), nfh)
+	h.AddRewrite("/blog/", "/posts/")
+	h.AddRewrite("/about-us.html", "/about/")
+	h.AddRewrite("/escape/", "/posts/../")
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/blog/first.html", http.StatusOK, "First post"},
+		{"/blog/", http.StatusOK, "Posts"},
+		{"/about-us.html", http.StatusOK, "About"},
+		{"/about-us.html/", http.StatusNotFound, ""},
+		{"/posts/first.html", http.StatusOK, "First post"},
+		{"/escape/posts/first.html", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the path
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the rewritten path is served without a redirect
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+			continue
+		}
+
+		if test.body != "" && response.Body.String() != test.body {
+			t.Errorf("Expected %q for %s. Got: %q", test.body, test.path, response.Body.String())
+		}
+	}
+
+	// Check the original request is not modified
+	request, _ = http.NewRequest("GET", "/blog/first.html", nil)
+	h.ServeHTTP(httptest.NewRecorder(), request)
+
+	if request.URL.Path != "/blog/first.html" {
+		t.Errorf("Expected the request path to be unchanged. Got: %s", request.URL.Path)
+	}
+}
+
+// Test FileHandler rewrite and redirect rules with patterns
+func TestRewritePatterns(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	fileSystem := fstest.MapFS{
+		"posts/hello.html": {Data: []byte("Hello")},
+	}
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fileSystem), nfh)
+
+	// Check invalid rules are rejected when they are added
+	invalid := []error{
+		h.AddRewritePattern("/blog/([0-9]+", "/posts/$1"),
+		h.AddRewritePattern("/blog/(.*)", "/posts/$2"),
+		h.AddRewritePattern("/blog/(.*)", "/posts/$1x"),
+		h.AddRewritePattern("/blog/(.*)", "posts/$1"),
+		h.AddRedirectPattern("/old/(.*)", "/new/$1", http.StatusOK),
+	}
+
+	for i, err := range invalid {
+
+		if err == nil {
+			t.Errorf("Expected an error for invalid rule %d", i)
+		}
+	}
+
+	if len(h.rewrites) != 0 {
+		t.Fatalf("Expected no rules to be added. Got: %d", len(h.rewrites))
+	}
+
+	// Add valid rules
+	for _, err := range []error{
+		h.AddRewritePattern(`/blog/(\d+)/(.*)`, "/posts/$2"),
+		h.AddRewritePattern(`/articles/(?P<slug>[a-z]+)`, "/posts/${slug}.html"),
+		h.AddRedirectPattern(`/old/(.*)`, "/blog/1/$1", http.StatusMovedPermanently),
+		h.AddRedirectPattern(`/docs/(.*)`, "https://docs.example.com/$1", http.StatusFound),
+		h.AddRedirectPattern(`/go/(.*)`, "/$1", http.StatusFound),
+	} {
+
+		if err != nil {
+			t.Fatalf("Expected no error adding a valid rule. Got: %s", err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		status   int
+		body     string
+		location string
+	}{
+		{"/blog/12/hello.html", http.StatusOK, "Hello", ""},
+		{"/blog/x/hello.html", http.StatusNotFound, "", ""},
+		{"/articles/hello", http.StatusOK, "Hello", ""},
+		{"/old/hello.html?s=1", http.StatusMovedPermanently, "", "/blog/1/hello.html?s=1"},
+		{"/docs/guide", http.StatusFound, "", "https://docs.example.com/guide"},
+		{"/go//evil.example.com", http.StatusNotFound, "", ""},
+		{"/go/%5Cevil.example.com", http.StatusNotFound, "", ""},
+		{"/go/%5C%5Cevil.example.com", http.StatusNotFound, "", ""},
+		{"/go/a%0D%0ASet-Cookie:x", http.StatusNotFound, "", ""},
+		{"/go/a%3Fb", http.StatusFound, "", "/a%3Fb"},
+		{"/go/a%20b?s=1", http.StatusFound, "", "/a%20b?s=1"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the path
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check the rule is applied
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+			continue
+		}
+
+		if test.body != "" && response.Body.String() != test.body {
+			t.Errorf("Expected %q for %s. Got: %q", test.body, test.path, response.Body.String())
+		}
+
+		if location := response.Header().Get("Location"); location != test.location {
+			t.Errorf("Expected Location %q for %s. Got: %q", test.location, test.path, location)
+		}
+	}
+}
+
+// Test FileHandler rules with conditions
+func TestRewriteConditions(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	fileSystem := fstest.MapFS{
+		"app/item.html":  {Data: []byte("App item")},
+		"web/item.html":  {Data: []byte("Web item")},
+		"beta/item.html": {Data: []byte("Beta item")},
+	}
+
+	legacyApp := HeaderMatches("User-Agent", regexp.MustCompile(`^LegacyApp/[12]\.`))
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fileSystem), nfh)
+	h.AddRedirectPattern(`/item/(.*)`, "app://item/$1", http.StatusFound, legacyApp, MethodIs("GET", "HEAD"))
+	h.AddRewrite("/item/", "/beta/", HasCookie("beta"))
+	h.AddRewrite("/item/", "/web/")
+
+	tests := []struct {
+		method    string
+		userAgent string
+		cookie    string
+		status    int
+		body      string
+		location  string
+		vary      string
+	}{
+		{"GET", "LegacyApp/1.4", "", http.StatusFound, "", "app://item/item.html", "User-Agent"},
+		{"POST", "LegacyApp/1.4", "", http.StatusOK, "Web item", "", "User-Agent, Cookie"},
+		{"GET", "LegacyApp/3.0", "", http.StatusOK, "Web item", "", "User-Agent, Cookie"},
+		{"GET", "Mozilla/5.0", "beta", http.StatusOK, "Beta item", "", "User-Agent, Cookie"},
+		{"GET", "Mozilla/5.0", "", http.StatusOK, "Web item", "", "User-Agent, Cookie"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the request's headers and cookies
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest(test.method, "/item/item.html", nil)
+		request.Header.Set("User-Agent", test.userAgent)
+
+		if test.cookie != "" {
+			request.AddCookie(&http.Cookie{Name: test.cookie, Value: "1"})
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the first rule whose conditions are met is applied
+		if response.Code != test.status || response.Body.String() != test.body ||
+			response.Header().Get("Location") != test.location {
+
+			t.Errorf("Expected %d %q %q for %s %s %s. Got: %d %q %q", test.status, test.body,
+				test.location, test.method, test.userAgent, test.cookie, response.Code,
+				response.Body.String(), response.Header().Get("Location"))
+		}
+
+		// Check the response varies by the headers the conditions read
+		if vary := strings.Join(response.Header().Values("Vary"), ", "); vary != test.vary {
+			t.Errorf("Expected Vary %q for %s %s %s. Got: %q", test.vary, test.method,
+				test.userAgent, test.cookie, vary)
+		}
+	}
+
+	// Check responses to paths without conditional rules do not vary
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/web/item.html", nil)
+	h.ServeHTTP(response, request)
+
+	if vary := response.Header().Get("Vary"); vary != "" {
+		t.Errorf("Expected no Vary for a path without conditional rules. Got: %q", vary)
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PublishWindow is the time during which scheduled content is visible. A zero
+// Publish time means the content is visible from the start, and a zero Expire
+// time means it never expires.
+type PublishWindow struct {
+	Publish time.Time `json:"publish"`
+	Expire  time.Time `json:"expire"`
+}
+
+// PublishSchedule holds the publish windows of paths served by a FileHandler,
+// for content that is deployed ahead of an embargo or withdrawn at a set time.
+// Paths ending in "/" apply to everything under them, and the most specific
+// path that matches a request is used. Windows are evaluated on every request,
+// so no redeploy is needed when content is published or expires.
+type PublishSchedule struct {
+	mutex   sync.RWMutex
+	windows map[string]PublishWindow
+	clock   Clock
+}
+
+// NewPublishSchedule returns a new PublishSchedule with the given windows,
+// keyed by url path.
+func NewPublishSchedule(windows map[string]PublishWindow) *PublishSchedule {
+
+	schedule := &PublishSchedule{
+		windows: make(map[string]PublishWindow),
+		clock:   SystemClock,
+	}
+
+	for urlPath, window := range windows {
+		schedule.windows[urlPath] = window
+	}
+
+	return schedule
+}
+
+// LoadPublishSchedule returns a new PublishSchedule read from the JSON file at
+// schedulePath. The file holds an object mapping url paths to objects with
+// optional "publish" and "expire" times in RFC 3339 format.
+func LoadPublishSchedule(schedulePath string) (*PublishSchedule, error) {
+
+	contents, err := os.ReadFile(schedulePath)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var windows map[string]PublishWindow
+
+	if err = json.Unmarshal(contents, &windows); err != nil {
+		return nil, fmt.Errorf("handlers: invalid schedule in %s: %s", schedulePath, err)
+	}
+
+	return NewPublishSchedule(windows), nil
+}
+
+// Set sets the publish window of the given url path.
+func (s *PublishSchedule) Set(urlPath string, window PublishWindow) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.windows[urlPath] = window
+}
+
+// SetClock sets the clock the schedule uses to get the current time, so that
+// tests can check content before and after its publish and expiry times. By
+// default the schedule uses SystemClock.
+func (s *PublishSchedule) SetClock(clock Clock) {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.clock = clock
+}
+
+// Status returns the status of the content at the given url path: 0 if it is
+// visible, http.StatusNotFound if it is not yet published, or http.StatusGone
+// if it has expired.
+func (s *PublishSchedule) Status(urlPath string) int {
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	window, ok := s.window(urlPath)
+
+	if !ok {
+		return 0
+	}
+
+	now := s.clock.Now()
+
+	if !window.Publish.IsZero() && now.Before(window.Publish) {
+		return http.StatusNotFound
+	}
+
+	if !window.Expire.IsZero() && !now.Before(window.Expire) {
+		return http.StatusGone
+	}
+
+	return 0
+}
+
+// window returns the window of the most specific path matching urlPath.
+func (s *PublishSchedule) window(urlPath string) (PublishWindow, bool) {
+
+	var (
+		best    PublishWindow
+		bestLen int = -1
+	)
+
+	for candidate, window := range s.windows {
+
+		matches := candidate == urlPath ||
+			(strings.HasSuffix(candidate, "/") && strings.HasPrefix(urlPath, candidate))
+
+		if matches && len(candidate) > bestLen {
+			best, bestLen = window, len(candidate)
+		}
+	}
+
+	return best, bestLen >= 0
+}
+
+// SetPublishSchedule sets a schedule of publish windows for the files the
+// handler serves. Requests for content that is not yet published are served
+// with the handler's not found handler, and requests for content that has
+// expired are served with goneHandler, which should respond with a 410. If
+// goneHandler is nil a plain 410 response is used. Passing a nil schedule
+// turns scheduling off.
+func (h *FileHandler) SetPublishSchedule(schedule *PublishSchedule, goneHandler http.Handler) {
+
+	h.schedule = schedule
+	h.goneHandler = goneHandler
+}
+
+// serveScheduled serves the response for a request for unpublished or expired
+// content and returns true, or returns false if the content is visible.
+func (h *FileHandler) serveScheduled(w http.ResponseWriter, r *http.Request, filePath string) bool {
+
+	if h.schedule == nil {
+		return false
+	}
+
+	switch h.schedule.Status(h.urlPath[:len(h.urlPath)-1] + filePath) {
+
+	case http.StatusNotFound:
+
+		h.notFoundHandler.ServeHTTP(w, r)
+		return true
+
+	case http.StatusGone:
+
+		if h.goneHandler != nil {
+			h.goneHandler.ServeHTTP(w, r)
+			return true
+		}
+
+		http.Error(w, http.StatusText(http.StatusGone), http.StatusGone)
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test FileHandler publish schedules
+func TestPublishSchedule(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		schedule *PublishSchedule
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	fileSystem := fstest.MapFS{
+		"news/launch.html":  {Data: []byte("Launch")},
+		"news/old.html":     {Data: []byte("Old")},
+		"offers/today.html": {Data: []byte("Today")},
+	}
+
+	publish := time.Date(2030, 1, 1, 9, 0, 0, 0, time.UTC)
+	clock := NewFixedClock(publish)
+
+	schedule = NewPublishSchedule(map[string]PublishWindow{
+		"/news/launch.html": {Publish: publish},
+		"/news/old.html":    {Expire: publish},
+		"/offers/":          {Publish: publish, Expire: publish.Add(24 * time.Hour)},
+	})
+	schedule.SetClock(clock)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(fileSystem), nfh)
+	h.SetPublishSchedule(schedule, nil)
+
+	tests := []struct {
+		now    time.Time
+		path   string
+		status int
+	}{
+		{publish.Add(-time.Hour), "/news/launch.html", http.StatusNotFound},
+		{publish.Add(-time.Hour), "/news/old.html", http.StatusOK},
+		{publish.Add(-time.Hour), "/offers/today.html", http.StatusNotFound},
+		{publish, "/news/launch.html", http.StatusOK},
+		{publish, "/news/old.html", http.StatusGone},
+		{publish, "/offers/today.html", http.StatusOK},
+		{publish.Add(48 * time.Hour), "/offers/today.html", http.StatusGone},
+		{publish.Add(-time.Hour), "//news/launch.html", http.StatusNotFound},
+		{publish.Add(-time.Hour), "/./news/launch.html", http.StatusNotFound},
+		{publish.Add(-time.Hour), "/offers//today.html", http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the path at the given time
+		clock.Set(test.now)
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/", nil)
+		request.URL.Path = test.path
+		h.ServeHTTP(response, request)
+
+		// Check the status matches the schedule
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s at %s. Got: %d",
+				test.status, test.path, test.now, response.Code)
+		}
+	}
+
+	// Check other spellings of the path are hidden when case is ignored
+	h.SetCaseSensitivity(CaseInsensitive)
+	clock.Set(publish.Add(-time.Hour))
+
+	for _, path := range []string{"/NEWS/launch.html", "/news/Launch.HTML"} {
+
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", path, nil)
+		h.ServeHTTP(response, request)
+
+		if response.Code != http.StatusNotFound {
+			t.Errorf("Expected StatusNotFound for %s before publishing. Got: %d", path, response.Code)
+		}
+	}
+
+	// Test LoadPublishSchedule with a schedule file
+	schedulePath := filepath.Join(t.TempDir(), "schedule.json")
+	contents := `{"/news/": {"publish": "2030-01-01T09:00:00Z"}}`
+
+	if err := os.WriteFile(schedulePath, []byte(contents), 0644); err != nil {
+		t.Fatalf("Failed to write the schedule file: %s", err)
+	}
+
+	schedule, err := LoadPublishSchedule(schedulePath)
+
+	if err != nil {
+		t.Fatalf("Expected no error from LoadPublishSchedule. Got: %s", err)
+	}
+
+	schedule.SetClock(NewFixedClock(publish.Add(-time.Second)))
+
+	// Check the loaded schedule hides content before its publish time
+	if status := schedule.Status("/news/launch.html"); status != http.StatusNotFound {
+		t.Errorf("Expected StatusNotFound from the loaded schedule. Got: %d", status)
+	}
+}
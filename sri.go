@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SRIExtensions are the extensions of the files hashed by GenerateSRIManifest
+// if no extensions are given.
+var SRIExtensions = []string{".js", ".mjs", ".css"}
+
+// SRIManifest holds the Subresource Integrity hashes of the assets under a
+// url path. Templates can include the hashes in script and link tags with the
+// manifest's FuncMap, and a FileHandler can use the manifest to check that the
+// files it serves still match their hashes.
+type SRIManifest struct {
+	hashes   map[string]string
+	mutex    sync.Mutex
+	verified map[string]sriVerification
+}
+
+// sriVerification records the size and modification time of a file when its
+// hash was last checked, so unchanged files are not hashed on every request.
+type sriVerification struct {
+	size    int64
+	modTime time.Time
+}
+
+// GenerateSRIManifest returns a manifest of the SRI hashes of the files in
+// fileSystem with the given extensions, keyed by the url path at which each
+// file is served when the file system is mounted at urlPath. If no extensions
+// are given SRIExtensions are used. Hashes use SHA-384.
+func GenerateSRIManifest(fileSystem fs.FS, urlPath string, extensions ...string) (*SRIManifest, error) {
+
+	if len(extensions) == 0 {
+		extensions = SRIExtensions
+	}
+
+	manifest := &SRIManifest{
+		hashes:   make(map[string]string),
+		verified: make(map[string]sriVerification),
+	}
+
+	err := fs.WalkDir(fileSystem, ".", func(name string, entry fs.DirEntry, err error) error {
+
+		if err != nil || entry.IsDir() || !hasExtension(name, extensions) {
+			return err
+		}
+
+		file, err := fileSystem.Open(name)
+
+		if err != nil {
+			return err
+		}
+
+		defer file.Close()
+
+		hash, err := sriHash(file)
+
+		if err != nil {
+			return err
+		}
+
+		manifest.hashes[path.Join(urlPath, name)] = hash
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return manifest, nil
+}
+
+// Integrity returns the SRI hash of the asset at the given url path, or an
+// empty string if the asset is not in the manifest.
+func (m *SRIManifest) Integrity(urlPath string) string {
+
+	return m.hashes[urlPath]
+}
+
+// FuncMap returns a template.FuncMap containing an "integrity" function that
+// returns the SRI hash of the asset at the given url path, for use in tags
+// like <script src="/static/app.js" integrity="{{integrity "/static/app.js"}}">.
+func (m *SRIManifest) FuncMap() template.FuncMap {
+
+	return template.FuncMap{
+		"integrity": m.Integrity,
+	}
+}
+
+// WriteJSON writes the manifest to w as a JSON object mapping url paths to
+// hashes, for use by build tools and other servers.
+func (m *SRIManifest) WriteJSON(w io.Writer) error {
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(m.hashes)
+}
+
+// verify checks that the contents of the file served at urlPath still match
+// the hash in the manifest. Files not in the manifest always pass. The file is
+// left positioned at its start.
+func (m *SRIManifest) verify(urlPath string, file io.ReadSeeker, finfo os.FileInfo) error {
+
+	expected, ok := m.hashes[urlPath]
+
+	if !ok {
+		return nil
+	}
+
+	current := sriVerification{size: finfo.Size(), modTime: finfo.ModTime()}
+
+	m.mutex.Lock()
+	previous, checked := m.verified[urlPath]
+	m.mutex.Unlock()
+
+	if checked && previous.size == current.size && previous.modTime.Equal(current.modTime) {
+		return nil
+	}
+
+	hash, err := sriHash(file)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	if hash != expected {
+		return fmt.Errorf("handlers: %s does not match its integrity hash", urlPath)
+	}
+
+	m.mutex.Lock()
+	m.verified[urlPath] = current
+	m.mutex.Unlock()
+
+	return nil
+}
+
+// sriHash returns the SHA-384 SRI hash of everything read from reader.
+func sriHash(reader io.Reader) (string, error) {
+
+	hash := sha512.New384()
+
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	return "sha384-" + base64.StdEncoding.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hasExtension reports whether the name has one of the given extensions,
+// ignoring case.
+func hasExtension(name string, extensions []string) bool {
+
+	extension := strings.ToLower(path.Ext(name))
+
+	for _, candidate := range extensions {
+
+		if extension == strings.ToLower(candidate) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test GenerateSRIManifest and SRI verification in FileHandler
+func TestSRIManifest(t *testing.T) {
+
+	var (
+		manifest   *SRIManifest
+		fileSystem fstest.MapFS
+		h          *FileHandler
+		tmpl       *template.Template
+		buffer     bytes.Buffer
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+		err        error
+	)
+
+	// Generate a manifest for a script and a page
+	fileSystem = fstest.MapFS{
+		"js/app.js":  {Data: []byte("alert(1)"), ModTime: time.Unix(1, 0)},
+		"index.html": {Data: []byte("Index")},
+	}
+
+	manifest, err = GenerateSRIManifest(fileSystem, "/static/")
+
+	if err != nil {
+		t.Fatalf("Expected no error from GenerateSRIManifest. Got: %s", err)
+	}
+
+	// Check only the script is in the manifest
+	integrity := manifest.Integrity("/static/js/app.js")
+
+	if !strings.HasPrefix(integrity, "sha384-") || manifest.Integrity("/static/index.html") != "" {
+		t.Errorf("Expected a hash for the script only. Got: %v", manifest.hashes)
+	}
+
+	// Check the template function prints the hash in a script tag, where
+	// html/template escapes any "+" characters as entities
+	tmpl = template.Must(template.New("page").Funcs(manifest.FuncMap()).Parse(
+		`<script src="/static/js/app.js" integrity="{{integrity "/static/js/app.js"}}"></script>`))
+	tmpl.Execute(&buffer, nil)
+
+	if !strings.Contains(buffer.String(), strings.ReplaceAll(integrity, "+", "&#43;")) {
+		t.Errorf("Expected the hash in the template output. Got: %s", buffer.String())
+	}
+
+	// Test a FileHandler serves the unchanged script
+	nfh := MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/static/", http.FS(fileSystem), nfh)
+	h.SetIntegrityManifest(manifest)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/static/js/app.js", nil)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusOK || response.Body.String() != "alert(1)" {
+		t.Errorf("Expected StatusOK and the script from FileHandler. Got: %d %s",
+			response.Code, response.Body.String())
+	}
+
+	// Test a FileHandler refuses to serve a modified script
+	fileSystem["js/app.js"] = &fstest.MapFile{Data: []byte("alert(2)"), ModTime: time.Unix(2, 0)}
+
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusInternalServerError {
+		t.Errorf("Expected StatusInternalServerError from FileHandler. Got: %d",
+			response.Code)
+	}
+}
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"runtime/debug"
+)
+
+// SetStackTraces sets whether ServeError, ServeErrorWithStatus and
+// ServeErrorRequest capture the stack trace of the goroutine that called them
+// and pass it to the template, which can show it with the {{.StackTrace}}
+// tag. Stack traces are captured only when the handler displays errors, so
+// that they are never shown in production. By default they are not captured.
+func (h *ErrorHandler) SetStackTraces(capture bool) {
+
+	h.stackTraces = capture
+}
+
+// stackTrace returns the stack trace of the calling goroutine if the handler
+// captures stack traces, and an empty string otherwise.
+func (h *ErrorHandler) stackTrace() string {
+
+	if !h.stackTraces || !h.displayErrors {
+		return ""
+	}
+
+	return string(debug.Stack())
+}
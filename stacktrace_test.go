@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test ErrorHandler stack traces
+func TestErrorHandlerStackTraces(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+	)
+
+	errorTemplate := template.Must(template.New("error").Parse(`{{.ErrorMessage}}|{{.StackTrace}}`))
+
+	tests := []struct {
+		display bool
+		capture bool
+		traced  bool
+	}{
+		{false, false, false},
+		{true, false, false},
+		{false, true, false},
+		{true, true, true},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeError with the display and capture settings
+		h = NewErrorHandler(errorTemplate, WithDefaultMessage("Error"), WithDisplayErrors(test.display))
+		h.SetStackTraces(test.capture)
+		response = httptest.NewRecorder()
+		h.ServeError(response, "Detailed error")
+
+		// Check the stack trace names the caller only when captured
+		_, trace, _ := strings.Cut(response.Body.String(), "|")
+		traced := strings.Contains(trace, "TestErrorHandlerStackTraces")
+
+		if traced != test.traced {
+			t.Errorf("Expected traced %t with display %t and capture %t. Got: %q",
+				test.traced, test.display, test.capture, trace)
+		}
+	}
+
+	// Check AlwaysServeError does not capture a stack trace
+	h = NewErrorHandler(errorTemplate, WithDefaultMessage("Error"), WithDisplayErrors(true))
+	h.SetStackTraces(true)
+	response = httptest.NewRecorder()
+	h.AlwaysServeError(response, "Public error")
+
+	if body := response.Body.String(); body != "Public error|" {
+		t.Errorf("Expected no stack trace from AlwaysServeError. Got: %q", body)
+	}
+}
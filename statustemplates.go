@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"html/template"
+)
+
+// SetStatusTemplate sets the template used for errors served with the given
+// status code, such as a 503 page explaining planned maintenance, in place of
+// the handler's template, which remains the fallback for other statuses. The
+// template is given the same data as the handler's template. A nil template
+// removes the status's template. Status templates are not reloaded by
+// WithTemplateReloading.
+func (h *ErrorHandler) SetStatusTemplate(status int, t *template.Template) {
+
+	if t == nil {
+		delete(h.statusTemplates, status)
+		return
+	}
+
+	if h.statusTemplates == nil {
+		h.statusTemplates = make(map[int]*template.Template)
+	}
+
+	h.statusTemplates[status] = t
+}
+
+// WithStatusTemplate sets the template used for errors served with the given
+// status code, as SetStatusTemplate does.
+func WithStatusTemplate(status int, t *template.Template) ErrorOption {
+
+	return func(h *ErrorHandler) {
+		h.SetStatusTemplate(status, t)
+	}
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// Test ErrorHandler status templates
+func TestStatusTemplates(t *testing.T) {
+
+	var (
+		h        *ErrorHandler
+		response *httptest.ResponseRecorder
+	)
+
+	fallback := template.Must(template.New("error").Parse(`Error: {{.ErrorMessage}}`))
+	maintenance := template.Must(template.New("503").Parse(`Maintenance: {{.ErrorMessage}}`))
+	gateway := template.Must(template.New("502").Parse(`Bad gateway: {{.ErrorMessage}}`))
+
+	h = NewErrorHandler(fallback, WithDefaultMessage("Sorry"),
+		WithStatusTemplate(http.StatusServiceUnavailable, maintenance))
+	h.SetStatusTemplate(http.StatusBadGateway, gateway)
+
+	tests := []struct {
+		status int
+		body   string
+	}{
+		{http.StatusInternalServerError, "Error: Sorry"},
+		{http.StatusBadGateway, "Bad gateway: Sorry"},
+		{http.StatusServiceUnavailable, "Maintenance: Sorry"},
+		{http.StatusGatewayTimeout, "Error: Sorry"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeErrorWithStatus with the status
+		response = httptest.NewRecorder()
+		h.ServeErrorWithStatus(response, "Detailed error", test.status)
+
+		// Check the status's template is used, or the fallback
+		if response.Code != test.status || response.Body.String() != test.body {
+			t.Errorf("Expected %d %q. Got: %d %q", test.status, test.body,
+				response.Code, response.Body.String())
+		}
+	}
+
+	// Check the default status picks its template too
+	h.SetDefaultStatus(http.StatusServiceUnavailable)
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	if body := response.Body.String(); body != "Maintenance: Sorry" {
+		t.Errorf("Expected the 503 template for the default status. Got: %q", body)
+	}
+
+	// Check a removed template falls back
+	h.SetStatusTemplate(http.StatusServiceUnavailable, nil)
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+
+	if body := response.Body.String(); body != "Error: Sorry" {
+		t.Errorf("Expected the fallback template after removal. Got: %q", body)
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// svgUnsafeElements are the elements removed, with their contents, by
+// SanitizeSVG. They can run scripts or embed other documents.
+var svgUnsafeElements = map[string]bool{
+	"script": true, "foreignobject": true, "iframe": true, "embed": true,
+	"object": true, "handler": true, "listener": true,
+}
+
+// svgAnimationElements are the elements that can change the value of another
+// element's attribute, which SanitizeSVG removes if they change a link.
+var svgAnimationElements = map[string]bool{
+	"set": true, "animate": true, "animatemotion": true, "animatetransform": true,
+}
+
+// svgURLPattern matches the urls referenced in CSS and presentation attributes.
+var svgURLPattern = regexp.MustCompile(`(?i)url\s*\(\s*['"]?\s*([^'")\s]*)`)
+
+// svgDataImagePattern matches the data urls of raster images, which cannot
+// run scripts and are often embedded in exported images.
+var svgDataImagePattern = regexp.MustCompile(`(?i)^data:image/(png|jpeg|gif|webp)[;,]`)
+
+// svgEscaper escapes text and attribute values written by SanitizeSVG.
+var svgEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// SetSanitizeSVG controls whether the handler sanitizes SVG images with
+// SanitizeSVG as it serves them, so that images supplied by users cannot run
+// scripts or load content from other sites when they are opened directly.
+// Sanitized images are cached in memory until the file changes, and images
+// the handler cannot parse are answered with a 500 rather than served as
+// they are. Applications that accept SVG uploads can also call SanitizeSVG
+// when the image is uploaded. By default images are served unchanged.
+func (h *FileHandler) SetSanitizeSVG(sanitize bool) {
+
+	if !sanitize {
+		h.sanitizedSVGs = nil
+		return
+	}
+
+	h.sanitizedSVGs = newStrippedImageCache(func(filePath string, contents []byte) ([]byte, error) {
+
+		return SanitizeSVG(contents)
+	})
+}
+
+// SanitizeSVG returns the SVG image with the content that could run scripts
+// or load external resources removed. Script, foreignObject and other
+// embedding elements are removed with their contents, as are animations that
+// change a link. Event handler attributes, such as onload, are removed, and
+// so are links, url() references and style sheets that point anywhere but to
+// a fragment of the image itself or an embedded raster image. Comments,
+// processing instructions other than the XML declaration, and document type
+// declarations are dropped. The result is well-formed XML. An error is
+// returned if the image is not well-formed XML.
+func SanitizeSVG(contents []byte) ([]byte, error) {
+
+	var (
+		output bytes.Buffer
+		open   []xml.Name
+		skip   int
+	)
+
+	decoder := xml.NewDecoder(bytes.NewReader(contents))
+	decoder.Entity = xml.HTMLEntity
+
+	for {
+
+		token, err := decoder.RawToken()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		switch token := token.(type) {
+
+		case xml.StartElement:
+
+			if skip > 0 || !safeSVGElement(token) {
+				skip++
+				continue
+			}
+
+			open = append(open, token.Name)
+			output.WriteString("<" + qualifiedName(token.Name))
+
+			for _, attr := range token.Attr {
+
+				if !safeSVGAttr(attr) {
+					continue
+				}
+
+				output.WriteString(" " + qualifiedName(attr.Name) + `="` +
+					svgEscaper.Replace(attr.Value) + `"`)
+			}
+
+			output.WriteString(">")
+
+		case xml.EndElement:
+
+			if skip > 0 {
+				skip--
+				continue
+			}
+
+			if len(open) == 0 || open[len(open)-1] != token.Name {
+				return nil, errors.New("handlers: malformed SVG image")
+			}
+
+			open = open[:len(open)-1]
+			output.WriteString("</" + qualifiedName(token.Name) + ">")
+
+		case xml.CharData:
+
+			if skip > 0 {
+				continue
+			}
+
+			// Drop style sheets that load other resources
+			if len(open) > 0 && strings.EqualFold(open[len(open)-1].Local, "style") &&
+				!safeCSS(string(token)) {
+
+				continue
+			}
+
+			output.WriteString(svgEscaper.Replace(string(token)))
+
+		case xml.ProcInst:
+
+			if token.Target == "xml" && output.Len() == 0 {
+				output.WriteString("<?xml " + string(token.Inst) + "?>")
+			}
+		}
+	}
+
+	if len(open) != 0 || skip != 0 {
+		return nil, errors.New("handlers: malformed SVG image")
+	}
+
+	return output.Bytes(), nil
+}
+
+// safeSVGElement reports whether the element can be kept in a sanitized image.
+func safeSVGElement(element xml.StartElement) bool {
+
+	local := strings.ToLower(element.Name.Local)
+
+	if svgUnsafeElements[local] {
+		return false
+	}
+
+	if svgAnimationElements[local] {
+
+		for _, attr := range element.Attr {
+
+			if strings.EqualFold(attr.Name.Local, "attributeName") &&
+				strings.HasSuffix(strings.ToLower(attr.Value), "href") {
+
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// safeSVGAttr reports whether the attribute can be kept in a sanitized image.
+func safeSVGAttr(attr xml.Attr) bool {
+
+	local := strings.ToLower(attr.Name.Local)
+
+	// Event handlers run scripts
+	if strings.HasPrefix(local, "on") {
+		return false
+	}
+
+	// Links may only point to fragments of the image or embedded images
+	if local == "href" || local == "src" {
+		return safeSVGReference(attr.Value)
+	}
+
+	return safeCSS(attr.Value)
+}
+
+// safeCSS reports whether a style sheet or attribute value only references
+// fragments of the image, and imports nothing.
+func safeCSS(value string) bool {
+
+	if strings.Contains(strings.ToLower(value), "@import") {
+		return false
+	}
+
+	for _, match := range svgURLPattern.FindAllStringSubmatch(value, -1) {
+
+		if !safeSVGReference(match[1]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// safeSVGReference reports whether a url refers to a fragment of the image or
+// is the data url of a raster image.
+func safeSVGReference(url string) bool {
+
+	url = strings.TrimSpace(url)
+	return strings.HasPrefix(url, "#") || svgDataImagePattern.MatchString(url)
+}
+
+// qualifiedName returns the name with its namespace prefix, as read by
+// xml.Decoder.RawToken.
+func qualifiedName(name xml.Name) string {
+
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
+
+// isSVG reports whether the named file is an SVG image.
+func isSVG(name string) bool {
+
+	return strings.ToLower(path.Ext(name)) == ".svg"
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+// Test SanitizeSVG
+func TestSanitizeSVG(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`<?xml version="1.0"?><!DOCTYPE svg><svg xmlns="http://www.w3.org/2000/svg"><circle r="5"/></svg>`,
+			`<?xml version="1.0"?><svg xmlns="http://www.w3.org/2000/svg"><circle r="5"></circle></svg>`,
+		},
+		{
+			`<svg onload="alert(1)"><script>alert(2)</script><g><SCRIPT><![CDATA[alert(3)]]></SCRIPT></g></svg>`,
+			`<svg><g></g></svg>`,
+		},
+		{
+			`<svg><foreignObject><iframe src="https://example.com/"></iframe></foreignObject><text>A &amp; B</text></svg>`,
+			`<svg><text>A &amp; B</text></svg>`,
+		},
+		{
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#icon"/><use href="https://example.com/sprite.svg#icon"/></svg>`,
+			`<svg xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#icon"></use><use></use></svg>`,
+		},
+		{
+			`<svg><a href=" javascript:alert(1)"><text>Link</text></a><image href="data:image/png;base64,AAAA"/></svg>`,
+			`<svg><a><text>Link</text></a><image href="data:image/png;base64,AAAA"></image></svg>`,
+		},
+		{
+			`<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="opacity" to="0"/></a></svg>`,
+			`<svg><a><animate attributeName="opacity" to="0"></animate></a></svg>`,
+		},
+		{
+			`<svg><rect fill="url(#gradient)" style="fill: url('https://example.com/track')"/></svg>`,
+			`<svg><rect fill="url(#gradient)"></rect></svg>`,
+		},
+		{
+			`<svg><style>@import url(https://example.com/a.css);</style><style>a > b { fill: red }</style><!-- note --></svg>`,
+			`<svg><style></style><style>a &gt; b { fill: red }</style></svg>`,
+		},
+	}
+
+	for _, test := range tests {
+
+		// Test SanitizeSVG on the image
+		output, err := SanitizeSVG([]byte(test.input))
+
+		if err != nil {
+			t.Errorf("Expected no error for %s. Got: %s", test.input, err)
+			continue
+		}
+
+		// Check unsafe content is removed and the rest is kept
+		if string(output) != test.expected {
+			t.Errorf("Expected %s for %s. Got: %s", test.expected, test.input, output)
+		}
+	}
+
+	// Check malformed images are refused
+	for _, input := range []string{`<svg><g></svg>`, `<svg>`, `<svg>&unknown;</svg>`} {
+
+		if _, err := SanitizeSVG([]byte(input)); err == nil {
+			t.Errorf("Expected an error for %s", input)
+		}
+	}
+}
+
+// Test FileHandler SVG sanitization
+func TestFileHandlerSanitizeSVG(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+		modTime  time.Time = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	files := fstest.MapFS{
+		"logo.svg":   {Data: []byte(`<svg onload="alert(1)"><circle r="5"/></svg>`), ModTime: modTime},
+		"broken.svg": {Data: []byte(`<svg><g></svg>`), ModTime: modTime},
+	}
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/", http.FS(files), nfh)
+
+	// Check images are served unchanged by default
+	if body := string(serveBody(h, "/logo.svg")); body != string(files["logo.svg"].Data) {
+		t.Errorf("Expected the image unchanged by default. Got: %s", body)
+	}
+
+	h.SetSanitizeSVG(true)
+
+	tests := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/logo.svg", http.StatusOK, `<svg><circle r="5"></circle></svg>`},
+		{"/broken.svg", http.StatusInternalServerError, ""},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the image
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check sanitized images are served and malformed images are not
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+
+		if test.body != "" && response.Body.String() != test.body {
+			t.Errorf("Expected %s for %s. Got: %s", test.body, test.path, response.Body.String())
+		}
+	}
+
+	// Check a changed image is sanitized again
+	files["logo.svg"] = &fstest.MapFile{Data: []byte(`<svg><rect/></svg>`), ModTime: modTime.Add(time.Hour)}
+
+	if body := string(serveBody(h, "/logo.svg")); body != `<svg><rect></rect></svg>` {
+		t.Errorf("Expected the changed image. Got: %s", body)
+	}
+}
@@ -0,0 +1,474 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SwapFileSystem is an http.FileSystem whose contents can be replaced
+// atomically, so that a whole new version of a site can be promoted at once.
+// Requests started before a swap finish with the files they opened.
+type SwapFileSystem struct {
+	current atomic.Value
+}
+
+// swapRoot holds the file system currently served by a SwapFileSystem, so
+// that atomic.Value always stores the same concrete type.
+type swapRoot struct {
+	fileSystem http.FileSystem
+}
+
+// NewSwapFileSystem returns a new SwapFileSystem serving fileSystem.
+func NewSwapFileSystem(fileSystem http.FileSystem) *SwapFileSystem {
+
+	fs := &SwapFileSystem{}
+	fs.Swap(fileSystem)
+	return fs
+}
+
+// Swap replaces the served file system.
+func (fs *SwapFileSystem) Swap(fileSystem http.FileSystem) {
+
+	fs.current.Store(swapRoot{fileSystem: fileSystem})
+}
+
+// Open opens the named file in the current file system.
+func (fs *SwapFileSystem) Open(name string) (http.File, error) {
+
+	return fs.current.Load().(swapRoot).fileSystem.Open(name)
+}
+
+// SyncSource copies a version of a site into directory, which is new and
+// empty. Sources should stop when ctx is cancelled, and can write a log of
+// their work, such as a build's output, to SyncOutput(ctx).
+type SyncSource func(ctx context.Context, directory string) error
+
+// maxSyncOutput is the maximum number of bytes of a sync's output kept in the
+// syncer's status.
+const maxSyncOutput int = 64 * 1024
+
+// syncOutputContextKey is the context key for the output of a sync.
+type syncOutputContextKey struct{}
+
+// SyncOutput returns the writer for the output of the sync running with ctx,
+// which is reported in the syncer's status. If ctx does not belong to a sync
+// the output is discarded.
+func SyncOutput(ctx context.Context) io.Writer {
+
+	if output, ok := ctx.Value(syncOutputContextKey{}).(io.Writer); ok {
+		return output
+	}
+
+	return io.Discard
+}
+
+// CommandSource returns a SyncSource that builds a version of a site by
+// running an external command, such as a static site generator, in the
+// directory dir. Arguments equal to "{output}" are replaced with the
+// directory the version must be written to, which is also passed in the
+// HANDLERS_OUTPUT environment variable. For example,
+// CommandSource("/srv/site", "hugo", "--destination", "{output}"). The
+// command's output is reported in the status returned by the syncer's Status
+// method.
+func CommandSource(dir string, name string, args ...string) SyncSource {
+
+	return func(ctx context.Context, directory string) error {
+
+		expanded := make([]string, len(args))
+
+		for i, arg := range args {
+
+			if arg == "{output}" {
+				arg = directory
+			}
+
+			expanded[i] = arg
+		}
+
+		command := exec.CommandContext(ctx, name, expanded...)
+		command.Dir = dir
+		command.Env = append(os.Environ(), "HANDLERS_OUTPUT="+directory)
+		command.Stdout = SyncOutput(ctx)
+		command.Stderr = SyncOutput(ctx)
+
+		if err := command.Run(); err != nil {
+			return fmt.Errorf("handlers: %s failed: %s", name, err)
+		}
+
+		return nil
+	}
+}
+
+// RsyncSource returns a SyncSource that copies the contents of an rsync
+// source, such as "deploy@build.example.com:/srv/site/". The rsync command
+// must be installed.
+func RsyncSource(source string) SyncSource {
+
+	return func(ctx context.Context, directory string) error {
+
+		return runSyncCommand(ctx, "rsync", "--recursive", "--links", "--times",
+			"--delete", "--", strings.TrimSuffix(source, "/")+"/", directory+"/")
+	}
+}
+
+// GitSource returns a SyncSource that copies the files of a git repository at
+// the given branch or tag. The git command must be installed.
+func GitSource(repository string, ref string) SyncSource {
+
+	return func(ctx context.Context, directory string) error {
+
+		err := runSyncCommand(ctx, "git", "clone", "--quiet", "--depth", "1",
+			"--branch", ref, "--", repository, directory)
+
+		if err != nil {
+			return err
+		}
+
+		return os.RemoveAll(filepath.Join(directory, ".git"))
+	}
+}
+
+// runSyncCommand runs a command for a SyncSource, writing its output to the
+// sync's output.
+func runSyncCommand(ctx context.Context, name string, args ...string) error {
+
+	command := exec.CommandContext(ctx, name, args...)
+	command.Stdout = SyncOutput(ctx)
+	command.Stderr = SyncOutput(ctx)
+
+	if err := command.Run(); err != nil {
+		return fmt.Errorf("handlers: %s failed: %s", name, err)
+	}
+
+	return nil
+}
+
+// SyncManifestName is the name of the file in a synced site holding the SRI
+// hashes its files must match, as written by SRIManifest.WriteJSON with the
+// url path "/". If a synced version contains this file, every file it lists
+// is checked before the version is promoted.
+const SyncManifestName string = ".sri-manifest.json"
+
+// SyncStatus describes the state of a Syncer.
+type SyncStatus struct {
+	Version    string    `json:"version"`
+	LastSync   time.Time `json:"lastSync"`
+	LastError  string    `json:"lastError,omitempty"`
+	LastFailed time.Time `json:"lastFailed,omitempty"`
+	Running    bool      `json:"running"`
+	Output     string    `json:"output,omitempty"`
+}
+
+// Syncer periodically pulls a site from a SyncSource and promotes it to a
+// SwapFileSystem. Each version is copied into a new directory under the
+// staging directory and verified, and the file system is only swapped to it
+// if the copy and verification succeed, so a failed sync never leaves a site
+// partly updated. The previous version is kept for requests still reading
+// from it, and older versions are removed.
+type Syncer struct {
+	source     SyncSource
+	staging    string
+	target     *SwapFileSystem
+	clock      Clock
+	timeout    time.Duration
+	mutex      sync.Mutex
+	status     SyncStatus
+	versions   []string
+	syncMutex  sync.Mutex
+	done       chan struct{}
+	closeOnce  sync.Once
+	generation int64
+	queued     bool
+}
+
+// NewSyncer returns a new Syncer which copies versions from source into
+// directories under staging and promotes them to target.
+func NewSyncer(source SyncSource, staging string, target *SwapFileSystem) *Syncer {
+
+	return &Syncer{
+		source:  source,
+		staging: staging,
+		target:  target,
+		clock:   SystemClock,
+		timeout: 10 * time.Minute,
+		done:    make(chan struct{}),
+	}
+}
+
+// SetClock sets the clock used to name versions and record sync times. By
+// default the syncer uses SystemClock.
+func (s *Syncer) SetClock(clock Clock) {
+
+	s.clock = clock
+}
+
+// Start syncs every interval in the background until the syncer is closed.
+func (s *Syncer) Start(interval time.Duration) {
+
+	go func() {
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+
+			select {
+
+			case <-ticker.C:
+				s.Sync()
+
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops the background syncs.
+func (s *Syncer) Close() error {
+
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	return nil
+}
+
+// Sync copies, verifies and promotes a new version now. The output of the
+// sync is kept in the syncer's status.
+func (s *Syncer) Sync() error {
+
+	s.syncMutex.Lock()
+	defer s.syncMutex.Unlock()
+
+	s.mutex.Lock()
+	s.status.Running = true
+	s.mutex.Unlock()
+
+	output := &limitedBuffer{limit: maxSyncOutput}
+	err := s.sync(output)
+	now := s.clock.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.status.Running = false
+	s.status.Output = output.String()
+
+	if err != nil {
+		s.status.LastError = err.Error()
+		s.status.LastFailed = now
+		return err
+	}
+
+	s.status.LastError = ""
+	s.status.LastSync = now
+	return nil
+}
+
+// WebhookHandler returns a handler for build hooks and push webhooks, which
+// starts a sync in the background when it receives a POST request and
+// responds with a 202. If a sync is already running another is started when
+// it finishes. The request must carry an X-Hub-Signature-256 header with the
+// HMAC-SHA256 of its body with secret, and requests without a valid signature
+// get a 401. The result is reported in the syncer's status. WebhookHandler
+// panics if secret is empty, as the hook would otherwise let anyone trigger
+// syncs.
+func (s *Syncer) WebhookHandler(secret []byte) http.Handler {
+
+	requireWebhookSecret(secret)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if !acceptWebhook(w, r, secret) {
+			return
+		}
+
+		s.mutex.Lock()
+		queued := s.queued
+		s.queued = true
+		s.mutex.Unlock()
+
+		if !queued {
+
+			go func() {
+
+				s.syncMutex.Lock()
+				s.mutex.Lock()
+				s.queued = false
+				s.mutex.Unlock()
+				s.syncMutex.Unlock()
+
+				s.Sync()
+			}()
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+// sync copies, verifies and promotes a new version, writing the source's
+// output to output.
+func (s *Syncer) sync(output io.Writer) error {
+
+	s.generation++
+	version := fmt.Sprintf("%s-%d", s.clock.Now().UTC().Format("20060102T150405Z"), s.generation)
+	directory := filepath.Join(s.staging, version)
+
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	ctx = context.WithValue(ctx, syncOutputContextKey{}, output)
+
+	err := s.source(ctx, directory)
+
+	if err == nil {
+		err = verifySyncManifest(directory)
+	}
+
+	if err != nil {
+		os.RemoveAll(directory)
+		return err
+	}
+
+	s.target.Swap(http.Dir(directory))
+
+	s.mutex.Lock()
+	s.status.Version = version
+	s.versions = append(s.versions, directory)
+	var expired []string
+
+	// Keep the new version and the one before it
+	if len(s.versions) > 2 {
+		expired = s.versions[:len(s.versions)-2]
+		s.versions = s.versions[len(s.versions)-2:]
+	}
+
+	s.mutex.Unlock()
+
+	for _, old := range expired {
+		os.RemoveAll(old)
+	}
+
+	return nil
+}
+
+// Status returns the syncer's current status.
+func (s *Syncer) Status() SyncStatus {
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.status
+}
+
+// ServeHTTP serves the syncer's status as JSON, for monitoring. The status is
+// served without its output, which can hold anything a build prints, such as
+// paths, environment details and secrets; read it with Status instead.
+func (s *Syncer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	status := s.Status()
+	status.Output = ""
+
+	setServerHeader(w)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(status)
+}
+
+// verifySyncManifest checks the files in directory against the manifest in
+// it, if there is one.
+func verifySyncManifest(directory string) error {
+
+	contents, err := os.ReadFile(filepath.Join(directory, SyncManifestName))
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var hashes map[string]string
+
+	if err := json.Unmarshal(contents, &hashes); err != nil {
+		return fmt.Errorf("handlers: invalid sync manifest: %s", err)
+	}
+
+	root := http.Dir(directory)
+
+	for urlPath, expected := range hashes {
+
+		file, err := root.Open(urlPath)
+
+		if err != nil {
+			return fmt.Errorf("handlers: %s is in the sync manifest but missing", urlPath)
+		}
+
+		hash, err := sriHash(file)
+		file.Close()
+
+		if err != nil {
+			return err
+		}
+
+		if hash != expected {
+			return fmt.Errorf("handlers: %s does not match the sync manifest", urlPath)
+		}
+	}
+
+	return nil
+}
+
+// limitedBuffer keeps up to limit bytes of what is written to it, dropping
+// the rest. It is safe for concurrent use, as a command's stdout and stderr
+// may be written at once.
+type limitedBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+	limit  int
+}
+
+// Write keeps as much of p as fits within the limit. It always reports all
+// of p as written, so that a command with a lot of output is not stopped.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if remaining := b.limit - b.buffer.Len(); remaining > 0 {
+
+		if len(p) > remaining {
+			b.buffer.Write(p[:remaining])
+		} else {
+			b.buffer.Write(p)
+		}
+	}
+
+	return len(p), nil
+}
+
+// String returns the output kept so far.
+func (b *limitedBuffer) String() string {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
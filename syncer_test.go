@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Test the Syncer with a function source
+func TestSyncer(t *testing.T) {
+
+	var (
+		contents string = "Version 1"
+		manifest bool
+		fail     bool
+	)
+
+	source := func(ctx context.Context, directory string) error {
+
+		if fail {
+			return errors.New("source unavailable")
+		}
+
+		os.WriteFile(filepath.Join(directory, "index.html"), []byte(contents), 0644)
+
+		if manifest {
+
+			hash, _ := sriHash(strings.NewReader("Version 1"))
+			encoded, _ := json.Marshal(map[string]string{"/index.html": hash})
+			os.WriteFile(filepath.Join(directory, SyncManifestName), encoded, 0644)
+		}
+
+		return nil
+	}
+
+	staging := t.TempDir()
+	target := NewSwapFileSystem(http.Dir(t.TempDir()))
+	syncer := NewSyncer(source, staging, target)
+	syncer.SetClock(NewFixedClock(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	defer syncer.Close()
+
+	read := func() string {
+
+		file, err := target.Open("/index.html")
+
+		if err != nil {
+			return ""
+		}
+
+		defer file.Close()
+		body, _ := io.ReadAll(file)
+		return string(body)
+	}
+
+	// Check a sync promotes the new version
+	if err := syncer.Sync(); err != nil {
+		t.Fatalf("Expected no error from Sync. Got: %s", err)
+	}
+
+	if body := read(); body != "Version 1" {
+		t.Errorf("Expected \"Version 1\" after the first sync. Got: %q", body)
+	}
+
+	// Check a failed sync keeps the current version and reports the error
+	fail = true
+
+	if err := syncer.Sync(); err == nil {
+		t.Errorf("Expected an error from Sync for a failed source")
+	}
+
+	if body := read(); body != "Version 1" {
+		t.Errorf("Expected \"Version 1\" after a failed sync. Got: %q", body)
+	}
+
+	if status := syncer.Status(); status.LastError == "" {
+		t.Errorf("Expected the error in the status. Got: %+v", status)
+	}
+
+	// Check a version that does not match its manifest is not promoted
+	fail, manifest, contents = false, true, "Tampered"
+
+	if err := syncer.Sync(); err == nil {
+		t.Errorf("Expected an error from Sync for a manifest mismatch")
+	}
+
+	if body := read(); body != "Version 1" {
+		t.Errorf("Expected \"Version 1\" after a manifest mismatch. Got: %q", body)
+	}
+
+	// Check a version that matches its manifest is promoted
+	contents = "Version 1"
+
+	if err := syncer.Sync(); err != nil {
+		t.Errorf("Expected no error from Sync for a matching manifest. Got: %s", err)
+	}
+
+	// Check only the current and previous versions are kept
+	syncer.Sync()
+	entries, _ := os.ReadDir(staging)
+
+	if len(entries) != 2 {
+		t.Errorf("Expected 2 versions in the staging directory. Got: %d", len(entries))
+	}
+
+	// Check the status is served as JSON
+	response := httptest.NewRecorder()
+	syncer.ServeHTTP(response, httptest.NewRequest("GET", "/sync", nil))
+
+	var status SyncStatus
+
+	if err := json.NewDecoder(bytes.NewReader(response.Body.Bytes())).Decode(&status); err != nil ||
+		status.Version == "" || status.LastError != "" {
+
+		t.Errorf("Expected the current version in the status. Got: %s", response.Body.String())
+	}
+}
+
+// Test the GitSource
+func TestGitSource(t *testing.T) {
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	work := t.TempDir()
+	os.WriteFile(filepath.Join(work, "index.html"), []byte("From git"), 0644)
+
+	for _, args := range [][]string{
+		{"init", "--quiet", "--initial-branch=main"},
+		{"add", "."},
+		{"-c", "user.name=Test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "Site"},
+	} {
+
+		if output, err := exec.Command("git", append([]string{"-C", work}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git failed: %s %s", err, output)
+		}
+	}
+
+	// Check the source copies the files without the repository
+	directory := filepath.Join(t.TempDir(), "version")
+
+	if err := GitSource(work, "main")(context.Background(), directory); err != nil {
+		t.Fatalf("Expected no error from GitSource. Got: %s", err)
+	}
+
+	if body, _ := os.ReadFile(filepath.Join(directory, "index.html")); string(body) != "From git" {
+		t.Errorf("Expected \"From git\" from GitSource. Got: %q", body)
+	}
+
+	if _, err := os.Stat(filepath.Join(directory, ".git")); err == nil {
+		t.Errorf("Expected no .git directory from GitSource")
+	}
+}
+
+// Test the CommandSource with the Syncer's webhook
+func TestSyncerWebhook(t *testing.T) {
+
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	var (
+		secret []byte = []byte("hook secret")
+		body   string = `{"ref":"refs/heads/main"}`
+	)
+
+	source := CommandSource(t.TempDir(), "sh", "-c",
+		`echo building; echo Built > "$HANDLERS_OUTPUT/index.html"`)
+
+	target := NewSwapFileSystem(http.Dir(t.TempDir()))
+	syncer := NewSyncer(source, t.TempDir(), target)
+	defer syncer.Close()
+
+	// Check a hook cannot be created without a secret
+	func() {
+
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected WebhookHandler to panic without a secret")
+			}
+		}()
+
+		syncer.WebhookHandler(nil)
+	}()
+
+	hook := syncer.WebhookHandler(secret)
+
+	// Check unsigned and GET requests are rejected
+	response := httptest.NewRecorder()
+	hook.ServeHTTP(response, httptest.NewRequest("POST", "/hook", strings.NewReader(body)))
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for an unsigned hook. Got: %d", response.Code)
+	}
+
+	response = httptest.NewRecorder()
+	hook.ServeHTTP(response, httptest.NewRequest("GET", "/hook", nil))
+
+	if response.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for a GET request. Got: %d", response.Code)
+	}
+
+	// Check a signed hook starts a build and reports its output
+	request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(body))
+	request.Header.Set("X-Hub-Signature-256", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	response = httptest.NewRecorder()
+	hook.ServeHTTP(response, request)
+
+	if response.Code != http.StatusAccepted {
+		t.Fatalf("Expected 202 for a signed hook. Got: %d", response.Code)
+	}
+
+	deadline := time.Now().Add(10 * time.Second)
+
+	for syncer.Status().Version == "" && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	status := syncer.Status()
+
+	if status.Version == "" || status.LastError != "" {
+		t.Fatalf("Expected a promoted version after the hook. Got: %+v", status)
+	}
+
+	if status.Output != "building\n" {
+		t.Errorf("Expected the build output in the status. Got: %q", status.Output)
+	}
+
+	file, err := target.Open("/index.html")
+
+	if err != nil {
+		t.Fatalf("Expected the built index.html. Got: %s", err)
+	}
+
+	defer file.Close()
+
+	if contents, _ := io.ReadAll(file); string(contents) != "Built\n" {
+		t.Errorf("Expected \"Built\" from the build. Got: %q", contents)
+	}
+
+	// Check a failed build keeps its output and error
+	failing := NewSyncer(CommandSource("", "sh", "-c", "echo broken >&2; exit 1"),
+		t.TempDir(), target)
+
+	if err := failing.Sync(); err == nil {
+		t.Errorf("Expected an error from a failed build")
+	}
+
+	if status := failing.Status(); status.Output != "broken\n" || status.LastError == "" {
+		t.Errorf("Expected the failed build's output and error. Got: %+v", status)
+	}
+
+	// Check the output is not served with the status
+	response = httptest.NewRecorder()
+	failing.ServeHTTP(response, httptest.NewRequest("GET", "/sync", nil))
+
+	if strings.Contains(response.Body.String(), "broken") {
+		t.Errorf("Expected the status to be served without output. Got: %s", response.Body.String())
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"html/template"
+	"sync"
+)
+
+// The names of the built-in themes for the default templates.
+const (
+	ThemePlain   string = "plain"
+	ThemeDark    string = "dark"
+	ThemeBranded string = "branded"
+)
+
+// themes holds the stylesheets of the registered themes by name.
+var (
+	themesMutex sync.RWMutex
+	themes      = map[string]string{
+		ThemePlain: `
+body { margin: 0; font-family: system-ui, sans-serif; color: #1a1a1a; background: #ffffff; }
+main { max-width: 40em; margin: 4em auto; padding: 0 1em; }
+h1 { font-size: 1.75em; }
+`,
+		ThemeDark: `
+body { margin: 0; font-family: system-ui, sans-serif; color: #e8e8e8; background: #16181d; }
+main { max-width: 40em; margin: 4em auto; padding: 0 1em; }
+h1 { font-size: 1.75em; color: #ffffff; }
+a { color: #8ab4f8; }
+`,
+		ThemeBranded: `
+body { margin: 0; font-family: system-ui, sans-serif; color: #1a1a1a; background: #f5f5f7; }
+header { padding: 1em; background: var(--accent, #2b59c3); color: #ffffff; }
+header img { max-height: 2.5em; vertical-align: middle; }
+main { max-width: 40em; margin: 3em auto; padding: 2em; background: #ffffff; border-top: 4px solid var(--accent, #2b59c3); }
+h1 { font-size: 1.75em; }
+`,
+	}
+)
+
+// RegisterTheme adds a theme with the given name and stylesheet, which can
+// then be selected when creating the default templates. Registering a theme
+// with the name of an existing theme replaces it, so the built-in themes can
+// be overridden. The stylesheet is trusted and included in the templates
+// without escaping.
+func RegisterTheme(name string, css string) {
+
+	themesMutex.Lock()
+	defer themesMutex.Unlock()
+
+	themes[name] = css
+}
+
+// ThemedErrorTemplate returns the built-in error page template styled with
+// the named theme.
+func ThemedErrorTemplate(theme string) (*template.Template, error) {
+
+	return BrandedErrorTemplate(theme, Branding{})
+}
+
+// ThemedNotFoundTemplate returns the built-in 404 page template styled with
+// the named theme.
+func ThemedNotFoundTemplate(theme string) (*template.Template, error) {
+
+	return BrandedNotFoundTemplate(theme, Branding{})
+}
+
+// themedTemplate parses the built-in layout with the given page definitions
+// and branding, and defines its "theme" template as the stylesheet of the
+// named theme.
+func themedTemplate(name string, page string, theme string, branding Branding) (*template.Template, error) {
+
+	themesMutex.RLock()
+	css, ok := themes[theme]
+	themesMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("handlers: unknown theme %q", theme)
+	}
+
+	data, err := branding.templateData()
+
+	if err != nil {
+		return nil, err
+	}
+
+	funcs := template.FuncMap{
+		"branding": func() *brandingData { return data },
+	}
+
+	tmpl, err := template.New(name).Funcs(funcs).Parse(defaultLayout)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = tmpl.New("page").Parse(page); err != nil {
+		return nil, err
+	}
+
+	if _, err = tmpl.New("theme").Parse(css); err != nil {
+		return nil, fmt.Errorf("handlers: invalid theme %q: %s", theme, err)
+	}
+
+	return tmpl, nil
+}
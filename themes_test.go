@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// Test the theme functions
+func TestThemes(t *testing.T) {
+
+	var (
+		nfh        *NotFoundHandler
+		bodyString string
+		response   *httptest.ResponseRecorder
+		request    *http.Request
+	)
+
+	// Check each built-in theme can be selected
+	for _, theme := range []string{ThemePlain, ThemeDark, ThemeBranded} {
+
+		if _, err := ThemedErrorTemplate(theme); err != nil {
+			t.Errorf("Expected no error from ThemedErrorTemplate for %s. Got: %s",
+				theme, err)
+		}
+	}
+
+	// Check an unknown theme is reported
+	if _, err := ThemedNotFoundTemplate("unknown"); err == nil {
+		t.Errorf("Expected an error from ThemedNotFoundTemplate for an unknown theme")
+	}
+
+	// Register a theme and serve a 404 page with it
+	RegisterTheme("test", "body { color: #123456; }")
+	defer func() {
+		themesMutex.Lock()
+		delete(themes, "test")
+		themesMutex.Unlock()
+	}()
+
+	tmpl, err := ThemedNotFoundTemplate("test")
+
+	if err != nil {
+		t.Fatalf("Expected no error from ThemedNotFoundTemplate. Got: %s", err)
+	}
+
+	nfh = NewNotFoundHandler(tmpl)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/missing", nil)
+	nfh.ServeHTTP(response, request)
+
+	// Check the page contains the theme's stylesheet
+	bodyString = response.Body.String()
+
+	if !strings.Contains(bodyString, "<style>body { color: #123456; }</style>") {
+		t.Errorf("Expected the test theme in the page. Got: %s", bodyString)
+	}
+}
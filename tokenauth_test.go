@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// Test the TokenAuthHandler
+func TestTokenAuthHandler(t *testing.T) {
+
+	var (
+		h        *TokenAuthHandler
+		key      []byte = []byte("0123456789abcdef0123456789abcdef")
+		clock    *FixedClock
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock = NewFixedClock(now)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, TokenSubject(r))
+	})
+
+	h = NewTokenAuthHandler(next)
+	h.AddStaticToken("static-secret", "deploy")
+	h.SetSigningKey(key)
+	h.SetClock(clock)
+
+	valid := IssueToken(key, "alice", now.Add(time.Hour))
+	tampered := IssueToken(key, "alice", now.Add(time.Hour))
+	tampered = "Ym9i" + tampered[len("YWxpY2U"):]
+
+	tests := []struct {
+		authorization string
+		status        int
+		subject       string
+	}{
+		{"", http.StatusUnauthorized, ""},
+		{"Bearer static-secret", http.StatusOK, "deploy"},
+		{"bearer static-secret", http.StatusOK, "deploy"},
+		{"Basic static-secret", http.StatusUnauthorized, ""},
+		{"Bearer wrong-secret", http.StatusUnauthorized, ""},
+		{"Bearer " + valid, http.StatusOK, "alice"},
+		{"Bearer " + tampered, http.StatusUnauthorized, ""},
+		{"Bearer " + IssueToken([]byte("another key"), "alice", now.Add(time.Hour)),
+			http.StatusUnauthorized, ""},
+		{"Bearer " + IssueToken(key, "alice", now), http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP with the Authorization header
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", "/api/", nil)
+
+		if test.authorization != "" {
+			request.Header.Set("Authorization", test.authorization)
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the status and the subject passed to the protected handler
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %q. Got: %d", test.status, test.authorization, response.Code)
+		}
+
+		if test.status == http.StatusOK && response.Body.String() != test.subject {
+			t.Errorf("Expected subject %q for %q. Got: %q",
+				test.subject, test.authorization, response.Body.String())
+		}
+
+		if test.status == http.StatusUnauthorized && response.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("Expected a WWW-Authenticate header for %q", test.authorization)
+		}
+	}
+
+	// Check a signed token stops working once it expires
+	clock.Advance(2 * time.Hour)
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/api/", nil)
+	request.Header.Set("Authorization", "Bearer "+valid)
+	h.ServeHTTP(response, request)
+
+	if response.Code != http.StatusUnauthorized {
+		t.Errorf("Expected StatusUnauthorized for an expired token. Got: %d", response.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// htmlTransform modifies the contents of an HTML page served by a FileHandler
+// before it is sent to the client. A transform may set response headers, and
+// returns the transformed page.
+type htmlTransform func(w http.ResponseWriter, r *http.Request, page []byte) []byte
+
+// addTransform adds a transform to the handler's HTML transform pipeline.
+// Transforms are applied in the order they were added.
+func (h *FileHandler) addTransform(transform htmlTransform) {
+
+	h.transforms = append(h.transforms, transform)
+}
+
+// SetTransformLimit sets the maximum size in bytes of an HTML page passed
+// through the handler's transforms, such as the consent banner, both as read
+// and after each transform is applied. Transformed pages are held in memory,
+// so the limit stops a very large page, or a transform whose output grows
+// without bound, from exhausting it. A page exceeding the limit is answered
+// with a 500 naming the page and the transform that exceeded it, served by
+// the handler's ErrorHandler if it has one. A value of zero, the default,
+// means no limit.
+func (h *FileHandler) SetTransformLimit(maxBytes int64) {
+
+	h.transformLimit = maxBytes
+}
+
+// serveTransformed reads an HTML page, applies the handler's transforms to it
+// and serves the result. As the output can depend on the request it is served
+// without a Last-Modified time and marked as private.
+func (h *FileHandler) serveTransformed(w http.ResponseWriter, r *http.Request, file io.Reader, name string) {
+
+	var reader io.Reader = file
+
+	if h.transformLimit > 0 {
+		reader = io.LimitReader(file, h.transformLimit+1)
+	}
+
+	page, err := io.ReadAll(reader)
+
+	if err != nil {
+		h.serveError(w, r, err)
+		return
+	}
+
+	if h.exceedsTransformLimit(page) {
+		h.serveError(w, r, fmt.Errorf("handlers: page %s exceeds the transform "+
+			"limit of %d bytes", name, h.transformLimit))
+		return
+	}
+
+	for index, transform := range h.transforms {
+
+		page = transform(w, r, page)
+
+		if h.exceedsTransformLimit(page) {
+			h.serveError(w, r, fmt.Errorf("handlers: transform %d of page %s exceeds "+
+				"the transform limit of %d bytes", index+1, name, h.transformLimit))
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "private, no-cache")
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(page))
+}
+
+// exceedsTransformLimit reports whether the page is larger than the handler's
+// transform limit.
+func (h *FileHandler) exceedsTransformLimit(page []byte) bool {
+
+	return h.transformLimit > 0 && int64(len(page)) > h.transformLimit
+}
+
+// isHTML reports whether the named file is an HTML page.
+func isHTML(name string) bool {
+
+	extension := strings.ToLower(path.Ext(name))
+	return extension == ".html" || extension == ".htm"
+}
+
+// closingBodyPattern matches the closing body tag of an HTML page.
+var closingBodyPattern = regexp.MustCompile(`(?i)</body\s*>`)
+
+// insertBeforeBody inserts the snippet before the last closing body tag of the
+// page, or appends it to the page if there is no closing body tag.
+func insertBeforeBody(page []byte, snippet []byte) []byte {
+
+	matches := closingBodyPattern.FindAllIndex(page, -1)
+
+	if len(matches) == 0 {
+		return append(page, snippet...)
+	}
+
+	position := matches[len(matches)-1][0]
+	result := make([]byte, 0, len(page)+len(snippet))
+	result = append(result, page[:position]...)
+	result = append(result, snippet...)
+	return append(result, page[position:]...)
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"path"
+)
+
+// SandboxPolicy is the Content-Security-Policy sent with untrusted content
+// when it is sandboxed. It puts every page in a sandbox with a unique origin,
+// so that it cannot run scripts or read the site's cookies and storage, and
+// stops it loading any resources.
+const SandboxPolicy string = "sandbox; default-src 'none'"
+
+// SetUntrustedContent sets whether the handler serves content that users have
+// uploaded, or that otherwise cannot be trusted, protecting against stored
+// cross-site scripting through uploaded HTML and SVG files. When untrusted is
+// true the handler sends X-Content-Type-Options: nosniff, so that browsers
+// never guess a more dangerous type than the one sent, and serves files whose
+// extensions have no known type as application/octet-stream, rather than
+// sniffing their contents. If sandbox is also true every response carries
+// SandboxPolicy, so that documents are shown without scripts and in their own
+// origin. Serving the content from its own path or host makes the sandbox
+// easier to reason about. By default content is trusted.
+func (h *FileHandler) SetUntrustedContent(untrusted bool, sandbox bool) {
+
+	h.untrusted = untrusted
+	h.sandbox = untrusted && sandbox
+}
+
+// setUntrustedHeaders sets the headers for serving the named file as untrusted
+// content, if the handler serves untrusted content.
+func (h *FileHandler) setUntrustedHeaders(w http.ResponseWriter, name string) {
+
+	if !h.untrusted {
+		return
+	}
+
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if mime.TypeByExtension(path.Ext(name)) == "" {
+		w.Header().Set("Content-Type", "application/octet-stream")
+	}
+
+	if h.sandbox {
+		w.Header().Add("Content-Security-Policy", SandboxPolicy)
+	}
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+// Test FileHandler untrusted content
+func TestUntrustedContent(t *testing.T) {
+
+	var (
+		h        *FileHandler
+		nfh      *NotFoundHandler
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	nfh = MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+	h = NewFileSystemHandler("/uploads/", http.FS(fstest.MapFS{
+		"photo.png":   {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"page.html":   {Data: []byte("<script>alert(1)</script>")},
+		"upload.data": {Data: []byte("<html><script>alert(1)</script></html>")},
+	}), nfh)
+
+	// Check unknown types are sniffed by default
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/uploads/upload.data", nil)
+	h.ServeHTTP(response, request)
+
+	if contentType := response.Header().Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+		t.Errorf("Expected a sniffed type by default. Got: %s", contentType)
+	}
+
+	h.SetUntrustedContent(true, false)
+
+	tests := []struct {
+		path        string
+		contentType string
+	}{
+		{"/uploads/photo.png", "image/png"},
+		{"/uploads/page.html", "text/html; charset=utf-8"},
+		{"/uploads/upload.data", "application/octet-stream"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the file
+		response = httptest.NewRecorder()
+		request, _ = http.NewRequest("GET", test.path, nil)
+		h.ServeHTTP(response, request)
+
+		// Check known types are kept, others are not sniffed
+		if contentType := response.Header().Get("Content-Type"); contentType != test.contentType {
+			t.Errorf("Expected %s for %s. Got: %s", test.contentType, test.path, contentType)
+		}
+
+		if nosniff := response.Header().Get("X-Content-Type-Options"); nosniff != "nosniff" {
+			t.Errorf("Expected nosniff for %s. Got: %q", test.path, nosniff)
+		}
+
+		if policy := response.Header().Get("Content-Security-Policy"); policy != "" {
+			t.Errorf("Expected no sandbox for %s. Got: %s", test.path, policy)
+		}
+	}
+
+	// Check sandboxed content carries the sandbox policy
+	h.SetUntrustedContent(true, true)
+
+	response = httptest.NewRecorder()
+	request, _ = http.NewRequest("GET", "/uploads/page.html", nil)
+	h.ServeHTTP(response, request)
+
+	if policy := response.Header().Get("Content-Security-Policy"); policy != SandboxPolicy {
+		t.Errorf("Expected the sandbox policy. Got: %q", policy)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// userNamePattern matches the user names accepted by a UserDirHandler, which
+// follow the usual rules for Unix account names.
+var userNamePattern = regexp.MustCompile(`^[a-z_][a-z0-9_-]{0,31}$`)
+
+// UserDirHandler serves per-user sites from home directories, mapping
+// requests for /~name/path to path in the named user's directory, like the
+// classic userdir module of other web servers. Each user's files are served
+// with a FileHandler, so they have the same protections against unsafe paths
+// and directory listings. The handler should be bound to "/~" or to "/".
+// Access to each user's site can be checked with SetAuthorizer, the bytes
+// served for each user can be limited with SetDailyQuota, and 404s are
+// counted per user so one user's broken links are not lost among another's.
+type UserDirHandler struct {
+	pattern         string
+	notFoundHandler http.Handler
+	authorize       func(r *http.Request, user string) bool
+	quota           int64
+	clock           Clock
+	mutex           sync.Mutex
+	day             time.Time
+	served          map[string]int64
+	notFound        map[string]int64
+}
+
+// NewUserDirHandler returns a new UserDirHandler. The pattern gives the path
+// of each user's directory with "{user}" in place of the user name, for
+// example "/home/{user}/public_html". Requests for users whose directory does
+// not exist are served with notFoundHandler.
+func NewUserDirHandler(pattern string, notFoundHandler http.Handler) *UserDirHandler {
+
+	return &UserDirHandler{
+		pattern:         pattern,
+		notFoundHandler: notFoundHandler,
+		clock:           SystemClock,
+		served:          make(map[string]int64),
+		notFound:        make(map[string]int64),
+	}
+}
+
+// SetAuthorizer sets a function that decides whether a request may see the
+// named user's site. Requests it refuses get a 403. By default every user's
+// site is public.
+func (h *UserDirHandler) SetAuthorizer(authorize func(r *http.Request, user string) bool) {
+
+	h.authorize = authorize
+}
+
+// SetDailyQuota limits the number of bytes served from each user's site per
+// day, so that one popular site cannot use all of a shared server's bandwidth.
+// Once a user's quota is used up, requests for their site get a 429 until the
+// next day. A quota of zero, the default, means no limit.
+func (h *UserDirHandler) SetDailyQuota(bytes int64) {
+
+	h.quota = bytes
+}
+
+// SetClock sets the clock used to decide when each day's quota starts. By
+// default the handler uses SystemClock.
+func (h *UserDirHandler) SetClock(clock Clock) {
+
+	h.clock = clock
+}
+
+// NotFoundCounts returns the number of 404s served for each user's site.
+func (h *UserDirHandler) NotFoundCounts() map[string]int64 {
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	return copyCounts(h.notFound)
+}
+
+// ServeHTTP serves the request from the named user's directory.
+func (h *UserDirHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	setServerHeader(w)
+
+	if !strings.HasPrefix(r.URL.Path, "/~") {
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	user := strings.TrimPrefix(r.URL.Path, "/~")
+	hasSlash := false
+
+	if slash := strings.IndexByte(user, '/'); slash >= 0 {
+		user, hasSlash = user[:slash], true
+	}
+
+	if !userNamePattern.MatchString(user) {
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	directory := strings.ReplaceAll(h.pattern, "{user}", user)
+
+	if finfo, err := os.Stat(directory); err != nil || !finfo.IsDir() {
+		h.notFoundHandler.ServeHTTP(w, r)
+		return
+	}
+
+	if h.authorize != nil && !h.authorize(r, user) {
+		serveForbidden(w)
+		return
+	}
+
+	// Redirect /~name to /~name/ so relative links in the site work
+	if !hasSlash {
+
+		location := "/~" + user + "/"
+
+		if r.URL.RawQuery != "" {
+			location += "?" + r.URL.RawQuery
+		}
+
+		w.Header().Set("Location", location)
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+
+	if !h.withinQuota(user) {
+
+		w.Header().Set("Retry-After", strconv.Itoa(h.secondsUntilTomorrow()))
+		http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+		return
+	}
+
+	notFound := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		h.mutex.Lock()
+		incrementCount(h.notFound, user)
+		h.mutex.Unlock()
+
+		h.notFoundHandler.ServeHTTP(w, r)
+	})
+
+	recorder := &byteCounter{ResponseWriter: w}
+	NewFileHandler("/~"+user+"/", directory, notFound).ServeHTTP(recorder, r)
+	h.addServed(user, recorder.bytes)
+}
+
+// withinQuota reports whether the user has any of today's quota left.
+func (h *UserDirHandler) withinQuota(user string) bool {
+
+	if h.quota <= 0 {
+		return true
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.resetDay()
+	return h.served[user] < h.quota
+}
+
+// addServed adds to the bytes served today for the user.
+func (h *UserDirHandler) addServed(user string, bytes int64) {
+
+	if h.quota <= 0 {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.resetDay()
+	h.served[user] += bytes
+}
+
+// resetDay clears the bytes served when a new day starts. The mutex must be
+// held.
+func (h *UserDirHandler) resetDay() {
+
+	now := h.clock.Now().UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	if !today.Equal(h.day) {
+		h.day = today
+		h.served = make(map[string]int64)
+	}
+}
+
+// secondsUntilTomorrow returns the number of seconds until the next day's
+// quota starts.
+func (h *UserDirHandler) secondsUntilTomorrow() int {
+
+	now := h.clock.Now().UTC()
+	tomorrow := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	return int(tomorrow.Sub(now)/time.Second) + 1
+}
+
+// byteCounter counts the bytes written to a response.
+type byteCounter struct {
+	http.ResponseWriter
+	bytes int64
+}
+
+// Write passes p through and counts the bytes the client was sent.
+func (c *byteCounter) Write(p []byte) (int, error) {
+
+	n, err := c.ResponseWriter.Write(p)
+	c.bytes += int64(n)
+	return n, err
+}
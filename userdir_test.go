@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Test the UserDirHandler
+func TestUserDirHandler(t *testing.T) {
+
+	var (
+		h        *UserDirHandler
+		clock    *FixedClock
+		response *httptest.ResponseRecorder
+		request  *http.Request
+	)
+
+	// Create home directories for two users
+	home := t.TempDir()
+
+	for _, user := range []string{"alice", "bob"} {
+
+		site := filepath.Join(home, user, "public_html")
+		os.MkdirAll(site, 0755)
+		os.WriteFile(filepath.Join(site, "index.html"), []byte("Home of "+user), 0644)
+	}
+
+	os.WriteFile(filepath.Join(home, "alice", "secret.txt"), []byte("secret"), 0644)
+
+	clock = NewFixedClock(time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC))
+	nfh := MustLoadNotFoundHandler(filepath.FromSlash("templates/notfound.html"))
+
+	h = NewUserDirHandler(filepath.Join(home, "{user}", "public_html"), nfh)
+	h.SetClock(clock)
+	h.SetAuthorizer(func(r *http.Request, user string) bool {
+		return user != "bob" || r.Header.Get("X-Friend") == "yes"
+	})
+
+	tests := []struct {
+		path   string
+		friend bool
+		status int
+		body   string
+	}{
+		{"/~alice/", false, http.StatusOK, "Home of alice"},
+		{"/~alice", false, http.StatusFound, ""},
+		{"/~alice/missing.html", false, http.StatusNotFound, ""},
+		{"/~alice/../secret.txt", false, http.StatusNotFound, ""},
+		{"/~alice/..%2fsecret.txt", false, http.StatusNotFound, ""},
+		{"/~carol/", false, http.StatusNotFound, ""},
+		{"/~../etc/", false, http.StatusNotFound, ""},
+		{"/~bob/", false, http.StatusForbidden, ""},
+		{"/~bob/", true, http.StatusOK, "Home of bob"},
+	}
+
+	for _, test := range tests {
+
+		// Test ServeHTTP on the path
+		response = httptest.NewRecorder()
+		request = httptest.NewRequest("GET", test.path, nil)
+
+		if test.friend {
+			request.Header.Set("X-Friend", "yes")
+		}
+
+		h.ServeHTTP(response, request)
+
+		// Check the status and body
+		if response.Code != test.status {
+			t.Errorf("Expected %d for %s. Got: %d", test.status, test.path, response.Code)
+		}
+
+		if test.body != "" && response.Body.String() != test.body {
+			t.Errorf("Expected %q for %s. Got: %q", test.body, test.path, response.Body.String())
+		}
+	}
+
+	// Check the quota stops a user's site once it is used up
+	h.SetDailyQuota(20)
+
+	for _, status := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+
+		response = httptest.NewRecorder()
+		h.ServeHTTP(response, httptest.NewRequest("GET", "/~alice/", nil))
+
+		if response.Code != status {
+			t.Errorf("Expected %d from the quota. Got: %d", status, response.Code)
+		}
+	}
+
+	// Check the quota is reset the next day
+	clock.Advance(24 * time.Hour)
+	response = httptest.NewRecorder()
+	h.ServeHTTP(response, httptest.NewRequest("GET", "/~alice/", nil))
+
+	if response.Code != http.StatusOK {
+		t.Errorf("Expected StatusOK after the quota reset. Got: %d", response.Code)
+	}
+
+	// Check 404s are counted for each user
+	counts := h.NotFoundCounts()
+
+	if counts["alice"] != 3 || counts["bob"] != 0 {
+		t.Errorf("Expected 3 404s for alice and none for bob. Got: %v", counts)
+	}
+}